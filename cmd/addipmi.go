@@ -4,19 +4,21 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"html/template"
-	"os"
-	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bmc-toolbox/bmclib/v2"
 	tinkv1alpha1 "github.com/kubefirst/tink/api/v1alpha1"
 
+	"github.com/konstructio/colony/internal/bmc"
 	"github.com/konstructio/colony/internal/constants"
 	"github.com/konstructio/colony/internal/k8s"
 	"github.com/konstructio/colony/internal/logger"
+	"github.com/konstructio/colony/internal/printer"
 	"github.com/konstructio/colony/internal/utils"
 	"github.com/konstructio/colony/manifests"
 	"github.com/spf13/cobra"
@@ -30,6 +32,8 @@ type IPMIAuth struct {
 	Username     string
 	InsecureTLS  bool
 	AutoDiscover bool
+	UpdatedAt    string
+	UpdatedBy    string
 }
 
 type RufioPowerCycleRequest struct {
@@ -39,189 +43,347 @@ type RufioPowerCycleRequest struct {
 	RandomSuffix string
 }
 
+// bmcConnectTimeout bounds the login and inventory of a BMC.
+const bmcConnectTimeout = 2 * time.Minute
+
 func getAddIPMICommand() *cobra.Command {
-	var ip, username, password string
+	var ip, username, password, fromFile string
 	var autoDiscover, insecureTLS bool
-	var templates []string
+	var concurrency int
 
 	getAddIPMICmd := &cobra.Command{
 		Use:   "add-ipmi",
 		Short: "adds an IPMI auth to the cluster",
+		Long: `adds an IPMI auth to the cluster
+
+add a single bmc with --ip, or many at once with --from-file. the file is a
+csv file with an ip, username, password and insecure_tls header, or a yaml
+or json list of objects with ip, username, password and insecureTLS keys.
+every bmc is validated first, then up to --concurrency bmcs are added at a
+time and a summary is printed at the end.
+
+with --auto-discover every machine is power cycled into pxe and the
+hardware tinkerbell registers for it is found by its bmc reference or by
+one of the nic mac addresses the bmc reported.`,
+		Args: cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			log := logger.New(logger.Debug)
-
 			ctx := cmd.Context()
+			log := logger.FromContext(ctx)
 
-			log.Infof("adding ipmi information for host %q - auto discovery %t", ip, autoDiscover)
-
-			// validate login credentials
-			log.Infof("validating credentials")
-			bmcClient := bmclib.NewClient(ip, username, password)
-
-			if err := bmcClient.Open(context.Background()); err != nil {
-				// could also be a connection timeout
-				return fmt.Errorf("error connecting to remote server: %w", err)
+			var entries []bmc.Entry
+			if fromFile != "" {
+				var err error
+				entries, err = bmc.ParseFile(fromFile)
+				if err != nil {
+					return fmt.Errorf("error reading bmcs: %w", err)
+				}
+			} else {
+				if password == "" {
+					return errors.New("--password is required with --ip")
+				}
+				entries = []bmc.Entry{{IP: ip, Username: username, Password: password, InsecureTLS: &insecureTLS}}
 			}
 
-			defer bmcClient.Close(context.Background())
-			log.Infof("successfully connected to remote server")
-
-			log.Infof("fetching remote server (%s) inventory", ip)
+			if concurrency < 1 {
+				return errors.New("--concurrency must be at least 1")
+			}
 
-			inventory, err := bmcClient.Inventory(context.Background())
+			colonyCtx, err := currentContext(cmd)
 			if err != nil {
-				return fmt.Errorf("error getting machine inventory: %w", err)
+				return err
 			}
 
-			homeDir, err := os.UserHomeDir()
+			k8sClient, err := k8s.New(log, colonyCtx.KubeconfigPath())
 			if err != nil {
-				return fmt.Errorf("error getting user home directory: %w", err)
+				return fmt.Errorf("failed to create k8s client: %w", err)
 			}
 
-			fileTypes := []string{"machine", "secret"}
-			randomSuffix := utils.RandomString(6)
+			if err = k8sClient.LoadMappingsFromKubernetes(); err != nil {
+				return fmt.Errorf("error loading dynamic mappings from kubernetes: %w", err)
+			}
 
-			for _, t := range fileTypes {
-				file, err := manifests.IPMI.ReadFile(fmt.Sprintf("ipmi/ipmi-%s.yaml.tmpl", t))
-				if err != nil {
-					return fmt.Errorf("error reading templates file: %w", err)
-				}
+			if fromFile == "" {
+				_, err := addIPMI(ctx, log.WithField(logger.FieldBMCIP, ip), k8sClient, entries[0], autoDiscover)
+				return err
+			}
 
-				tmpl, err := template.New("ipmi").Funcs(template.FuncMap{
-					"base64Encode": func(s string) string {
-						return base64.StdEncoding.EncodeToString([]byte(s))
-					},
-					"replaceDotsWithDash": func(s string) string {
-						return strings.ReplaceAll(s, ".", "-")
-					},
-				}).Parse(string(file))
-				if err != nil {
-					return fmt.Errorf("error parsing template: %w", err)
-				}
+			results := addIPMIs(ctx, log, k8sClient, entries, autoDiscover, concurrency)
 
-				var outputBuffer bytes.Buffer
+			p, err := printer.New(printer.Table)
+			if err != nil {
+				return fmt.Errorf("error creating printer: %w", err)
+			}
 
-				err = tmpl.Execute(&outputBuffer, IPMIAuth{
-					IP:           ip,
-					Username:     username,
-					Password:     password,
-					InsecureTLS:  insecureTLS,
-					AutoDiscover: autoDiscover,
-					BoardSerial:  inventory.Serial,
-				})
-				if err != nil {
-					return fmt.Errorf("error executing template: %w", err)
-				}
-				templates = append(templates, outputBuffer.String())
+			if err := p.Print(cmd.OutOrStdout(), results, addIPMIRows(results)); err != nil {
+				return fmt.Errorf("error printing summary: %w", err)
 			}
 
-			k8sClient, err := k8s.New(log, filepath.Join(homeDir, constants.ColonyDir, constants.KubeconfigHostPath))
-			if err != nil {
-				return fmt.Errorf("failed to create k8s client: %w", err)
+			var failed int
+			for _, result := range results {
+				if result.Error != "" {
+					failed++
+				}
 			}
 
-			if err = k8sClient.LoadMappingsFromKubernetes(); err != nil {
-				return fmt.Errorf("error loading dynamic mappings from kubernetes: %w", err)
+			if failed > 0 {
+				return fmt.Errorf("%d of %d bmcs failed to be added", failed, len(results))
 			}
 
-			// Create a channel to receive the hardware object
-			hardwareChan := make(chan *tinkv1alpha1.Hardware, 1)
-			errChan := make(chan error, 1)
+			return nil
+		},
+	}
+	getAddIPMICmd.Flags().BoolVar(&autoDiscover, "auto-discover", false, "whether to auto-discover the machine note: this power cycles the machines")
+	getAddIPMICmd.Flags().BoolVar(&insecureTLS, "insecure", true, "the ipmi insecure tls")
+	getAddIPMICmd.Flags().StringVar(&ip, "ip", "", "the ipmi ip address")
+	getAddIPMICmd.Flags().StringVar(&password, "password", "", "the ipmi password")
+	getAddIPMICmd.Flags().StringVar(&username, "username", bmc.DefaultUsername, "the ipmi username")
+	getAddIPMICmd.Flags().StringVar(&fromFile, "from-file", "", "add the bmcs of a csv, yaml or json file")
+	getAddIPMICmd.Flags().IntVar(&concurrency, "concurrency", 5, "number of bmcs of --from-file added at a time")
 
-			go func() {
-				log.Infof("starting informer for hardware creation")
-				err := k8sClient.HardwareInformer(ctx, ip, hardwareChan)
-				if err != nil {
-					errChan <- fmt.Errorf("error watching hardware creation: %w", err)
-				}
-			}()
+	getAddIPMICmd.MarkFlagsOneRequired("ip", "from-file")
+	getAddIPMICmd.MarkFlagsMutuallyExclusive("ip", "from-file")
+	getAddIPMICmd.MarkFlagsMutuallyExclusive("password", "from-file")
 
-			if err := k8sClient.ApplyManifests(ctx, templates); err != nil {
-				return fmt.Errorf("error applying templates: %w", err)
-			}
+	return getAddIPMICmd
+}
+
+// addIPMIResult is the outcome of adding a BMC.
+type addIPMIResult struct {
+	IP          string `json:"ip"`
+	BoardSerial string `json:"boardSerial,omitempty"`
+	HardwareID  string `json:"hardwareID,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// addIPMIs adds the BMCs, at most concurrency at a time, and returns the
+// result of every BMC in the order of the entries.
+func addIPMIs(ctx context.Context, log *logger.Logger, k8sClient *k8s.Client, entries []bmc.Entry, autoDiscover bool, concurrency int) []addIPMIResult {
+	results := make([]addIPMIResult, len(entries))
+	sem := make(chan struct{}, concurrency)
 
-			err = k8sClient.FetchAndWaitForMachines(ctx, k8s.MachineDetails{
-				Name:        strings.ReplaceAll(ip, ".", "-"),
-				Namespace:   constants.ColonyNamespace,
-				WaitTimeout: 90,
-			})
+	var wg sync.WaitGroup
+	for i, entry := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			bmcLog := log.WithField(logger.FieldBMCIP, entry.IP)
+
+			result, err := addIPMI(ctx, bmcLog, k8sClient, entry, autoDiscover)
 			if err != nil {
-				return fmt.Errorf("error get machine: %w", err)
+				bmcLog.Errorf("failed to add bmc: %s", err)
+				result.Error = err.Error()
 			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
 
-			log.Infof("machine is ready")
+	return results
+}
 
-			if autoDiscover {
-				file, err := manifests.IPMI.ReadFile("ipmi/ipmi-off-pxe-on.yaml.tmpl")
-				if err != nil {
-					return fmt.Errorf("error reading templates file: %w", err)
-				}
+// addIPMI validates the BMC credentials, reads its inventory and creates
+// its rufio Machine and auth secret. With autoDiscover the machine is
+// power cycled into pxe so tinkerbell registers its hardware.
+func addIPMI(ctx context.Context, log *logger.Logger, k8sClient *k8s.Client, entry bmc.Entry, autoDiscover bool) (addIPMIResult, error) {
+	ip := entry.IP
+	result := addIPMIResult{IP: ip}
 
-				tmpl, err := template.New("ipmi").Funcs(template.FuncMap{
-					"replaceDotsWithDash": func(s string) string {
-						return strings.ReplaceAll(s, ".", "-")
-					},
-				}).Parse(string(file))
-				if err != nil {
-					return fmt.Errorf("error parsing template: %w", err)
-				}
+	// stops the hardware informer once done
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-				var outputBuffer2 bytes.Buffer
+	log.Infof("adding ipmi information for host %q - auto discovery %t", ip, autoDiscover)
 
-				err = tmpl.Execute(&outputBuffer2, RufioPowerCycleRequest{
-					IP:           ip,
-					BootDevice:   "pxe",
-					EFIBoot:      true,
-					RandomSuffix: randomSuffix,
-				})
-				if err != nil {
-					return fmt.Errorf("error executing template: %w", err)
-				}
+	// validate login credentials
+	log.Infof("validating credentials")
 
-				if err := k8sClient.ApplyManifests(ctx, []string{outputBuffer2.String()}); err != nil {
-					return fmt.Errorf("error applying rufiojob: %w", err)
-				}
+	connectCtx, connectCancel := context.WithTimeout(ctx, bmcConnectTimeout)
+	defer connectCancel()
 
-				err = k8sClient.FetchAndWaitForRufioJobs(ctx, k8s.RufioJobWaitRequest{
-					LabelValue:   fmt.Sprintf("%s-off-pxe-on-%s", strings.ReplaceAll(ip, ".", "-"), randomSuffix),
-					Namespace:    constants.ColonyNamespace,
-					WaitTimeout:  300,
-					RandomSuffix: randomSuffix,
-				})
-				if err != nil {
-					return fmt.Errorf("error get machine: %w", err)
-				}
-				// Wait for hardware or error
-				select {
-				case hardware := <-hardwareChan:
+	bmcClient := bmclib.NewClient(ip, entry.Username, entry.Password)
 
-					log.Infof("added ipmi connectivity for %q", ip)
-					log.Infof("associated colony hardware id: %q", hardware.Name)
+	if err := bmcClient.Open(connectCtx); err != nil {
+		// could also be a connection timeout
+		return result, fmt.Errorf("error connecting to remote server: %w", err)
+	}
 
-				case err := <-errChan:
-					log.Errorf("Error: %v", err)
+	defer bmcClient.Close(context.Background())
+	log.Infof("successfully connected to remote server")
 
-				case <-ctx.Done():
-					log.Info("Context cancelled")
+	log.Infof("fetching remote server (%s) inventory", ip)
 
-				case <-time.After(5 * time.Minute):
-					log.Info("Timeout waiting for hardware creation")
-				}
-			}
+	inventory, err := bmcClient.Inventory(connectCtx)
+	if err != nil {
+		return result, fmt.Errorf("error getting machine inventory: %w", err)
+	}
+	result.BoardSerial = inventory.Serial
+
+	fileTypes := []string{"machine", "secret"}
+	randomSuffix := utils.RandomString(6)
+
+	templates := make([]string, 0, len(fileTypes))
+	for _, t := range fileTypes {
+		file, err := manifests.IPMI.ReadFile(fmt.Sprintf("ipmi/ipmi-%s.yaml.tmpl", t))
+		if err != nil {
+			return result, fmt.Errorf("error reading templates file: %w", err)
+		}
+
+		tmpl, err := template.New("ipmi").Funcs(template.FuncMap{
+			"base64Encode": func(s string) string {
+				return base64.StdEncoding.EncodeToString([]byte(s))
+			},
+			"replaceDotsWithDash": func(s string) string {
+				return strings.ReplaceAll(s, ".", "-")
+			},
+		}).Parse(string(file))
+		if err != nil {
+			return result, fmt.Errorf("error parsing template: %w", err)
+		}
+
+		var outputBuffer bytes.Buffer
+
+		err = tmpl.Execute(&outputBuffer, IPMIAuth{
+			IP:           ip,
+			Username:     entry.Username,
+			Password:     entry.Password,
+			InsecureTLS:  entry.Insecure(),
+			AutoDiscover: autoDiscover,
+			BoardSerial:  inventory.Serial,
+			UpdatedAt:    time.Now().UTC().Format(time.RFC3339),
+			UpdatedBy:    operator(),
+		})
+		if err != nil {
+			return result, fmt.Errorf("error executing template: %w", err)
+		}
+		templates = append(templates, outputBuffer.String())
+	}
 
-			return nil
+	// Create a channel to receive the hardware object
+	hardwareChan := make(chan *tinkv1alpha1.Hardware, 1)
+	errChan := make(chan error, 1)
+
+	go func() {
+		log.Infof("starting informer for hardware creation")
+		match := k8s.HardwareMatch{BMCIP: ip, MACs: bmc.MACAddresses(inventory)}
+		err := k8sClient.HardwareInformer(ctx, match, hardwareChan)
+		if err != nil {
+			errChan <- fmt.Errorf("error watching hardware creation: %w", err)
+		}
+	}()
+
+	if err := k8sClient.ApplyManifests(ctx, templates); err != nil {
+		return result, fmt.Errorf("error applying templates: %w", err)
+	}
+
+	err = k8sClient.FetchAndWaitForMachines(ctx, k8s.MachineDetails{
+		Name:        strings.ReplaceAll(ip, ".", "-"),
+		Namespace:   constants.ColonyNamespace,
+		WaitTimeout: 90,
+	})
+	if err != nil {
+		return result, fmt.Errorf("error get machine: %w", err)
+	}
+
+	log.Infof("machine is ready")
+
+	if err := k8sClient.SaveInventory(ctx, strings.ReplaceAll(ip, ".", "-"), ip, inventory); err != nil {
+		log.Warnf("failed to store the inventory, collect it again with colony hardware inventory refresh: %s", err)
+	}
+
+	if !autoDiscover {
+		return result, nil
+	}
+
+	file, err := manifests.IPMI.ReadFile("ipmi/ipmi-off-pxe-on.yaml.tmpl")
+	if err != nil {
+		return result, fmt.Errorf("error reading templates file: %w", err)
+	}
+
+	tmpl, err := template.New("ipmi").Funcs(template.FuncMap{
+		"replaceDotsWithDash": func(s string) string {
+			return strings.ReplaceAll(s, ".", "-")
 		},
+	}).Parse(string(file))
+	if err != nil {
+		return result, fmt.Errorf("error parsing template: %w", err)
 	}
-	getAddIPMICmd.Flags().BoolVar(&autoDiscover, "auto-discover", false, "whether to auto-discover the machine note: this power cycles the machines")
-	getAddIPMICmd.Flags().BoolVar(&insecureTLS, "insecure", true, "the ipmi insecure tls")
-	getAddIPMICmd.Flags().StringVar(&ip, "ip", "", "the ipmi ip address")
-	getAddIPMICmd.Flags().StringVar(&password, "password", "", "the ipmi password")
-	getAddIPMICmd.Flags().StringVar(&username, "username", "admin", "the ipmi username")
 
-	// getAddIPMICmd.MarkFlagRequired("hardware-id")
-	getAddIPMICmd.MarkFlagRequired("ip")
-	getAddIPMICmd.MarkFlagRequired("password")
-	getAddIPMICmd.MarkFlagRequired("username")
+	var outputBuffer2 bytes.Buffer
 
-	return getAddIPMICmd
+	err = tmpl.Execute(&outputBuffer2, RufioPowerCycleRequest{
+		IP:           ip,
+		BootDevice:   "pxe",
+		EFIBoot:      true,
+		RandomSuffix: randomSuffix,
+	})
+	if err != nil {
+		return result, fmt.Errorf("error executing template: %w", err)
+	}
+
+	if err := k8sClient.ApplyManifests(ctx, []string{outputBuffer2.String()}); err != nil {
+		return result, fmt.Errorf("error applying rufiojob: %w", err)
+	}
+
+	err = k8sClient.FetchAndWaitForRufioJobs(ctx, k8s.RufioJobWaitRequest{
+		LabelValue:   fmt.Sprintf("%s-off-pxe-on-%s", strings.ReplaceAll(ip, ".", "-"), randomSuffix),
+		Namespace:    constants.ColonyNamespace,
+		WaitTimeout:  300,
+		RandomSuffix: randomSuffix,
+	})
+	if err != nil {
+		return result, fmt.Errorf("error get machine: %w", err)
+	}
+	// Wait for hardware or error
+	select {
+	case hardware := <-hardwareChan:
+
+		log.Infof("added ipmi connectivity for %q", ip)
+		log.Infof("associated colony hardware id: %q", hardware.Name)
+		result.HardwareID = hardware.Name
+
+	case err := <-errChan:
+		return result, err
+
+	case <-ctx.Done():
+		return result, fmt.Errorf("interrupted while waiting for the hardware to be discovered: %w", ctx.Err())
+
+	case <-time.After(5 * time.Minute):
+		return result, errors.New("timed out after 5m waiting for the hardware to be discovered")
+	}
+
+	return result, nil
+}
+
+func addIPMIRows(results []addIPMIResult) printer.Rows {
+	rows := printer.Rows{
+		Columns: []printer.Column{
+			{Name: "bmc ip"},
+			{Name: "board serial"},
+			{Name: "hardware id"},
+			{Name: "status"},
+			{Name: "error"},
+		},
+		Items: make([]map[string]string, 0, len(results)),
+	}
+
+	for _, result := range results {
+		status := "added"
+		if result.Error != "" {
+			status = "failed"
+		}
+
+		rows.Items = append(rows.Items, map[string]string{
+			"bmc ip":       result.IP,
+			"board serial": result.BoardSerial,
+			"hardware id":  result.HardwareID,
+			"status":       status,
+			"error":        result.Error,
+		})
+	}
+
+	return rows
 }
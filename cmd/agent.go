@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/konstructio/colony/internal/agent"
+	"github.com/konstructio/colony/internal/colony"
+	"github.com/konstructio/colony/internal/config"
+	"github.com/konstructio/colony/internal/k8s"
+	"github.com/konstructio/colony/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+func getAgentCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "agent",
+		Short: "run the colony agent on this host",
+	}
+
+	cmd.AddCommand(getAgentRunCommand())
+
+	return cmd
+}
+
+func getAgentRunCommand() *cobra.Command {
+	var interval, maxBackoff time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "report the state of the data center to the colony api until stopped",
+		Long: `report the state of the data center to the colony api until stopped
+
+sends a heartbeat every --interval with the health of the kubernetes api,
+the readiness of the colony deployments, the number of hardware in each
+state and the running rufio jobs and workflows. while the colony api is
+unreachable the heartbeats back off up to --max-backoff.
+
+runs until it receives SIGINT or SIGTERM, so it can run as a systemd unit.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			log := logger.FromContext(cmd.Context())
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			colonyCtx, err := currentContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			// installs made before the config was saved ran in docker
+			cfg, err := config.Load(colonyCtx.ConfigPath())
+			if err != nil {
+				cfg = config.Default()
+			}
+
+			k8sClient, err := k8s.New(log, colonyCtx.KubeconfigPath())
+			if err != nil {
+				return fmt.Errorf("failed to create k8s client: %w", err)
+			}
+
+			agentConfig := loadAgentConfig(ctx, log, k8sClient, cfg)
+			if agentConfig == nil {
+				return errors.New("no registered agent found, run `colony init` first")
+			}
+
+			collector := agent.NewCollector(k8sClient, statusDeployments(cfg))
+			// the agent backs off on its own between heartbeats
+			colonyAPI, err := newColonyAPI(log, agentConfig, colony.WithMaxRetries(0))
+			if err != nil {
+				return err
+			}
+			a := agent.New(log, colonyAPI, agentConfig.AgentID, collector.Collect)
+			a.Interval = interval
+			a.MaxBackoff = maxBackoff
+			a.AfterHeartbeat = func(ctx context.Context) {
+				replayPendingUpdates(ctx, log, colonyCtx)
+			}
+
+			log.Infof("reporting the state of agent %s to %s every %s", agentConfig.AgentID, agentConfig.APIURL, interval)
+
+			if err := a.Run(ctx); err != nil {
+				return fmt.Errorf("error running agent: %w", err)
+			}
+
+			log.Info("agent stopped")
+			return nil
+		},
+	}
+
+	cmd.Flags().DurationVar(&interval, "interval", agent.DefaultInterval, "time between two heartbeats")
+	cmd.Flags().DurationVar(&maxBackoff, "max-backoff", agent.DefaultMaxBackoff, "maximum time between two heartbeats while the colony api is unreachable")
+
+	return cmd
+}
+
+// loadAgentConfig returns the agent credentials from the colony-api
+// secret, falling back to the config saved by `colony init`. It returns
+// nil if no agent was registered.
+func loadAgentConfig(ctx context.Context, log *logger.Logger, k8sClient *k8s.Client, cfg *config.Config) *k8s.AgentConfig {
+	if k8sClient != nil {
+		secretCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		agentConfig, err := k8sClient.GetAgentConfig(secretCtx)
+		if err == nil {
+			return agentConfig
+		}
+		log.Warnf("unable to read agent config from cluster: %s", err)
+	}
+
+	if cfg != nil && cfg.AgentID != "" {
+		apiTLS, err := configTLS(cfg)
+		if err != nil {
+			log.Warnf("unable to read the colony api tls settings: %s", err)
+		}
+
+		return &k8s.AgentConfig{
+			AgentID: cfg.AgentID,
+			APIKey:  cfg.APIKey,
+			APIURL:  cfg.APIURL,
+			TLS:     apiTLS,
+		}
+	}
+
+	return nil
+}
+
+// configTLS reads the colony api TLS files of the config.
+func configTLS(cfg *config.Config) (colony.TLS, error) {
+	apiTLS, err := colony.LoadTLS(cfg.APITLS.CAFile, cfg.APITLS.ClientCert, cfg.APITLS.ClientKey, cfg.APITLS.Insecure)
+	if err != nil {
+		return colony.TLS{}, fmt.Errorf("error loading colony api tls settings: %w", err)
+	}
+	return apiTLS, nil
+}
+
+// newColonyAPI returns a colony api client using the agent credentials and
+// TLS settings.
+func newColonyAPI(log *logger.Logger, agentConfig *k8s.AgentConfig, opts ...colony.Option) (*colony.API, error) {
+	tlsConfig, err := agentConfig.TLS.Config()
+	if err != nil {
+		return nil, fmt.Errorf("invalid colony api tls settings: %w", err)
+	}
+
+	if agentConfig.TLS.Insecure {
+		log.Warnf("the certificate of the colony api at %s is not verified, the api key can be intercepted", agentConfig.APIURL)
+	}
+
+	opts = append([]colony.Option{colony.WithTLSConfig(tlsConfig)}, opts...)
+	return colony.New(agentConfig.APIURL, agentConfig.APIKey, opts...), nil
+}
@@ -2,42 +2,96 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"time"
 
-	"github.com/konstructio/colony/internal/constants"
 	"github.com/konstructio/colony/internal/k8s"
 	"github.com/konstructio/colony/internal/logger"
+	"github.com/konstructio/colony/internal/printer"
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/duration"
 )
 
 func getAssetsCommand() *cobra.Command {
+	var output string
+
 	assetsCmd := &cobra.Command{
 		Use:   "assets",
 		Short: "list the colony assets in the data center",
+		Long: `list the colony assets in the data center
+
+-o wide adds the bmc ip, board serial, power state and age of every asset.`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			ctx := cmd.Context()
-			log := logger.New(logger.Debug)
-			homeDir, err := os.UserHomeDir()
+			log := logger.FromContext(cmd.Context())
+
+			p, err := printer.New(output)
 			if err != nil {
-				return fmt.Errorf("error getting user home directory: %w", err)
+				return fmt.Errorf("error creating printer: %w", err)
 			}
 
-			k8sClient, err := k8s.New(log, filepath.Join(homeDir, constants.ColonyDir, constants.KubeconfigHostPath))
+			colonyCtx, err := currentContext(cmd)
 			if err != nil {
-				return fmt.Errorf("failed to create k8s client: %w", err)
+				return err
 			}
 
-			if err = k8sClient.LoadMappingsFromKubernetes(); err != nil {
-				return fmt.Errorf("error loading dynamic mappings from kubernetes: %w", err)
+			k8sClient, err := k8s.New(log, colonyCtx.KubeconfigPath())
+			if err != nil {
+				return fmt.Errorf("failed to create k8s client: %w", err)
 			}
-			err = k8sClient.ListAssets(ctx)
+
+			assets, err := k8sClient.ListAssets(ctx)
 			if err != nil {
 				return fmt.Errorf("error listing assets: %w", err)
 			}
 
+			if len(assets) == 0 && p.IsTable() {
+				log.Info("no assets found")
+				return nil
+			}
+
+			if err := p.Print(cmd.OutOrStdout(), assets, assetRows(assets)); err != nil {
+				return fmt.Errorf("error printing assets: %w", err)
+			}
+
 			return nil
 		},
 	}
+
+	addOutputFlag(assetsCmd, &output)
+	assetsCmd.AddCommand(getAssetsDiffCommand(), getAssetsSyncCommand())
+
 	return assetsCmd
 }
+
+func assetRows(assets []k8s.Asset) printer.Rows {
+	rows := printer.Rows{
+		Columns: []printer.Column{
+			{Name: "name"},
+			{Name: "hostname"},
+			{Name: "ip"},
+			{Name: "mac"},
+			{Name: "status"},
+			{Name: "bmc ip", Wide: true},
+			{Name: "board serial", Wide: true},
+			{Name: "power", Wide: true},
+			{Name: "age", Wide: true},
+		},
+		Items: make([]map[string]string, 0, len(assets)),
+	}
+
+	for _, asset := range assets {
+		rows.Items = append(rows.Items, map[string]string{
+			"name":         asset.Name,
+			"hostname":     asset.Hostname,
+			"ip":           asset.IP,
+			"mac":          asset.MAC,
+			"status":       asset.Status,
+			"bmc ip":       asset.BMCIP,
+			"board serial": asset.BoardSerial,
+			"power":        asset.PowerState,
+			"age":          duration.HumanDuration(time.Since(asset.CreatedAt)),
+		})
+	}
+
+	return rows
+}
@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/konstructio/colony/internal/assets"
+	"github.com/konstructio/colony/internal/colony"
+	"github.com/konstructio/colony/internal/config"
+	"github.com/konstructio/colony/internal/k8s"
+	"github.com/konstructio/colony/internal/logger"
+	"github.com/konstructio/colony/internal/printer"
+	"github.com/spf13/cobra"
+)
+
+func getAssetsDiffCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "compare the assets of the data center with the colony cloud",
+		Long: `compare the assets of the data center with the colony cloud
+
+hardware and cloud assets are matched by mac address, then by name. the
+diff lists the hardware missing in the cloud, the cloud assets missing
+locally and the assets whose mac, ip or name differ.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			log := logger.FromContext(cmd.Context())
+
+			p, err := printer.New(output)
+			if err != nil {
+				return fmt.Errorf("error creating printer: %w", err)
+			}
+
+			s, err := newAssetSyncer(cmd)
+			if err != nil {
+				return err
+			}
+
+			drifts, err := s.diff(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			if len(drifts) == 0 && p.IsTable() {
+				log.Info("the assets are in sync with the colony cloud")
+				return nil
+			}
+
+			if err := p.Print(cmd.OutOrStdout(), drifts, driftRows(drifts)); err != nil {
+				return fmt.Errorf("error printing asset diff: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	addOutputFlag(cmd, &output)
+
+	return cmd
+}
+
+func getAssetsSyncCommand() *cobra.Command {
+	var dryRun, prune, yes bool
+
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "update the colony cloud with the assets of the data center",
+		Long: `update the colony cloud with the assets of the data center
+
+the hardware of the data center is the source of truth: the hardware
+missing in the cloud is created and the mismatching assets are updated.
+cloud assets missing locally are only deleted with --prune.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			log := logger.FromContext(ctx)
+
+			s, err := newAssetSyncer(cmd)
+			if err != nil {
+				return err
+			}
+
+			drifts, err := s.diff(ctx)
+			if err != nil {
+				return err
+			}
+
+			var pruned int
+			for _, drift := range drifts {
+				if drift.Kind == assets.MissingLocally {
+					pruned++
+				}
+			}
+
+			if dryRun {
+				for _, drift := range drifts {
+					if action := syncAction(drift, prune); action != "" {
+						fmt.Fprintf(cmd.OutOrStdout(), "would %s %s\n", action, drift.Name)
+					}
+				}
+				return nil
+			}
+
+			if prune && pruned > 0 && !yes {
+				ok, err := confirm(cmd, fmt.Sprintf("delete %d asset(s) from the colony cloud?", pruned))
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return errors.New("sync aborted")
+				}
+			}
+
+			var synced, failed int
+			for _, drift := range drifts {
+				action := syncAction(drift, prune)
+				if action == "" {
+					continue
+				}
+
+				if err := s.apply(ctx, drift, action); err != nil {
+					log.Errorf("failed to %s %s: %s", action, drift.Name, err)
+					failed++
+					continue
+				}
+
+				log.Infof("%sd %s", action, drift.Name)
+				synced++
+			}
+
+			if !prune && pruned > 0 {
+				log.Warnf("%d cloud asset(s) have no local hardware, rerun with --prune to delete them", pruned)
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%d of %d assets failed to sync", failed, failed+synced)
+			}
+
+			log.Infof("%d asset(s) synced with the colony cloud", synced)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "only print what would be changed in the colony cloud")
+	cmd.Flags().BoolVar(&prune, "prune", false, "delete the cloud assets that have no local hardware")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "do not ask for confirmation before pruning")
+
+	return cmd
+}
+
+// syncAction is what sync does for a drift, or empty if nothing.
+func syncAction(drift assets.Drift, prune bool) string {
+	switch drift.Kind {
+	case assets.MissingInCloud:
+		return "create"
+	case assets.Mismatch:
+		return "update"
+	case assets.MissingLocally:
+		if prune {
+			return "delete"
+		}
+	}
+	return ""
+}
+
+// assetSyncer compares and reconciles the local hardware with the assets
+// of the colony cloud.
+type assetSyncer struct {
+	k8sClient *k8s.Client
+	colonyAPI *colony.API
+	agentID   string
+}
+
+func newAssetSyncer(cmd *cobra.Command) (*assetSyncer, error) {
+	log := logger.FromContext(cmd.Context())
+
+	colonyCtx, err := currentContext(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	k8sClient, err := k8s.New(log, colonyCtx.KubeconfigPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create k8s client: %w", err)
+	}
+
+	cfg, err := config.Load(colonyCtx.ConfigPath())
+	if err != nil {
+		cfg = nil
+	}
+
+	agentConfig := loadAgentConfig(cmd.Context(), log, k8sClient, cfg)
+	if agentConfig == nil {
+		return nil, errors.New("no registered agent found, run `colony init` first")
+	}
+
+	colonyAPI, err := newColonyAPI(log, agentConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &assetSyncer{
+		k8sClient: k8sClient,
+		colonyAPI: colonyAPI,
+		agentID:   agentConfig.AgentID,
+	}, nil
+}
+
+func (s *assetSyncer) diff(ctx context.Context) ([]assets.Drift, error) {
+	local, err := s.k8sClient.ListAssets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing assets: %w", err)
+	}
+
+	cloud, err := s.colonyAPI.ListAssets(ctx, s.agentID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing colony cloud assets: %w", err)
+	}
+
+	return assets.Diff(local, cloud), nil
+}
+
+func (s *assetSyncer) apply(ctx context.Context, drift assets.Drift, action string) error {
+	switch action {
+	case "create":
+		if _, err := s.colonyAPI.CreateAsset(ctx, s.agentID, assets.ToCloud(*drift.Local, nil)); err != nil {
+			return fmt.Errorf("error creating asset: %w", err)
+		}
+	case "update":
+		if err := s.colonyAPI.UpdateAsset(ctx, s.agentID, assets.ToCloud(*drift.Local, drift.Cloud)); err != nil {
+			return fmt.Errorf("error updating asset: %w", err)
+		}
+	case "delete":
+		if err := s.colonyAPI.DeleteAsset(ctx, s.agentID, drift.AssetID); err != nil {
+			return fmt.Errorf("error deleting asset: %w", err)
+		}
+	}
+	return nil
+}
+
+func driftRows(drifts []assets.Drift) printer.Rows {
+	rows := printer.Rows{
+		Columns: []printer.Column{
+			{Name: "name"},
+			{Name: "drift"},
+			{Name: "local mac"},
+			{Name: "cloud mac"},
+			{Name: "local ip"},
+			{Name: "cloud ip"},
+			{Name: "asset id", Wide: true},
+		},
+		Items: make([]map[string]string, 0, len(drifts)),
+	}
+
+	for _, drift := range drifts {
+		rows.Items = append(rows.Items, map[string]string{
+			"name":      drift.Name,
+			"drift":     string(drift.Kind),
+			"local mac": drift.LocalMAC,
+			"cloud mac": drift.CloudMAC,
+			"local ip":  drift.LocalIP,
+			"cloud ip":  drift.CloudIP,
+			"asset id":  drift.AssetID,
+		})
+	}
+
+	return rows
+}
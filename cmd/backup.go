@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/konstructio/colony/internal/backup"
+	"github.com/konstructio/colony/internal/constants"
+	"github.com/konstructio/colony/internal/k8s"
+	"github.com/konstructio/colony/internal/logger"
+	"github.com/konstructio/colony/internal/table"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// backupPassphraseEnv holds the passphrase of the backup secrets when
+// --passphrase-file is not set.
+const backupPassphraseEnv = "COLONY_BACKUP_PASSPHRASE"
+
+func getBackupCommand() *cobra.Command {
+	var output, passphraseFile string
+
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "back up the provisioning state of your data center",
+		Long: `back up the provisioning state of your data center
+
+exports the ipmi auth and colony-api secrets, rufio machines, hardware,
+templates and workflows along with the rendered colony.yaml to a gzipped
+tar archive. use "colony restore" to re-apply them.
+
+the secrets are encrypted when a passphrase is given with --passphrase-file
+or the ` + backupPassphraseEnv + ` environment variable.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			log := logger.FromContext(cmd.Context())
+			ctx := cmd.Context()
+
+			passphrase, err := readPassphrase(passphraseFile)
+			if err != nil {
+				return err
+			}
+
+			colonyCtx, err := currentContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			k8sClient, err := k8s.New(log, colonyCtx.KubeconfigPath())
+			if err != nil {
+				return fmt.Errorf("failed to create k8s client: %w", err)
+			}
+
+			b := backup.New()
+
+			for _, kind := range backup.Kinds {
+				objs, err := collectBackupObjects(ctx, k8sClient, kind)
+				if err != nil {
+					return fmt.Errorf("error backing up %s: %w", kind.Name, err)
+				}
+
+				for i := range objs {
+					content, err := kind.Marshal(&objs[i])
+					if err != nil {
+						return fmt.Errorf("error backing up %s: %w", kind.Name, err)
+					}
+					b.Add(kind.Name, objs[i].GetName(), content)
+				}
+			}
+
+			colonyYAML, err := os.ReadFile(colonyCtx.BootstrapPath())
+			if err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					return fmt.Errorf("error reading colony.yaml: %w", err)
+				}
+				log.Warn("no rendered colony.yaml found, it will not be part of the backup")
+			}
+			b.ColonyYAML = colonyYAML
+
+			if passphrase == "" {
+				log.Warnf("no passphrase given, the secrets are stored unencrypted in %q", output)
+			}
+
+			file, err := os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+			if err != nil {
+				return fmt.Errorf("error creating backup file %q: %w", output, err)
+			}
+			defer file.Close()
+
+			if err := b.Write(file, passphrase); err != nil {
+				return fmt.Errorf("error writing backup: %w", err)
+			}
+
+			if err := file.Close(); err != nil {
+				return fmt.Errorf("error writing backup file %q: %w", output, err)
+			}
+
+			printBackupCounts(b)
+			log.Infof("backup written to %q", output)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "colony-backup.tar.gz", "path of the backup archive to write")
+	cmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "file holding the passphrase to encrypt the secrets with")
+
+	return cmd
+}
+
+// collectBackupObjects returns the objects of the kind selected by its
+// label selector, and its named objects.
+func collectBackupObjects(ctx context.Context, k8sClient *k8s.Client, kind backup.Kind) ([]unstructured.Unstructured, error) {
+	var objs []unstructured.Unstructured
+
+	if kind.LabelSelector != "" || len(kind.Names) == 0 {
+		selected, err := k8sClient.ListObjects(ctx, kind.GVR, constants.ColonyNamespace, metav1.ListOptions{LabelSelector: kind.LabelSelector})
+		if err != nil {
+			return nil, fmt.Errorf("error listing %s: %w", kind.Name, err)
+		}
+		objs = selected
+	}
+
+	for _, name := range kind.Names {
+		obj, err := k8sClient.GetObject(ctx, kind.GVR, constants.ColonyNamespace, name)
+		if err != nil {
+			return nil, fmt.Errorf("error getting %s: %w", kind.Name, err)
+		}
+
+		if obj != nil && !slices.ContainsFunc(objs, func(o unstructured.Unstructured) bool { return o.GetName() == name }) {
+			objs = append(objs, *obj)
+		}
+	}
+
+	return objs, nil
+}
+
+// readPassphrase reads the backup passphrase from path, or from the
+// environment when path is empty. An empty passphrase means no
+// encryption.
+func readPassphrase(path string) (string, error) {
+	if path == "" {
+		return os.Getenv(backupPassphraseEnv), nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading passphrase file %q: %w", path, err)
+	}
+
+	passphrase := strings.TrimSpace(string(content))
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase file %q is empty", path)
+	}
+
+	return passphrase, nil
+}
+
+func printBackupCounts(b *backup.Backup) {
+	printer := table.NewTablePrinter([]table.Column{
+		{Name: "kind", Align: "left"},
+		{Name: "objects", Align: "right"},
+	})
+
+	rows := make([]map[string]string, 0, len(backup.Kinds))
+	for _, kind := range backup.Kinds {
+		rows = append(rows, map[string]string{
+			"kind":    kind.Name,
+			"objects": strconv.Itoa(len(b.Entries[kind.Name])),
+		})
+	}
+
+	printer.PrintTable(rows)
+}
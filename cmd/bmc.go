@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"os"
+	"os/user"
+
+	"github.com/spf13/cobra"
+)
+
+func getBMCCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bmc",
+		Short: "manage the bmcs of the data center",
+	}
+
+	cmd.AddCommand(getBMCListCommand(), getBMCScanCommand(), getBMCCredentialsCommand())
+
+	return cmd
+}
+
+// operator identifies who runs colony, recorded on the changes it makes.
+func operator() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+
+	return name
+}
@@ -0,0 +1,316 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bmc-toolbox/bmclib/v2"
+	"github.com/konstructio/colony/internal/bmc"
+	"github.com/konstructio/colony/internal/constants"
+	"github.com/konstructio/colony/internal/k8s"
+	"github.com/konstructio/colony/internal/logger"
+	"github.com/konstructio/colony/internal/printer"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+const (
+	// bmcRotateTimeout bounds the rotation of the password of a BMC.
+	bmcRotateTimeout = 3 * time.Minute
+
+	// bmcRollbackTimeout bounds setting the previous password back, on
+	// its own so a rotation that ran out of time can still be undone.
+	bmcRollbackTimeout = 2 * time.Minute
+)
+
+func getBMCCredentialsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "credentials",
+		Short: "manage the credentials colony uses to reach the bmcs",
+	}
+
+	cmd.AddCommand(getBMCCredentialsShowCommand(), getBMCCredentialsRotateCommand())
+
+	return cmd
+}
+
+func getBMCCredentialsShowCommand() *cobra.Command {
+	var hardwareID, selector, output string
+
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "show who last set the credentials of the bmcs and when",
+		Long: `show who last set the credentials of the bmcs and when
+
+the passwords are never printed.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			log := logger.FromContext(ctx)
+
+			p, err := printer.New(output)
+			if err != nil {
+				return fmt.Errorf("error creating printer: %w", err)
+			}
+
+			k8sClient, err := newBMCCredentialsClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			creds, err := k8sClient.ListBMCCredentials(ctx, credentialsSelector(hardwareID, selector))
+			if err != nil {
+				return fmt.Errorf("error listing bmc credentials: %w", err)
+			}
+
+			if len(creds) == 0 && p.IsTable() {
+				log.Info("no bmc credentials found")
+				return nil
+			}
+
+			if err := p.Print(cmd.OutOrStdout(), creds, credentialsRows(creds)); err != nil {
+				return fmt.Errorf("error printing bmc credentials: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	addCredentialsSelectorFlags(cmd, &hardwareID, &selector)
+	addOutputFlag(cmd, &output)
+
+	return cmd
+}
+
+func getBMCCredentialsRotateCommand() *cobra.Command {
+	var hardwareID, selector string
+
+	cmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "set a new random password on the bmcs",
+		Long: `set a new random password on the bmcs
+
+for every bmc a strong password is generated and set on the bmc for the
+user colony logs in with. the new password is verified with a new login
+before it is stored in the ipmi auth secret. if the verification or the
+secret update fails, the previous password is set back on the bmc. if
+that fails too, the new password is kept in the <secret>-recovery secret,
+or in a file of the colony context directory when the cluster cannot
+store it.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			log := logger.FromContext(ctx)
+
+			colonyCtx, err := currentContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			k8sClient, err := newBMCCredentialsClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			creds, err := k8sClient.ListBMCCredentials(ctx, credentialsSelector(hardwareID, selector))
+			if err != nil {
+				return fmt.Errorf("error listing bmc credentials: %w", err)
+			}
+
+			if len(creds) == 0 {
+				return errors.New("no bmc credentials match the selection")
+			}
+
+			by := operator()
+
+			var failed int
+			for _, cred := range creds {
+				credLog := log.WithFields(logger.Fields{
+					logger.FieldBMCIP:      cred.Host,
+					logger.FieldHardwareID: cred.HardwareID,
+				})
+
+				if err := rotateBMCPassword(ctx, k8sClient, cred, by, colonyCtx.Dir); err != nil {
+					credLog.Errorf("failed to rotate the password of %s: %s", cred.SecretName, err)
+					failed++
+					continue
+				}
+
+				credLog.Infof("rotated the password of %s", cred.SecretName)
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%d of %d bmc passwords failed to rotate", failed, len(creds))
+			}
+
+			return nil
+		},
+	}
+
+	addCredentialsSelectorFlags(cmd, &hardwareID, &selector)
+	cmd.MarkFlagsOneRequired("hardware-id", "selector")
+
+	return cmd
+}
+
+func addCredentialsSelectorFlags(cmd *cobra.Command, hardwareID, selector *string) {
+	cmd.Flags().StringVar(hardwareID, "hardware-id", "", "only the bmc of this hardware")
+	cmd.Flags().StringVarP(selector, "selector", "l", "", "only the bmcs whose ipmi auth secret matches this label selector")
+	cmd.MarkFlagsMutuallyExclusive("hardware-id", "selector")
+}
+
+// credentialsSelector returns the label selector of the ipmi auth
+// secrets picked with --hardware-id or --selector.
+func credentialsSelector(hardwareID, selector string) string {
+	if hardwareID != "" {
+		return fmt.Sprintf("colony.konstruct.io/hardware-id=%s", hardwareID)
+	}
+	return selector
+}
+
+func newBMCCredentialsClient(cmd *cobra.Command) (*k8s.Client, error) {
+	colonyCtx, err := currentContext(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	k8sClient, err := k8s.New(logger.FromContext(cmd.Context()), colonyCtx.KubeconfigPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create k8s client: %w", err)
+	}
+
+	return k8sClient, nil
+}
+
+// rotateBMCPassword sets a new password on the BMC, checks it with a new
+// login and stores it in the secret. The previous password is set back if
+// the new one cannot be used or stored, and the new one is kept for
+// recovery if that fails too.
+func rotateBMCPassword(ctx context.Context, k8sClient *k8s.Client, cred k8s.BMCCredentials, by, recoveryDir string) error {
+	if cred.Host == "" {
+		return fmt.Errorf("no rufio machine found for secret %q", cred.SecretName)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, bmcRotateTimeout)
+	defer cancel()
+
+	password, err := bmc.GeneratePassword(bmc.PasswordLength)
+	if err != nil {
+		return fmt.Errorf("error generating password: %w", err)
+	}
+
+	if err := setBMCPassword(ctx, cred.Host, cred.Username, cred.Password, password); err != nil {
+		return fmt.Errorf("error setting the new password: %w", err)
+	}
+
+	rollback := func(cause error) error {
+		// the rotation may have failed because ctx ran out of time
+		rollbackCtx, rollbackCancel := context.WithTimeout(context.WithoutCancel(ctx), bmcRollbackTimeout)
+		defer rollbackCancel()
+
+		if err := setBMCPassword(rollbackCtx, cred.Host, cred.Username, password, cred.Password); err != nil {
+			location, saveErr := saveRecoveryPassword(rollbackCtx, k8sClient, cred, password, by, recoveryDir)
+			if saveErr != nil {
+				return fmt.Errorf("%w, setting the previous password back failed: %w, and the new password could not be kept: %w", cause, err, saveErr)
+			}
+			return fmt.Errorf("%w, setting the previous password back failed: %w, the new password is kept in %s", cause, err, location)
+		}
+		return fmt.Errorf("%w, the previous password was set back", cause)
+	}
+
+	if err := verifyBMCLogin(ctx, cred.Host, cred.Username, password); err != nil {
+		return rollback(fmt.Errorf("error logging in with the new password: %w", err))
+	}
+
+	if err := k8sClient.UpdateBMCPassword(ctx, cred, password, by); err != nil {
+		return rollback(fmt.Errorf("error storing the new password: %w", err))
+	}
+
+	return nil
+}
+
+// saveRecoveryPassword keeps a password set on the BMC that is stored
+// nowhere else, in a recovery secret or else in a file of dir only
+// readable by the user. It returns where the password was kept.
+func saveRecoveryPassword(ctx context.Context, k8sClient *k8s.Client, cred k8s.BMCCredentials, password, by, dir string) (string, error) {
+	name, secretErr := k8sClient.SaveBMCRecoveryPassword(ctx, cred, password, by)
+	if secretErr == nil {
+		return fmt.Sprintf("secret %q of namespace %q", name, constants.ColonyNamespace), nil
+	}
+
+	path := filepath.Join(dir, cred.SecretName+"-recovery-password")
+	if err := os.WriteFile(path, []byte(password+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("error storing the recovery secret: %w, and writing %q: %w", secretErr, path, err)
+	}
+
+	return fmt.Sprintf("file %q", path), nil
+}
+
+// setBMCPassword logs in with the current password and changes the
+// password of the user, keeping its role.
+func setBMCPassword(ctx context.Context, host, username, current, password string) error {
+	client := bmclib.NewClient(host, username, current)
+	if err := client.Open(ctx); err != nil {
+		return fmt.Errorf("error connecting to %s: %w", host, err)
+	}
+	defer client.Close(context.Background())
+
+	ok, err := client.UpdateUser(ctx, username, password, "")
+	if err != nil {
+		return fmt.Errorf("error updating user %q: %w", username, err)
+	}
+	if !ok {
+		return fmt.Errorf("the bmc did not update user %q", username)
+	}
+
+	return nil
+}
+
+func verifyBMCLogin(ctx context.Context, host, username, password string) error {
+	client := bmclib.NewClient(host, username, password)
+	if err := client.Open(ctx); err != nil {
+		return fmt.Errorf("error connecting to %s: %w", host, err)
+	}
+
+	if err := client.Close(ctx); err != nil {
+		return fmt.Errorf("error closing connection to %s: %w", host, err)
+	}
+
+	return nil
+}
+
+func credentialsRows(creds []k8s.BMCCredentials) printer.Rows {
+	rows := printer.Rows{
+		Columns: []printer.Column{
+			{Name: "secret"},
+			{Name: "bmc ip"},
+			{Name: "hardware id"},
+			{Name: "username"},
+			{Name: "updated by"},
+			{Name: "updated"},
+		},
+		Items: make([]map[string]string, 0, len(creds)),
+	}
+
+	for _, cred := range creds {
+		updatedBy := cred.UpdatedBy
+		if updatedBy == "" {
+			updatedBy = "unknown"
+		}
+
+		rows.Items = append(rows.Items, map[string]string{
+			"secret":      cred.SecretName,
+			"bmc ip":      cred.Host,
+			"hardware id": cred.HardwareID,
+			"username":    cred.Username,
+			"updated by":  updatedBy,
+			"updated":     duration.HumanDuration(time.Since(cred.UpdatedAt)) + " ago",
+		})
+	}
+
+	return rows
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/konstructio/colony/internal/k8s"
+	"github.com/konstructio/colony/internal/logger"
+	"github.com/konstructio/colony/internal/printer"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+// bmcStates are the values of --state. unknown matches the bmcs rufio
+// never reached as well as an unknown power state.
+var bmcStates = []string{"reachable", "unreachable", "unknown", "on", "off"}
+
+func getBMCListCommand() *cobra.Command {
+	var output string
+	var states []string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list the bmcs with their reachability and power state",
+		Long: `list the bmcs with their reachability and power state
+
+the bmcs are read from the rufio machines and ipmi auth secrets. a bmc
+rufio never tried to reach is unknown. --state keeps the bmcs in any of
+the given states: ` + strings.Join(bmcStates, ", ") + `.
+
+  colony bmc list --state unreachable`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			log := logger.FromContext(ctx)
+
+			for _, state := range states {
+				if !slices.Contains(bmcStates, state) {
+					return fmt.Errorf("unsupported state %q, must be one of: %s", state, strings.Join(bmcStates, ", "))
+				}
+			}
+
+			p, err := printer.New(output)
+			if err != nil {
+				return fmt.Errorf("error creating printer: %w", err)
+			}
+
+			colonyCtx, err := currentContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			k8sClient, err := k8s.New(log, colonyCtx.KubeconfigPath())
+			if err != nil {
+				return fmt.Errorf("failed to create k8s client: %w", err)
+			}
+
+			bmcs, err := k8sClient.ListBMCs(ctx)
+			if err != nil {
+				return fmt.Errorf("error listing bmcs: %w", err)
+			}
+
+			bmcs = filterBMCs(bmcs, states)
+
+			if len(bmcs) == 0 && p.IsTable() {
+				log.Info("no bmcs found")
+				return nil
+			}
+
+			if err := p.Print(cmd.OutOrStdout(), bmcs, bmcRows(bmcs)); err != nil {
+				return fmt.Errorf("error printing bmcs: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&states, "state", nil, "only list the bmcs in these states ("+strings.Join(bmcStates, ", ")+")")
+	addOutputFlag(cmd, &output)
+
+	return cmd
+}
+
+// bmcContactable returns yes, no or unknown when rufio never tried to
+// reach the bmc.
+func bmcContactable(bmc k8s.BMC) string {
+	switch {
+	case bmc.Contactable == nil:
+		return "unknown"
+	case *bmc.Contactable:
+		return "yes"
+	default:
+		return "no"
+	}
+}
+
+func bmcPower(bmc k8s.BMC) string {
+	if bmc.PowerState == "" {
+		return "unknown"
+	}
+	return bmc.PowerState
+}
+
+// bmcState returns the --state values the bmc matches.
+func bmcState(bmc k8s.BMC) []string {
+	state := []string{bmcPower(bmc)}
+
+	switch bmcContactable(bmc) {
+	case "yes":
+		state = append(state, "reachable")
+	case "no":
+		state = append(state, "unreachable")
+	default:
+		state = append(state, "unknown")
+	}
+
+	return state
+}
+
+func filterBMCs(bmcs []k8s.BMC, states []string) []k8s.BMC {
+	if len(states) == 0 {
+		return bmcs
+	}
+
+	filtered := make([]k8s.BMC, 0, len(bmcs))
+	for _, bmc := range bmcs {
+		for _, state := range bmcState(bmc) {
+			if slices.Contains(states, state) {
+				filtered = append(filtered, bmc)
+				break
+			}
+		}
+	}
+
+	return filtered
+}
+
+func bmcRows(bmcs []k8s.BMC) printer.Rows {
+	rows := printer.Rows{
+		Columns: []printer.Column{
+			{Name: "bmc ip"},
+			{Name: "hardware id"},
+			{Name: "board serial"},
+			{Name: "contactable"},
+			{Name: "power"},
+			{Name: "reachability changed"},
+			{Name: "machine", Wide: true},
+			{Name: "message", Wide: true},
+		},
+		Items: make([]map[string]string, 0, len(bmcs)),
+	}
+
+	for _, bmc := range bmcs {
+		changed := "unknown"
+		if !bmc.ContactableSince.IsZero() {
+			changed = duration.HumanDuration(time.Since(bmc.ContactableSince)) + " ago"
+		}
+
+		rows.Items = append(rows.Items, map[string]string{
+			"bmc ip":               bmc.IP,
+			"hardware id":          bmc.HardwareID,
+			"board serial":         bmc.BoardSerial,
+			"contactable":          bmcContactable(bmc),
+			"power":                bmcPower(bmc),
+			"reachability changed": changed,
+			"machine":              bmc.Name,
+			"message":              bmc.Message,
+		})
+	}
+
+	return rows
+}
@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/konstructio/colony/internal/bmc"
+	"github.com/konstructio/colony/internal/k8s"
+	"github.com/konstructio/colony/internal/logger"
+	"github.com/konstructio/colony/internal/printer"
+	"github.com/spf13/cobra"
+)
+
+// Statuses of a scanned BMC.
+const (
+	scanStatusFound       = "found"
+	scanStatusLoginFailed = "login failed"
+	scanStatusExisting    = "already added"
+	scanStatusDuplicate   = "duplicate"
+	scanStatusEnrolled    = "enrolled"
+	scanStatusFailed      = "enroll failed"
+)
+
+// scannedBMC is a BMC found by a scan and what was done with it.
+type scannedBMC struct {
+	bmc.ScanResult
+	Status  string `json:"status"`
+	Machine string `json:"machine,omitempty"`
+	// DuplicateOf is the ip of the bmc found first with the same board
+	// serial, e.g. a bmc answering on a dedicated and a shared port.
+	DuplicateOf string `json:"duplicateOf,omitempty"`
+}
+
+func getBMCScanCommand() *cobra.Command {
+	var cidr, credentialsFile, output string
+	var enroll bool
+	var concurrency int
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "scan",
+		Short: "discover the bmcs of a network",
+		Long: `discover the bmcs of a network
+
+probes every host of --cidr for a redfish (443/tcp) or ipmi (623/udp)
+endpoint and tries the credential sets of --credentials on every bmc found
+to read its vendor, model and board serial. the credentials file is a yaml
+or json list of username and password objects.
+
+with --enroll the bmcs are added like with add-ipmi. bmcs whose board
+serial or ip was already added are skipped, and so are the bmcs sharing
+the board serial of a bmc found at a lower ip.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			log := logger.FromContext(ctx)
+
+			p, err := printer.New(output)
+			if err != nil {
+				return fmt.Errorf("error creating printer: %w", err)
+			}
+
+			if concurrency < 1 {
+				return errors.New("--concurrency must be at least 1")
+			}
+
+			hosts, err := bmc.Hosts(cidr)
+			if err != nil {
+				return fmt.Errorf("error reading network: %w", err)
+			}
+
+			creds, err := bmc.ParseCredentials(credentialsFile)
+			if err != nil {
+				return fmt.Errorf("error reading credentials: %w", err)
+			}
+
+			colonyCtx, err := currentContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			k8sClient, err := k8s.New(log, colonyCtx.KubeconfigPath())
+			if err != nil {
+				return fmt.Errorf("failed to create k8s client: %w", err)
+			}
+
+			existing, err := k8sClient.MachinesBySerial(ctx)
+			if err != nil {
+				return fmt.Errorf("error listing bmcs: %w", err)
+			}
+
+			machines, err := k8sClient.MachineNames(ctx)
+			if err != nil {
+				return fmt.Errorf("error listing bmcs: %w", err)
+			}
+
+			log.Infof("scanning %d hosts of %s", len(hosts), cidr)
+
+			scanner := &bmc.Scanner{
+				Credentials: creds,
+				Concurrency: concurrency,
+				Timeout:     timeout,
+			}
+			results := scanner.Scan(ctx, hosts)
+
+			log.Infof("found %d bmcs", len(results))
+
+			bmcs := make([]scannedBMC, 0, len(results))
+			// the ip of the first bmc found by board serial
+			seen := make(map[string]string, len(results))
+			var toEnroll []bmc.Entry
+			for _, result := range results {
+				scanned := scannedBMC{ScanResult: result, Status: scanStatusFound}
+				machine := strings.ReplaceAll(result.IP, ".", "-")
+
+				switch {
+				case result.Credential == nil:
+					scanned.Status = scanStatusLoginFailed
+				case existing[result.BoardSerial] != "":
+					scanned.Status = scanStatusExisting
+					scanned.Machine = existing[result.BoardSerial]
+				case machines[machine]:
+					scanned.Status = scanStatusExisting
+					scanned.Machine = machine
+				case result.BoardSerial != "" && seen[result.BoardSerial] != "":
+					scanned.Status = scanStatusDuplicate
+					scanned.DuplicateOf = seen[result.BoardSerial]
+				case enroll:
+					toEnroll = append(toEnroll, bmc.Entry{
+						IP:       result.IP,
+						Username: result.Credential.Username,
+						Password: result.Credential.Password,
+					})
+				}
+
+				if result.Credential != nil && result.BoardSerial != "" && seen[result.BoardSerial] == "" {
+					seen[result.BoardSerial] = result.IP
+				}
+
+				bmcs = append(bmcs, scanned)
+			}
+
+			var failed int
+			if len(toEnroll) > 0 {
+				if err = k8sClient.LoadMappingsFromKubernetes(); err != nil {
+					return fmt.Errorf("error loading dynamic mappings from kubernetes: %w", err)
+				}
+
+				enrolled := make(map[string]addIPMIResult, len(toEnroll))
+				// enrolling waits on the cluster, keep it to the add-ipmi default
+				for _, result := range addIPMIs(ctx, log, k8sClient, toEnroll, false, min(concurrency, 5)) {
+					enrolled[result.IP] = result
+				}
+
+				for i := range bmcs {
+					result, ok := enrolled[bmcs[i].IP]
+					switch {
+					case !ok:
+					case result.Error != "":
+						bmcs[i].Status = scanStatusFailed
+						bmcs[i].Error = result.Error
+						failed++
+					default:
+						bmcs[i].Status = scanStatusEnrolled
+					}
+				}
+			}
+
+			if len(bmcs) == 0 && p.IsTable() {
+				log.Infof("no bmc found in %s", cidr)
+				return nil
+			}
+
+			if err := p.Print(cmd.OutOrStdout(), bmcs, scanRows(bmcs)); err != nil {
+				return fmt.Errorf("error printing bmcs: %w", err)
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%d of %d bmcs failed to be enrolled", failed, len(toEnroll))
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&cidr, "cidr", "", "ipv4 network to scan, at most a /16")
+	cmd.Flags().StringVar(&credentialsFile, "credentials", "", "yaml or json file of the credential sets to try")
+	cmd.Flags().BoolVar(&enroll, "enroll", false, "add the bmcs found like add-ipmi does")
+	cmd.Flags().IntVar(&concurrency, "concurrency", 32, "number of hosts probed at a time")
+	cmd.Flags().DurationVar(&timeout, "timeout", bmc.DefaultProbeTimeout, "time to wait for a host to answer a probe")
+	addOutputFlag(cmd, &output)
+
+	cmd.MarkFlagRequired("cidr")
+	cmd.MarkFlagRequired("credentials")
+
+	return cmd
+}
+
+func scanRows(bmcs []scannedBMC) printer.Rows {
+	rows := printer.Rows{
+		Columns: []printer.Column{
+			{Name: "ip"},
+			{Name: "vendor"},
+			{Name: "model"},
+			{Name: "board serial"},
+			{Name: "status"},
+			{Name: "redfish", Wide: true},
+			{Name: "ipmi", Wide: true},
+			{Name: "username", Wide: true},
+			{Name: "duplicate of", Wide: true},
+			{Name: "error", Wide: true},
+		},
+		Items: make([]map[string]string, 0, len(bmcs)),
+	}
+
+	for _, b := range bmcs {
+		rows.Items = append(rows.Items, map[string]string{
+			"ip":           b.IP,
+			"vendor":       b.Vendor,
+			"model":        b.Model,
+			"board serial": b.BoardSerial,
+			"status":       b.Status,
+			"redfish":      strconv.FormatBool(b.Redfish),
+			"ipmi":         strconv.FormatBool(b.IPMI),
+			"username":     b.Username,
+			"duplicate of": b.DuplicateOf,
+			"error":        b.Error,
+		})
+	}
+
+	return rows
+}
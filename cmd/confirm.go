@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// errNotInteractive is returned when a confirmation is needed but
+// nobody can answer it.
+var errNotInteractive = errors.New("confirmation required, rerun with --yes when not running interactively")
+
+// confirm asks a yes/no question on the terminal. It fails when stdin
+// is not a terminal so scripts must opt in with --yes.
+func confirm(cmd *cobra.Command, question string) (bool, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, errNotInteractive
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "%s [y/N]: ", question)
+
+	answer, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	if err != nil {
+		return false, fmt.Errorf("error reading answer: %w", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/konstructio/colony/internal/k8s"
+	"github.com/konstructio/colony/internal/printer"
+	"github.com/konstructio/colony/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+// currentContext returns the colony context selected with --context,
+// COLONY_CONTEXT or `colony context use`.
+func currentContext(cmd *cobra.Command) (*workspace.Context, error) {
+	home, err := workspace.DefaultHome()
+	if err != nil {
+		return nil, fmt.Errorf("error finding colony home: %w", err)
+	}
+
+	var name string
+	if flag := cmd.Flag("context"); flag != nil {
+		name = flag.Value.String()
+	}
+
+	ctx, err := home.Resolve(name)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting colony context: %w", err)
+	}
+
+	return ctx, nil
+}
+
+func getContextCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "context",
+		Short: "manage the colony installations this workstation can reach",
+		Long: `manage the colony installations this workstation can reach
+
+each context holds the kubeconfig, saved init config and init state of one
+colony installation. the default context lives in ~/.colony (or
+COLONY_HOME), named contexts in its contexts directory.
+
+commands use the context given with --context, else COLONY_CONTEXT, else
+the one selected with "colony context use".`,
+	}
+
+	cmd.AddCommand(
+		getContextAddCommand(),
+		getContextListCommand(),
+		getContextUseCommand(),
+		getContextDeleteCommand())
+
+	return cmd
+}
+
+func getContextAddCommand() *cobra.Command {
+	var kubeconfig string
+	var use bool
+
+	cmd := &cobra.Command{
+		Use:   "add <name>",
+		Short: "add a colony context",
+		Long: `add a colony context
+
+run "colony init --context <name>" to install colony into it, or pass the
+kubeconfig of an installation made elsewhere with --kubeconfig.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			home, err := workspace.DefaultHome()
+			if err != nil {
+				return fmt.Errorf("error finding colony home: %w", err)
+			}
+
+			ctx, err := home.Add(args[0])
+			if err != nil {
+				return fmt.Errorf("error adding context: %w", err)
+			}
+
+			if kubeconfig != "" {
+				if err := k8s.CopyKubeconfig(kubeconfig, ctx.KubeconfigPath()); err != nil {
+					return fmt.Errorf("error importing kubeconfig: %w", err)
+				}
+			}
+
+			if use {
+				if err := home.Use(ctx.Name); err != nil {
+					return fmt.Errorf("error switching context: %w", err)
+				}
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "added context %q in %s\n", ctx.Name, ctx.Dir)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&kubeconfig, "kubeconfig", "", "kubeconfig of an existing colony installation to import")
+	cmd.Flags().BoolVar(&use, "use", false, "make the new context the current one")
+
+	return cmd
+}
+
+// contextInfo is a context as printed by `colony context list`.
+type contextInfo struct {
+	Name      string `json:"name"`
+	Current   bool   `json:"current"`
+	Installed bool   `json:"installed"`
+	Directory string `json:"directory"`
+}
+
+func getContextListCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "list the colony contexts",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			p, err := printer.New(output)
+			if err != nil {
+				return fmt.Errorf("error creating printer: %w", err)
+			}
+
+			home, err := workspace.DefaultHome()
+			if err != nil {
+				return fmt.Errorf("error finding colony home: %w", err)
+			}
+
+			current, err := home.Current()
+			if err != nil {
+				return fmt.Errorf("error reading current context: %w", err)
+			}
+
+			names, err := home.List()
+			if err != nil {
+				return fmt.Errorf("error listing contexts: %w", err)
+			}
+
+			contexts := make([]contextInfo, 0, len(names))
+			rows := printer.Rows{
+				Columns: []printer.Column{
+					{Name: "current"},
+					{Name: "name"},
+					{Name: "installed"},
+					{Name: "directory"},
+				},
+			}
+
+			for _, name := range names {
+				ctx, err := home.Get(name)
+				if err != nil {
+					return fmt.Errorf("error reading context: %w", err)
+				}
+
+				info := contextInfo{
+					Name:      name,
+					Current:   name == current,
+					Installed: ctx.Installed(),
+					Directory: ctx.Dir,
+				}
+				contexts = append(contexts, info)
+
+				row := map[string]string{
+					"current":   "",
+					"name":      name,
+					"installed": "no",
+					"directory": ctx.Dir,
+				}
+				if info.Current {
+					row["current"] = "*"
+				}
+				if info.Installed {
+					row["installed"] = "yes"
+				}
+				rows.Items = append(rows.Items, row)
+			}
+
+			if err := p.Print(cmd.OutOrStdout(), contexts, rows); err != nil {
+				return fmt.Errorf("error printing contexts: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	addOutputFlag(cmd, &output)
+
+	return cmd
+}
+
+func getContextUseCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "use <name>",
+		Short: "make a colony context the current one",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			home, err := workspace.DefaultHome()
+			if err != nil {
+				return fmt.Errorf("error finding colony home: %w", err)
+			}
+
+			if err := home.Use(args[0]); err != nil {
+				return fmt.Errorf("error switching context: %w", err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "switched to context %q\n", args[0])
+			return nil
+		},
+	}
+}
+
+func getContextDeleteCommand() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "delete <name>",
+		Short: "delete a colony context and its files",
+		Long: `delete a colony context and its files
+
+a context with colony installed must be destroyed with
+"colony destroy --context <name>" first, or deleted with --force, which
+leaves the installation running.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			home, err := workspace.DefaultHome()
+			if err != nil {
+				return fmt.Errorf("error finding colony home: %w", err)
+			}
+
+			ctx, err := home.Get(args[0])
+			if err != nil {
+				return fmt.Errorf("error deleting context: %w", err)
+			}
+
+			if ctx.Installed() && !force {
+				return fmt.Errorf("colony is installed in context %q, run `colony destroy --context %s` first or pass --force", ctx.Name, ctx.Name)
+			}
+
+			if err := home.Delete(ctx.Name); err != nil {
+				return fmt.Errorf("error deleting context: %w", err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "deleted context %q\n", ctx.Name)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "delete the context even if colony is installed in it")
+
+	return cmd
+}
@@ -2,12 +2,12 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"html/template"
-	"os"
-	"path/filepath"
 	"strings"
 
+	"github.com/konstructio/colony/internal/colony"
 	"github.com/konstructio/colony/internal/constants"
 	"github.com/konstructio/colony/internal/k8s"
 	"github.com/konstructio/colony/internal/logger"
@@ -30,16 +30,16 @@ func getDeprovisionCommand() *cobra.Command {
 		Short: "remove a hardware from your colony data center - very destructive",
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			ctx := cmd.Context()
-			log := logger.New(logger.Debug)
+			log := logger.FromContext(cmd.Context()).WithField(logger.FieldHardwareID, hardwareID)
 
 			randomSuffix := utils.RandomString(6)
 
-			homeDir, err := os.UserHomeDir()
+			colonyCtx, err := currentContext(cmd)
 			if err != nil {
-				return fmt.Errorf("error getting user home directory: %w", err)
+				return err
 			}
 
-			k8sClient, err := k8s.New(log, filepath.Join(homeDir, constants.ColonyDir, constants.KubeconfigHostPath))
+			k8sClient, err := k8s.New(log, colonyCtx.KubeconfigPath())
 			if err != nil {
 				return fmt.Errorf("failed to create k8s client: %w", err)
 			}
@@ -53,8 +53,6 @@ func getDeprovisionCommand() *cobra.Command {
 			log.Infof("efi boot %t", efiBoot)
 			log.Infof("destroy %t", destroy)
 
-			// todo
-			//! POST to api to mark the hardware removed
 			// get hardware and remove ipxe
 			hw, err := k8sClient.HardwareRemoveIPXE(ctx, k8s.UpdateHardwareRequest{
 				HardwareID: hardwareID,
@@ -66,142 +64,165 @@ func getDeprovisionCommand() *cobra.Command {
 			}
 			log.Infof("hardware: %v", hw)
 
-			ip, err := k8sClient.GetHardwareMachineRefFromSecretLabel(ctx, constants.ColonyNamespace, metav1.ListOptions{
-				LabelSelector: fmt.Sprintf("colony.konstruct.io/hardware-id=%s", hardwareID),
-			})
-			if err != nil {
-				return fmt.Errorf("error getting machine ref secret: %w", err)
+			if len(hw.Spec.Interfaces) == 0 || hw.Spec.Interfaces[0].DHCP == nil {
+				return fmt.Errorf("hardware %q has no dhcp interface", hardwareID)
 			}
+			mac := hw.Spec.Interfaces[0].DHCP.MAC
 
-			// TODO if the machine state is powered on, restart it so the workflow will run
-			// proactive reboot
-			file3, err := manifests.IPMI.ReadFile("ipmi/ipmi-off-pxe-on.yaml.tmpl")
-			if err != nil {
-				return fmt.Errorf("error reading templates file: %w", err)
-			}
+			// the api being down never fails the deprovision, the states
+			// are queued and sent later
+			reporter := newAssetReporter(ctx, log, colonyCtx, k8sClient)
+			reporter.report(ctx, hardwareID, mac, colony.AssetStateDeprovisioning, nil)
 
-			tmpl3, err := template.New("ipmi").Funcs(template.FuncMap{
-				"replaceDotsWithDash": func(s string) string {
-					return strings.ReplaceAll(s, ".", "-")
-				},
-			}).Parse(string(file3))
-			if err != nil {
-				return fmt.Errorf("error parsing template: %w", err)
+			if err := wipeHardware(ctx, log, k8sClient, hardwareID, mac, bootDevice, efiBoot, randomSuffix); err != nil {
+				reporter.report(ctx, hardwareID, mac, colony.AssetStateFailed, err)
+				return err
 			}
 
-			var outputBuffer3 bytes.Buffer
+			reporter.report(ctx, hardwareID, mac, colony.AssetStateRemoved, nil)
 
-			err = tmpl3.Execute(&outputBuffer3, RufioPowerCycleRequest{
-				IP:           ip,
-				EFIBoot:      efiBoot,
-				BootDevice:   bootDevice,
-				RandomSuffix: randomSuffix,
-			})
-			if err != nil {
-				return fmt.Errorf("error executing template: %w", err)
-			}
+			return nil
+		},
+	}
+	deprovisionCmd.Flags().StringVar(&hardwareID, "hardware-id", "", "hardware id of the server to deprovision - WARNING: you can not recover this server")
+	deprovisionCmd.Flags().StringVar(&bootDevice, "boot-device", "pxe", "the bootdev to set (pxe, bios) defaults to pxe")
+	deprovisionCmd.Flags().BoolVar(&efiBoot, "efiBoot", true, "boot device option (uefi, legacy) defaults to uefi")
+	deprovisionCmd.Flags().BoolVar(&destroy, "destroy", false, "whether to destroy the machine and its associated resources")
+	deprovisionCmd.MarkFlagRequired("hardware-id")
+	return deprovisionCmd
+}
 
-			log.Info(outputBuffer3.String())
+// wipeHardware reboots the hardware into the wipe disks workflow, waits for
+// it and reboots the hardware once the disks are wiped.
+func wipeHardware(ctx context.Context, log *logger.Logger, k8sClient *k8s.Client, hardwareID, mac, bootDevice string, efiBoot bool, randomSuffix string) error {
+	ip, err := k8sClient.GetHardwareMachineRefFromSecretLabel(ctx, constants.ColonyNamespace, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("colony.konstruct.io/hardware-id=%s", hardwareID),
+	})
+	if err != nil {
+		return fmt.Errorf("error getting machine ref secret: %w", err)
+	}
 
-			if err := k8sClient.ApplyManifests(ctx, []string{outputBuffer3.String()}); err != nil {
-				return fmt.Errorf("error applying rufiojob: %w", err)
-			}
+	// TODO if the machine state is powered on, restart it so the workflow will run
+	// proactive reboot
+	file3, err := manifests.IPMI.ReadFile("ipmi/ipmi-off-pxe-on.yaml.tmpl")
+	if err != nil {
+		return fmt.Errorf("error reading templates file: %w", err)
+	}
 
-			//! detokenize and apply the workflow
+	tmpl3, err := template.New("ipmi").Funcs(template.FuncMap{
+		"replaceDotsWithDash": func(s string) string {
+			return strings.ReplaceAll(s, ".", "-")
+		},
+	}).Parse(string(file3))
+	if err != nil {
+		return fmt.Errorf("error parsing template: %w", err)
+	}
 
-			file, err := manifests.Workflow.ReadFile("workflow/wipe-disks.yaml.tmpl")
-			if err != nil {
-				return fmt.Errorf("error reading templates file: %w", err)
-			}
+	var outputBuffer3 bytes.Buffer
 
-			tmpl, err := template.New("ipmi").Funcs(template.FuncMap{
-				"replaceColonsWithHyphens": func(s string) string {
-					return strings.ReplaceAll(s, ":", "-")
-				},
-			}).Parse(string(file))
-			if err != nil {
-				return fmt.Errorf("error parsing template: %w", err)
-			}
+	err = tmpl3.Execute(&outputBuffer3, RufioPowerCycleRequest{
+		IP:           ip,
+		EFIBoot:      efiBoot,
+		BootDevice:   bootDevice,
+		RandomSuffix: randomSuffix,
+	})
+	if err != nil {
+		return fmt.Errorf("error executing template: %w", err)
+	}
 
-			var outputBuffer bytes.Buffer
+	log.Info(outputBuffer3.String())
 
-			err = tmpl.Execute(&outputBuffer, DeprovisionWorkflowRequest{
-				Mac:          hw.Spec.Interfaces[0].DHCP.MAC,
-				RandomSuffix: randomSuffix,
-			})
-			if err != nil {
-				return fmt.Errorf("error executing template: %w", err)
-			}
+	if err := k8sClient.ApplyManifests(ctx, []string{outputBuffer3.String()}); err != nil {
+		return fmt.Errorf("error applying rufiojob: %w", err)
+	}
 
-			log.Info(outputBuffer.String())
+	//! detokenize and apply the workflow
 
-			//! NOT UNTIL WE'RE SURE
-			if err := k8sClient.ApplyManifests(ctx, []string{outputBuffer.String()}); err != nil {
-				return fmt.Errorf("error applying rufiojob: %w", err)
-			}
+	file, err := manifests.Workflow.ReadFile("workflow/wipe-disks.yaml.tmpl")
+	if err != nil {
+		return fmt.Errorf("error reading templates file: %w", err)
+	}
 
-			err = k8sClient.FetchAndWaitForWorkflow(ctx, k8s.WorkflowWaitRequest{
-				LabelValue:   strings.ReplaceAll(ip, ".", "-"),
-				Namespace:    constants.ColonyNamespace,
-				WaitTimeout:  480,
-				RandomSuffix: randomSuffix,
-			})
-			if err != nil {
-				return fmt.Errorf("error waiting for workflow: %w", err)
-			}
+	tmpl, err := template.New("ipmi").Funcs(template.FuncMap{
+		"replaceColonsWithHyphens": func(s string) string {
+			return strings.ReplaceAll(s, ":", "-")
+		},
+	}).Parse(string(file))
+	if err != nil {
+		return fmt.Errorf("error parsing template: %w", err)
+	}
 
-			// reboot
-			file2, err := manifests.IPMI.ReadFile("ipmi/ipmi-off-pxe-on.yaml.tmpl")
-			if err != nil {
-				return fmt.Errorf("error reading templates file: %w", err)
-			}
+	var outputBuffer bytes.Buffer
 
-			tmpl2, err := template.New("ipmi").Funcs(template.FuncMap{
-				"replaceDotsWithDash": func(s string) string {
-					return strings.ReplaceAll(s, ".", "-")
-				},
-			}).Parse(string(file2))
-			if err != nil {
-				return fmt.Errorf("error parsing template: %w", err)
-			}
+	err = tmpl.Execute(&outputBuffer, DeprovisionWorkflowRequest{
+		Mac:          mac,
+		RandomSuffix: randomSuffix,
+	})
+	if err != nil {
+		return fmt.Errorf("error executing template: %w", err)
+	}
 
-			var outputBuffer2 bytes.Buffer
+	log.Info(outputBuffer.String())
 
-			randomSuffix = utils.RandomString(6)
+	//! NOT UNTIL WE'RE SURE
+	if err := k8sClient.ApplyManifests(ctx, []string{outputBuffer.String()}); err != nil {
+		return fmt.Errorf("error applying rufiojob: %w", err)
+	}
 
-			err = tmpl2.Execute(&outputBuffer2, RufioPowerCycleRequest{
-				IP:           ip,
-				EFIBoot:      efiBoot,
-				BootDevice:   bootDevice,
-				RandomSuffix: randomSuffix,
-			})
-			if err != nil {
-				return fmt.Errorf("error executing template: %w", err)
-			}
+	err = k8sClient.FetchAndWaitForWorkflow(ctx, k8s.WorkflowWaitRequest{
+		LabelValue:   strings.ReplaceAll(ip, ".", "-"),
+		Namespace:    constants.ColonyNamespace,
+		WaitTimeout:  480,
+		RandomSuffix: randomSuffix,
+	})
+	if err != nil {
+		return fmt.Errorf("error waiting for workflow: %w", err)
+	}
 
-			log.Info(outputBuffer2.String())
+	// reboot
+	file2, err := manifests.IPMI.ReadFile("ipmi/ipmi-off-pxe-on.yaml.tmpl")
+	if err != nil {
+		return fmt.Errorf("error reading templates file: %w", err)
+	}
 
-			if err := k8sClient.ApplyManifests(ctx, []string{outputBuffer2.String()}); err != nil {
-				return fmt.Errorf("error applying rufiojob: %w", err)
-			}
+	tmpl2, err := template.New("ipmi").Funcs(template.FuncMap{
+		"replaceDotsWithDash": func(s string) string {
+			return strings.ReplaceAll(s, ".", "-")
+		},
+	}).Parse(string(file2))
+	if err != nil {
+		return fmt.Errorf("error parsing template: %w", err)
+	}
 
-			err = k8sClient.FetchAndWaitForRufioJobs(ctx, k8s.RufioJobWaitRequest{
-				LabelValue:   strings.ReplaceAll(ip, ".", "-"),
-				Namespace:    constants.ColonyNamespace,
-				WaitTimeout:  300,
-				RandomSuffix: randomSuffix,
-			})
-			if err != nil {
-				return fmt.Errorf("error get machine: %w", err)
-			}
+	var outputBuffer2 bytes.Buffer
 
-			return nil
-		},
+	randomSuffix = utils.RandomString(6)
+
+	err = tmpl2.Execute(&outputBuffer2, RufioPowerCycleRequest{
+		IP:           ip,
+		EFIBoot:      efiBoot,
+		BootDevice:   bootDevice,
+		RandomSuffix: randomSuffix,
+	})
+	if err != nil {
+		return fmt.Errorf("error executing template: %w", err)
 	}
-	deprovisionCmd.Flags().StringVar(&hardwareID, "hardware-id", "", "hardware id of the server to deprovision - WARNING: you can not recover this server")
-	deprovisionCmd.Flags().StringVar(&bootDevice, "boot-device", "pxe", "the bootdev to set (pxe, bios) defaults to pxe")
-	deprovisionCmd.Flags().BoolVar(&efiBoot, "efiBoot", true, "boot device option (uefi, legacy) defaults to uefi")
-	deprovisionCmd.Flags().BoolVar(&destroy, "destroy", false, "whether to destroy the machine and its associated resources")
-	deprovisionCmd.MarkFlagRequired("hardware-id")
-	return deprovisionCmd
+
+	log.Info(outputBuffer2.String())
+
+	if err := k8sClient.ApplyManifests(ctx, []string{outputBuffer2.String()}); err != nil {
+		return fmt.Errorf("error applying rufiojob: %w", err)
+	}
+
+	err = k8sClient.FetchAndWaitForRufioJobs(ctx, k8s.RufioJobWaitRequest{
+		LabelValue:   strings.ReplaceAll(ip, ".", "-"),
+		Namespace:    constants.ColonyNamespace,
+		WaitTimeout:  300,
+		RandomSuffix: randomSuffix,
+	})
+	if err != nil {
+		return fmt.Errorf("error get machine: %w", err)
+	}
+
+	return nil
 }
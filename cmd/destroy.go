@@ -1,72 +1,390 @@
 package cmd
 
 import (
+	"context"
+	"embed"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/konstructio/colony/internal/colony"
+	"github.com/konstructio/colony/internal/config"
 	"github.com/konstructio/colony/internal/constants"
-	"github.com/konstructio/colony/internal/docker"
+	"github.com/konstructio/colony/internal/container"
 	"github.com/konstructio/colony/internal/exec"
+	"github.com/konstructio/colony/internal/helm"
 	"github.com/konstructio/colony/internal/k8s"
 	"github.com/konstructio/colony/internal/logger"
+	"github.com/konstructio/colony/internal/workspace"
+	"github.com/konstructio/colony/manifests"
 	"github.com/spf13/cobra"
 )
 
 func getDestroyCommand() *cobra.Command {
+	var dryRun, localOnly, keepData, yes bool
+
 	cmd := &cobra.Command{
 		Use:   "destroy",
 		Short: "remove colony deployment from your host",
-		Long:  `remove colony deployment from your host`,
+		Long: `remove colony deployment from your host
+
+cleans the data center in the colony api, removes the colony k3s container
+and deletes the files of the colony context. colony installed into an existing cluster has its
+charts, secrets, templates and download jobs removed from that cluster
+instead of a container.
+
+missing pieces, like a container that was never created or an
+unreachable api, are skipped so a failed init can always be cleaned up.
+use --dry-run to list what would be removed.`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			ctx := cmd.Context()
-			log := logger.New(logger.Debug)
+			log := logger.FromContext(cmd.Context())
 
-			homeDir, err := os.UserHomeDir()
+			colonyCtx, err := currentContext(cmd)
 			if err != nil {
-				return fmt.Errorf("error getting user home directory: %w", err)
+				return err
 			}
 
-			kubeConfigPath := filepath.Join(homeDir, constants.ColonyDir, constants.KubeconfigHostPath)
-			if _, err := os.Stat(kubeConfigPath); os.IsNotExist(err) {
-				return fmt.Errorf("kubeconfig file not found at %s, cannot clean datacenter", kubeConfigPath)
+			d := &destroyer{
+				log:       log,
+				colonyCtx: colonyCtx,
+				localOnly: localOnly,
+				keepData:  keepData,
 			}
+			defer d.close()
 
-			k8sClient, err := k8s.New(log, kubeConfigPath)
-			if err != nil {
-				return fmt.Errorf("failed to create k8s client: %w", err)
+			actions := d.plan(ctx)
+
+			if len(actions) == 0 {
+				log.Info("nothing to remove, colony is not installed on this host")
+				return nil
 			}
 
-			agentConfig, err := k8sClient.GetAgentConfig(ctx)
-			if err != nil {
-				return fmt.Errorf("failed to get agent config from cluster: %w", err)
+			fmt.Fprintln(cmd.OutOrStdout(), "colony destroy will:")
+			for _, action := range actions {
+				fmt.Fprintf(cmd.OutOrStdout(), "  - %s\n", action.description)
 			}
 
-			log.Info("calling clean datacenter endpoint")
-			colonyAPI := colony.New(agentConfig.APIURL, agentConfig.APIKey)
-			if err := colonyAPI.CleanDatacenter(ctx, agentConfig.AgentID); err != nil {
-				return fmt.Errorf("failed to clean datacenter: %w", err)
+			if dryRun {
+				return nil
 			}
 
-			log.Info("creating docker client")
-			dockerCLI, err := docker.New(log)
-			if err != nil {
-				return fmt.Errorf("error creating docker client: %w", err)
+			if !yes {
+				ok, err := confirm(cmd, "proceed?")
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return errors.New("destroy aborted")
+				}
 			}
-			defer dockerCLI.Close()
 
-			if err := dockerCLI.RemoveColonyK3sContainer(ctx); err != nil {
-				return fmt.Errorf("error: failed to remove colony container %w", err)
+			var failed int
+			for _, action := range actions {
+				if err := action.run(ctx); err != nil {
+					log.Errorf("failed to %s: %s", action.description, err)
+					failed++
+				}
 			}
 
-			if err := exec.DeleteDirectory(filepath.Join(homeDir, constants.ColonyDir)); err != nil {
-				return fmt.Errorf("error: failed to delete kubeconfig file %w", err)
+			if failed > 0 {
+				return fmt.Errorf("%d of %d destroy steps failed, fix the errors above and rerun colony destroy", failed, len(actions))
 			}
 
 			log.Info("colony installation removed successfully")
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "list what would be removed without removing anything")
+	cmd.Flags().BoolVar(&localOnly, "local-only", false, "do not clean the data center in the colony api")
+	cmd.Flags().BoolVar(&keepData, "keep-data", false, "keep the config and backups in ~/.colony")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "do not ask for confirmation")
+
 	return cmd
 }
+
+// destroyAction is a single removal, planned only when there is
+// something to remove.
+type destroyAction struct {
+	description string
+	run         func(ctx context.Context) error
+}
+
+// destroyer plans and runs the removal of colony from this host.
+type destroyer struct {
+	log       *logger.Logger
+	colonyCtx *workspace.Context
+	localOnly bool
+	keepData  bool
+
+	cfg         *config.Config
+	k8sClient   *k8s.Client
+	runtime     container.Runtime
+	cloudFailed bool
+}
+
+func (d *destroyer) close() {
+	if d.runtime != nil {
+		d.runtime.Close()
+	}
+}
+
+// plan returns the actions needed to remove what is installed, logging
+// the pieces that are missing.
+func (d *destroyer) plan(ctx context.Context) []destroyAction {
+	var actions []destroyAction
+
+	cfg, err := config.Load(d.colonyCtx.ConfigPath())
+	if err != nil {
+		// installs made before the config was saved ran in docker
+		d.log.Warnf("no saved colony config: %s", err)
+		cfg = nil
+	}
+	d.cfg = cfg
+
+	if _, err := os.Stat(d.colonyCtx.KubeconfigPath()); err == nil {
+		d.k8sClient, err = k8s.New(d.log, d.colonyCtx.KubeconfigPath())
+		if err != nil {
+			d.log.Warnf("unable to create kubernetes client: %s", err)
+		}
+	} else {
+		d.log.Warnf("no kubeconfig found at %q", d.colonyCtx.KubeconfigPath())
+	}
+
+	if action, ok := d.planCloud(ctx); ok {
+		actions = append(actions, action)
+	}
+
+	if cfg != nil && cfg.External() {
+		if action, ok := d.planUninstall(); ok {
+			actions = append(actions, action)
+		}
+	} else if action, ok := d.planContainer(ctx); ok {
+		actions = append(actions, action)
+	}
+
+	if action, ok := d.planFiles(); ok {
+		actions = append(actions, action)
+	}
+
+	return actions
+}
+
+func (d *destroyer) planCloud(ctx context.Context) (destroyAction, bool) {
+	if d.localOnly {
+		d.log.Info("--local-only set, the data center will not be cleaned in the colony api")
+		return destroyAction{}, false
+	}
+
+	agentConfig := loadAgentConfig(ctx, d.log, d.k8sClient, d.cfg)
+	if agentConfig == nil {
+		d.log.Warn("no registered agent found, the data center will not be cleaned in the colony api")
+		return destroyAction{}, false
+	}
+
+	return destroyAction{
+		description: fmt.Sprintf("clean the data center of agent %s in the colony api at %s", agentConfig.AgentID, agentConfig.APIURL),
+		run: func(ctx context.Context) error {
+			// cleaning a large data center takes a while
+			colonyAPI, err := newColonyAPI(d.log, agentConfig, colony.WithTimeout(time.Minute))
+			if err != nil {
+				d.cloudFailed = true
+				return err
+			}
+			if err := colonyAPI.CleanDatacenter(ctx, agentConfig.AgentID); err != nil {
+				d.cloudFailed = true
+				return fmt.Errorf("failed to clean datacenter: %w", err)
+			}
+			return nil
+		},
+	}, true
+}
+
+func (d *destroyer) planUninstall() (destroyAction, bool) {
+	if d.k8sClient == nil {
+		d.log.Warn("no kubeconfig for the existing cluster, colony will not be uninstalled from it")
+		return destroyAction{}, false
+	}
+
+	inst := &installer{
+		cfg:            d.cfg,
+		log:            d.log,
+		k8sClient:      d.k8sClient,
+		bootstrapPath:  d.colonyCtx.BootstrapPath(),
+		kubeconfigPath: d.colonyCtx.KubeconfigPath(),
+	}
+
+	return destroyAction{
+		description: "uninstall the colony charts, secrets, templates and download jobs from the existing cluster",
+		run:         inst.uninstall,
+	}, true
+}
+
+func (d *destroyer) planContainer(ctx context.Context) (destroyAction, bool) {
+	cfg := d.cfg
+	if cfg == nil {
+		cfg = config.Default()
+	}
+
+	// the runtime is only set beforehand by tests
+	runtime := d.runtime
+	if runtime == nil {
+		var err error
+		runtime, err = newContainerRuntime(d.log, cfg)
+		if err != nil {
+			d.log.Warnf("unable to reach the container runtime, the container will not be removed: %s", err)
+			return destroyAction{}, false
+		}
+		d.runtime = runtime
+	}
+
+	k3scontainer, err := runtime.Get(ctx, constants.ColonyK3sContainerName)
+	if errors.Is(err, container.ErrNotFound) {
+		d.log.Infof("no %q container found", constants.ColonyK3sContainerName)
+		return destroyAction{}, false
+	}
+	if err != nil {
+		d.log.Warnf("unable to reach %s, the container will not be removed: %s", runtime.Name(), err)
+		return destroyAction{}, false
+	}
+
+	// every context shares the container name, the container of a
+	// context mounts its directory
+	if !mountsDir(k3scontainer, d.colonyCtx.Dir) {
+		d.log.Warnf("the %q container belongs to another colony context, it will not be removed", constants.ColonyK3sContainerName)
+		return destroyAction{}, false
+	}
+
+	k3s := container.NewK3s(d.log, runtime)
+
+	return destroyAction{
+		description: fmt.Sprintf("remove the %q %s container and its volumes", constants.ColonyK3sContainerName, runtime.Name()),
+		run: func(ctx context.Context) error {
+			if err := k3s.Remove(ctx); err != nil {
+				return fmt.Errorf("error: failed to remove colony container %w", err)
+			}
+			return nil
+		},
+	}, true
+}
+
+func (d *destroyer) planFiles() (destroyAction, bool) {
+	entries, err := d.colonyCtx.Entries()
+	if err != nil || len(entries) == 0 {
+		return destroyAction{}, false
+	}
+
+	description := fmt.Sprintf("delete the files in %s", d.colonyCtx.Dir)
+	if d.keepData {
+		description += ", keeping the config and backups"
+	}
+
+	return destroyAction{
+		description: description,
+		run: func(context.Context) error {
+			return d.removeFiles(entries)
+		},
+	}, true
+}
+
+// removeFiles deletes the files of the colony context. The config and
+// backups are kept with --keep-data, and when cleaning the data center
+// failed since the config holds the agent credentials needed to retry.
+// A kept config whose data center was cleaned no longer references the
+// agent, so the next init registers a new one.
+func (d *destroyer) removeFiles(entries []string) error {
+	keep := d.keepData || d.cloudFailed
+
+	for _, entry := range entries {
+		if keep && isColonyData(entry) {
+			continue
+		}
+
+		if err := exec.DeleteDirectory(filepath.Join(d.colonyCtx.Dir, entry)); err != nil {
+			return fmt.Errorf("error: failed to delete %s: %w", entry, err)
+		}
+	}
+
+	if !keep {
+		return nil
+	}
+
+	if d.cloudFailed {
+		d.log.Warnf("the data center was not cleaned, kept the config in %s to retry with colony destroy", d.colonyCtx.Dir)
+		return nil
+	}
+
+	if d.cfg != nil && !d.localOnly {
+		d.cfg.AgentID = ""
+		if err := d.cfg.Save(d.colonyCtx.ConfigPath()); err != nil {
+			return fmt.Errorf("error updating saved config: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func mountsDir(c *container.Container, dir string) bool {
+	for _, m := range c.Mounts {
+		if m.Type == container.MountBind && m.Source == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// isColonyData returns true for the files of the colony directory that
+// --keep-data preserves.
+func isColonyData(name string) bool {
+	return name == constants.ColonyConfigPath || name == "backups" || strings.HasSuffix(name, ".tar.gz")
+}
+
+// uninstall removes everything init installed into an existing cluster.
+// Templates are removed before the charts since the charts own their
+// CRDs.
+func (i *installer) uninstall(ctx context.Context) error {
+	if err := i.k8sClient.LoadMappingsFromKubernetes(); err != nil {
+		return fmt.Errorf("error loading dynamic mappings from kubernetes: %w", err)
+	}
+
+	for _, dir := range []struct {
+		fsys embed.FS
+		name string
+	}{
+		{manifests.Templates, "templates"},
+		{manifests.Downloads, "downloads"},
+	} {
+		contents, err := readEmbeddedManifests(dir.fsys, dir.name)
+		if err != nil {
+			return err
+		}
+
+		if err := i.k8sClient.DeleteManifests(ctx, contents); err != nil {
+			return fmt.Errorf("error deleting %s: %w", dir.name, err)
+		}
+	}
+
+	for _, name := range []string{constants.ColonyAPISecretName, constants.ColonyTokensSecretName, "mgmt-kubeconfig"} {
+		if err := i.k8sClient.DeleteSecret(ctx, constants.ColonyNamespace, name); err != nil {
+			return fmt.Errorf("error deleting secret: %w", err)
+		}
+	}
+
+	charts, err := i.renderedCharts()
+	if err != nil {
+		return err
+	}
+
+	helmClient := helm.New(i.log, i.kubeconfigPath)
+	for _, chart := range charts {
+		if err := helmClient.Uninstall(chart); err != nil {
+			return fmt.Errorf("error uninstalling colony charts: %w", err)
+		}
+	}
+
+	return nil
+}
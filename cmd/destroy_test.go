@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/konstructio/colony/internal/config"
+	"github.com/konstructio/colony/internal/constants"
+	"github.com/konstructio/colony/internal/container"
+	"github.com/konstructio/colony/internal/logger"
+	"github.com/konstructio/colony/internal/workspace"
+)
+
+func newTestDestroyer(t *testing.T, runtime *container.Fake) *destroyer {
+	t.Helper()
+
+	colonyCtx := &workspace.Context{Name: "test", Dir: t.TempDir()}
+
+	cfg := config.Default()
+	cfg.APIKey = "api-key"
+	if err := cfg.Save(colonyCtx.ConfigPath()); err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	return &destroyer{
+		log:       logger.NOOPLogger,
+		colonyCtx: colonyCtx,
+		localOnly: true,
+		runtime:   runtime,
+	}
+}
+
+func createK3sContainer(t *testing.T, runtime *container.Fake, dir string) {
+	t.Helper()
+
+	_, err := runtime.Create(context.Background(), container.Spec{
+		Name: constants.ColonyK3sContainerName,
+		Mounts: []container.Mount{
+			{Type: container.MountBind, Source: dir, Target: dir},
+			{Type: container.MountVolume, Source: "k3s-data", Target: "/var/lib/rancher/k3s"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+}
+
+func runActions(t *testing.T, actions []destroyAction) {
+	t.Helper()
+
+	for _, action := range actions {
+		if err := action.run(context.Background()); err != nil {
+			t.Fatalf("not expecting an error running %q but got: %s", action.description, err)
+		}
+	}
+}
+
+func Test_destroyer(t *testing.T) {
+	t.Run("partial install without container", func(tt *testing.T) {
+		runtime := container.NewFake()
+		d := newTestDestroyer(tt, runtime)
+		defer d.close()
+
+		actions := d.plan(context.Background())
+		if len(actions) != 1 {
+			tt.Fatalf("expected only the files to be removed but got %d actions", len(actions))
+		}
+
+		runActions(tt, actions)
+
+		entries, err := d.colonyCtx.Entries()
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+		if len(entries) != 0 {
+			tt.Fatalf("expected the context directory to be empty but got %v", entries)
+		}
+	})
+
+	t.Run("container of the context", func(tt *testing.T) {
+		runtime := container.NewFake()
+		d := newTestDestroyer(tt, runtime)
+		defer d.close()
+
+		createK3sContainer(tt, runtime, d.colonyCtx.Dir)
+
+		actions := d.plan(context.Background())
+		if len(actions) != 2 {
+			tt.Fatalf("expected the container and the files to be removed but got %d actions", len(actions))
+		}
+
+		runActions(tt, actions)
+
+		if len(runtime.Containers) != 0 {
+			tt.Fatalf("expected the container to be removed but %d are left", len(runtime.Containers))
+		}
+
+		if len(runtime.RemovedVolumes) != 1 || runtime.RemovedVolumes[0] != "k3s-data" {
+			tt.Fatalf("expected the k3s-data volume to be removed but got %v", runtime.RemovedVolumes)
+		}
+	})
+
+	t.Run("container of another context", func(tt *testing.T) {
+		runtime := container.NewFake()
+		d := newTestDestroyer(tt, runtime)
+		defer d.close()
+
+		createK3sContainer(tt, runtime, tt.TempDir())
+
+		actions := d.plan(context.Background())
+		if len(actions) != 1 {
+			tt.Fatalf("expected only the files to be removed but got %d actions", len(actions))
+		}
+
+		runActions(tt, actions)
+
+		if len(runtime.Containers) != 1 {
+			tt.Fatalf("expected the container of the other context to be kept")
+		}
+	})
+
+	t.Run("keep data", func(tt *testing.T) {
+		d := newTestDestroyer(tt, container.NewFake())
+		defer d.close()
+		d.keepData = true
+
+		if err := os.WriteFile(d.colonyCtx.KubeconfigPath(), []byte("not a kubeconfig"), 0o600); err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		runActions(tt, d.plan(context.Background()))
+
+		if _, err := os.Stat(d.colonyCtx.ConfigPath()); err != nil {
+			tt.Fatalf("expected the config to be kept but got: %s", err)
+		}
+
+		if _, err := os.Stat(filepath.Join(d.colonyCtx.Dir, constants.KubeconfigHostPath)); !os.IsNotExist(err) {
+			tt.Fatalf("expected the kubeconfig to be removed but got: %v", err)
+		}
+	})
+}
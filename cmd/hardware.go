@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/konstructio/colony/internal/bmc"
+	"github.com/konstructio/colony/internal/k8s"
+	"github.com/konstructio/colony/internal/logger"
+	"github.com/konstructio/colony/internal/printer"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+func getHardwareCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hardware",
+		Short: "manage the hardware of the data center",
+	}
+
+	cmd.AddCommand(getHardwareInventoryCommand())
+
+	return cmd
+}
+
+func getHardwareInventoryCommand() *cobra.Command {
+	var hardwareID, machine, bmcIP, output string
+
+	cmd := &cobra.Command{
+		Use:   "inventory",
+		Short: "show the hardware inventory read from the bmc of a server",
+		Long: `show the hardware inventory read from the bmc of a server
+
+the inventory lists the cpus, memory, drives, nics, power supplies and
+firmware versions of the server. it is collected when the bmc is added
+and again with colony hardware inventory refresh. -o json and -o yaml
+print every field reported by the bmc.
+
+servers not discovered with pxe yet have no hardware id, pick them by
+--machine or --bmc-ip instead.
+
+  colony hardware inventory --hardware-id hw-1
+  colony hardware inventory --bmc-ip 10.0.0.5`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			log := logger.FromContext(ctx)
+
+			p, err := printer.New(output)
+			if err != nil {
+				return fmt.Errorf("error creating printer: %w", err)
+			}
+
+			if bmcIP != "" {
+				machine = strings.ReplaceAll(bmcIP, ".", "-")
+			}
+
+			var k8sClient *k8s.Client
+			if hardwareID != "" {
+				var targets []k8s.PowerTarget
+				k8sClient, targets, err = listPowerTargets(cmd, hardwareID, "")
+				if err != nil {
+					return err
+				}
+				machine = targets[0].Machine
+			} else {
+				k8sClient, err = newBMCCredentialsClient(cmd)
+				if err != nil {
+					return err
+				}
+			}
+
+			inventory, err := k8sClient.GetInventory(ctx, machine)
+			if errors.Is(err, k8s.ErrInventoryNotFound) {
+				return fmt.Errorf("%w, collect it with colony hardware inventory refresh --selector colony.konstruct.io/name=%s", err, machine)
+			}
+			if err != nil {
+				return fmt.Errorf("error getting inventory: %w", err)
+			}
+
+			if p.IsTable() {
+				log.Infof("inventory of %s read from bmc %s %s ago", machine, inventory.BMCIP, duration.HumanDuration(time.Since(inventory.CollectedAt)))
+			}
+
+			if err := p.Print(cmd.OutOrStdout(), inventory, inventoryRows(inventory)); err != nil {
+				return fmt.Errorf("error printing inventory: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&hardwareID, "hardware-id", "", "hardware id of the server")
+	cmd.Flags().StringVar(&machine, "machine", "", "rufio machine of the server, also for servers without a hardware id")
+	cmd.Flags().StringVar(&bmcIP, "bmc-ip", "", "ip of the bmc of the server, also for servers without a hardware id")
+	cmd.MarkFlagsOneRequired("hardware-id", "machine", "bmc-ip")
+	cmd.MarkFlagsMutuallyExclusive("hardware-id", "machine", "bmc-ip")
+	addOutputFlag(cmd, &output)
+	cmd.AddCommand(getHardwareInventoryRefreshCommand())
+
+	return cmd
+}
+
+func getHardwareInventoryRefreshCommand() *cobra.Command {
+	var hardwareID, selector string
+	var concurrency int
+
+	cmd := &cobra.Command{
+		Use:   "refresh",
+		Short: "read the hardware inventory from the bmcs again",
+		Long: `read the hardware inventory from the bmcs again
+
+without --hardware-id or --selector the inventory of every bmc is read.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			log := logger.FromContext(ctx)
+
+			if concurrency < 1 {
+				return errors.New("--concurrency must be at least 1")
+			}
+
+			k8sClient, err := newBMCCredentialsClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			creds, err := k8sClient.ListBMCCredentials(ctx, credentialsSelector(hardwareID, selector))
+			if err != nil {
+				return fmt.Errorf("error listing bmc credentials: %w", err)
+			}
+
+			if len(creds) == 0 {
+				return errors.New("no bmc matches the selection")
+			}
+
+			var failed int
+			var mu sync.Mutex
+			sem := make(chan struct{}, concurrency)
+
+			var wg sync.WaitGroup
+			for _, cred := range creds {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					sem <- struct{}{}
+					defer func() { <-sem }()
+
+					bmcLog := log.WithFields(logger.Fields{
+						logger.FieldBMCIP:      cred.Host,
+						logger.FieldHardwareID: cred.HardwareID,
+					})
+
+					if err := refreshInventory(ctx, k8sClient, cred); err != nil {
+						bmcLog.Errorf("failed to refresh the inventory of %s: %s", cred.SecretName, err)
+						mu.Lock()
+						failed++
+						mu.Unlock()
+						return
+					}
+
+					bmcLog.Infof("refreshed the inventory of %s", cred.SecretName)
+				}()
+			}
+			wg.Wait()
+
+			if failed > 0 {
+				return fmt.Errorf("failed to refresh the inventory of %d of %d bmcs", failed, len(creds))
+			}
+
+			return nil
+		},
+	}
+
+	addCredentialsSelectorFlags(cmd, &hardwareID, &selector)
+	cmd.Flags().IntVar(&concurrency, "concurrency", 5, "number of bmcs read at once")
+
+	return cmd
+}
+
+func refreshInventory(ctx context.Context, k8sClient *k8s.Client, cred k8s.BMCCredentials) error {
+	if cred.Host == "" {
+		return errors.New("the bmc has no rufio machine")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, bmcConnectTimeout)
+	defer cancel()
+
+	device, err := bmc.ReadInventory(ctx, cred.Host, bmc.Credential{Username: cred.Username, Password: cred.Password})
+	if err != nil {
+		return fmt.Errorf("error reading inventory: %w", err)
+	}
+
+	if err := k8sClient.SaveInventory(ctx, cred.SecretName, cred.Host, device); err != nil {
+		return fmt.Errorf("error storing inventory: %w", err)
+	}
+
+	return nil
+}
+
+func inventoryRows(inventory *k8s.Inventory) printer.Rows {
+	components := bmc.Components(inventory.Device)
+
+	rows := printer.Rows{
+		Columns: []printer.Column{
+			{Name: "component"},
+			{Name: "id"},
+			{Name: "vendor"},
+			{Name: "model"},
+			{Name: "serial"},
+			{Name: "firmware"},
+			{Name: "details"},
+		},
+		Items: make([]map[string]string, 0, len(components)),
+	}
+
+	for _, c := range components {
+		rows.Items = append(rows.Items, map[string]string{
+			"component": c.Kind,
+			"id":        c.ID,
+			"vendor":    c.Vendor,
+			"model":     c.Model,
+			"serial":    c.Serial,
+			"firmware":  c.Firmware,
+			"details":   c.Details,
+		})
+	}
+
+	return rows
+}
@@ -1,26 +1,35 @@
 package cmd
 
 import (
-	"encoding/json"
+	"context"
+	"embed"
 	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/konstructio/colony/internal/colony"
+	"github.com/konstructio/colony/internal/config"
 	"github.com/konstructio/colony/internal/constants"
-	"github.com/konstructio/colony/internal/docker"
+	"github.com/konstructio/colony/internal/container"
 	"github.com/konstructio/colony/internal/exec"
+	"github.com/konstructio/colony/internal/helm"
 	"github.com/konstructio/colony/internal/k8s"
 	"github.com/konstructio/colony/internal/logger"
+	"github.com/konstructio/colony/internal/steps"
+	"github.com/konstructio/colony/internal/versions"
 	"github.com/konstructio/colony/manifests"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 type ColonyTokens struct {
@@ -29,300 +38,791 @@ type ColonyTokens struct {
 	DataCenterID          string
 	AgentID               string
 	ColonyAPIURL          string
-	GitlabToken           string
-	APIToken              string
-	DockerToken           string
 	CSEInstallerImage     string
+	Versions              *versions.Versions
 }
 
 func getInitCommand() *cobra.Command {
-	var dataCenterID, apiKey, agentID, apiURL, loadBalancerIP, loadBalancerInterface, gitlabToken, dockerToken, apiToken, cseInstallerImage string
+	var configFile string
+	var skipPreflight, resume bool
 
 	cmd := &cobra.Command{
 		Use:   "init",
 		Short: "initialize colony on your host to provision in your data center",
-		Long:  `initialize colony on your host to provision in your data center`,
+		Long: `initialize colony on your host to provision in your data center
+
+settings can be provided with a config file (-f), COLONY_* environment
+variables and flags, in increasing order of precedence. the resolved
+config is saved to ~/.colony/config.yaml (or the directory of the selected
+context) for later commands.
+
+the host checks from "colony preflight" run first, the colony api is
+only contacted once every check passes.
+
+by default colony runs in a k3s container managed by docker, pass
+--runtime=podman on hosts that only have podman. to install
+it into a cluster already running on this host instead, pass --kubeconfig
+or --runtime=external; the charts are then installed with helm, which must
+be on your PATH.
+
+init runs as a series of steps recorded in ~/.colony/init-state.json.
+if init fails part way, rerun it with --resume to skip the steps that
+already completed.`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			log := logger.New(logger.Debug)
+			log := logger.FromContext(cmd.Context())
 			ctx := cmd.Context()
 
-			// Check if an API key comes from the environment
-			if apiKey == "" {
-				apiKey = os.Getenv("COLONY_API_KEY")
-			}
-
-			dockerCLI, err := docker.New(log)
+			colonyCtx, err := currentContext(cmd)
 			if err != nil {
-				return fmt.Errorf("error creating docker client: %w", err)
+				return err
 			}
-			defer dockerCLI.Close()
 
-			containerExists, err := dockerCLI.CheckColonyK3sContainerExists(ctx)
+			savedConfigPath := colonyCtx.ConfigPath()
+
+			state, err := steps.Load(colonyCtx.InitStatePath())
 			if err != nil {
-				return fmt.Errorf("failed to check for running container: %w", err)
+				return fmt.Errorf("error loading init state: %w", err)
 			}
 
-			if containerExists {
-				return errors.New("container already exists. please remove before continuing or run `colony destroy`")
+			if state.Finished {
+				return errors.New("colony is already initialized on this host, run `colony destroy` to start over")
 			}
 
-			colonyAPI := colony.New(apiURL, apiKey)
-			if agentID == "" {
-				agent, err := colonyAPI.RegisterAgent(ctx, dataCenterID)
-				if err != nil {
-					if errors.Is(err, colony.ErrDataCenterAlreadyRegistered) {
-						return fmt.Errorf("data center %s already has an agent registered", dataCenterID)
-					}
-					return fmt.Errorf("error registering agent: %w", err)
-				}
-				agentID = agent.ID
+			if state.Started() && !resume {
+				return errors.New("a previous colony init did not complete, rerun with --resume to continue it or run `colony destroy` to start over")
 			}
 
-			if err := colonyAPI.Heartbeat(ctx, agentID); err != nil {
-				return fmt.Errorf("error sending heartbeat: %w", err)
+			// resuming reuses the config saved by the previous run, which
+			// holds the agent id, unless another config file is given
+			if resume && configFile == "" {
+				if _, err := os.Stat(savedConfigPath); err == nil {
+					configFile = savedConfigPath
+				}
 			}
 
-			log.Info("agent registered successfully")
-
-			homeDir, err := os.UserHomeDir()
+			cfg, err := loadInitConfig(cmd, configFile)
 			if err != nil {
-				return fmt.Errorf("error getting user home directory: %w", err)
+				return err
 			}
 
-			err = exec.CreateDirIfNotExist(filepath.Join(homeDir, constants.ColonyDir, "k3s-bootstrap"))
-			if err != nil {
-				return fmt.Errorf("error creating directory templates: %w", err)
+			if resume && cfg.AgentID == "" {
+				if saved, err := config.Load(savedConfigPath); err == nil {
+					cfg.AgentID = saved.AgentID
+				}
 			}
 
-			colonyYamlTmpl, err := manifests.Colony.ReadFile(fmt.Sprintf("colony/%s.tmpl", constants.ColonyYamlPath))
-			if err != nil {
-				return fmt.Errorf("error reading templates file: %w", err)
+			if cfg.External() {
+				if err := resolveExternalKubeconfig(cfg); err != nil {
+					return err
+				}
 			}
 
-			tmpl, err := template.New("colony").Parse(string(colonyYamlTmpl))
-			if err != nil {
-				return fmt.Errorf("error parsing template: %w", err)
+			var (
+				runtime container.Runtime
+				k3s     *container.K3s
+			)
+			if !cfg.External() {
+				runtime, err = newContainerRuntime(log, cfg)
+				if err != nil {
+					return err
+				}
+				defer runtime.Close()
+				k3s = container.NewK3s(log, runtime)
 			}
 
-			colonyK3sBootstrapPath := filepath.Join(homeDir, constants.ColonyDir, "k3s-bootstrap", constants.ColonyYamlPath)
-			colonyKubeconfigPath := filepath.Join(homeDir, constants.ColonyDir, constants.KubeconfigHostPath)
+			if !state.Started() {
+				if k3s != nil {
+					containerExists, err := k3s.Exists(ctx)
+					if err != nil {
+						return fmt.Errorf("failed to check for running container: %w", err)
+					}
 
-			outputFile, err := os.Create(colonyK3sBootstrapPath)
-			if err != nil {
-				return fmt.Errorf("error creating output file: %w", err)
-			}
-			defer outputFile.Close()
-
-			err = tmpl.Execute(outputFile, &ColonyTokens{
-				LoadBalancerIP:        loadBalancerIP,
-				LoadBalancerInterface: loadBalancerInterface,
-				DataCenterID:          dataCenterID,
-				AgentID:               agentID,
-				ColonyAPIURL:          apiURL,
-				GitlabToken:           gitlabToken,
-				APIToken:              apiToken,
-				DockerToken:           dockerToken,
-				CSEInstallerImage:     cseInstallerImage,
-			})
-			if err != nil {
-				return fmt.Errorf("error executing template: %w", err)
-			}
+					if containerExists {
+						return errors.New("container already exists. please remove before continuing or run `colony destroy`")
+					}
+				}
 
-			err = dockerCLI.CreateColonyK3sContainer(ctx, colonyK3sBootstrapPath, colonyKubeconfigPath, homeDir)
-			if err != nil {
-				return fmt.Errorf("error creating container: %w", err)
+				if skipPreflight {
+					log.Warn("skipping preflight checks")
+				} else if err := runPreflight(ctx, cfg, runtime, colonyCtx.Dir); err != nil {
+					return fmt.Errorf("%w, fix the failures above or rerun with --skip-preflight", err)
+				}
 			}
 
-			k8sClient, err := k8s.New(log, colonyKubeconfigPath)
+			target, err := versions.Target()
 			if err != nil {
-				return fmt.Errorf("error creating Kubernetes client: %w", err)
+				return fmt.Errorf("error reading target versions: %w", err)
 			}
 
-			if err := k8sClient.WaitForKubernetesAPIHealthy(ctx, 5*time.Minute); err != nil {
-				return fmt.Errorf("error waiting for kubernetes api to be healthy: %w", err)
+			inst := &installer{
+				cfg:            cfg,
+				log:            log,
+				k3s:            k3s,
+				versions:       target,
+				colonyDir:      colonyCtx.Dir,
+				configPath:     savedConfigPath,
+				bootstrapPath:  colonyCtx.BootstrapPath(),
+				kubeconfigPath: colonyCtx.KubeconfigPath(),
+				versionsPath:   colonyCtx.VersionsPath(),
 			}
 
-			if err := k8sClient.FetchAndWaitForDeployments(ctx, k8s.DeploymentDetails{
-				Label:     "kubernetes.io/name",
-				Value:     "CoreDNS",
-				Namespace: "kube-system",
-			}); err != nil {
-				return fmt.Errorf("error waiting for coredns deployment: %w", err)
+			if err := steps.Run(ctx, log, state, inst.steps()); err != nil {
+				return fmt.Errorf("%w, rerun with --resume to continue", err)
 			}
 
-			// Create the secret
-			apiKeySecret := &corev1.Secret{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      constants.ColonyAPISecretName,
-					Namespace: constants.ColonyNamespace,
-				},
-				Data: map[string][]byte{
-					"api-key":  []byte(apiKey),
-					"api-url":  []byte(apiURL),
-					"agent-id": []byte(agentID),
-				},
-			}
+			log.Info("colony init completed successfully")
 
-			// Create a secret in the cluster
-			if err := k8sClient.CreateSecret(ctx, apiKeySecret); err != nil {
-				return fmt.Errorf("error creating secret: %w", err)
-			}
+			return nil
+		},
+	}
 
-			k8sconfig, err := os.ReadFile(colonyKubeconfigPath)
-			if err != nil {
-				return fmt.Errorf("error reading file: %w", err)
-			}
+	cmd.Flags().StringVarP(&configFile, "file", "f", "", "path to a colony init config file")
+	cmd.Flags().BoolVar(&skipPreflight, "skip-preflight", false, "skip the host checks run before colony is installed")
+	cmd.Flags().BoolVar(&resume, "resume", false, "resume a previous init, skipping the steps that already completed")
+	cmd.Flags().String("api-key", "", "api key for interacting with colony cloud")
+	cmd.Flags().String("data-center-id", "", "data center id for interacting with colony cloud")
+	cmd.Flags().String("agent-id", "", "agent id for interacting with colony cloud")
+	cmd.Flags().String("api-url", config.DefaultAPIURL, "api url for interacting with colony cloud")
+	cmd.Flags().String("api-ca-file", "", "path to the pem encoded ca that signed the colony api certificate")
+	cmd.Flags().String("api-client-cert", "", "path to the pem encoded client certificate presented to the colony api")
+	cmd.Flags().String("api-client-key", "", "path to the pem encoded key of --api-client-cert")
+	cmd.Flags().Bool("api-insecure", false, "do not verify the colony api certificate")
+	cmd.Flags().String("load-balancer-interface", "", "the local network interface for colony to use")
+	cmd.Flags().String("load-balancer-ip", "", "the local ip address for colony to use")
+	cmd.Flags().String("api-token", "", "API-go token")
+	cmd.Flags().String("gitlab-token", "", "Gitlab token")
+	cmd.Flags().String("docker-token", "", "Docker token")
+	addTokenSourceFlags(cmd)
+	cmd.Flags().String("cse-installer-image", config.DefaultCSEInstallerImage, "cse-installer image location")
+	cmd.Flags().String("runtime", config.RuntimeDocker, "where colony runs, one of: "+strings.Join(config.Runtimes, ", "))
+	cmd.Flags().String("kubeconfig", "", "install colony into the existing cluster of this kubeconfig, implies --runtime=external")
 
-			mgmtKubeConfigSecret := &corev1.Secret{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      "mgmt-kubeconfig",
-					Namespace: constants.ColonyNamespace,
-				},
-				Data: map[string][]byte{
-					"kubeconfig": k8sconfig,
-				},
-			}
+	return cmd
+}
 
-			// Create a secret in the cluster
-			if err := k8sClient.CreateSecret(ctx, mgmtKubeConfigSecret); err != nil {
-				return fmt.Errorf("error creating secret: %w", err)
-			}
+// coreDNSDeployment is the first deployment to come up in the cluster.
+var coreDNSDeployment = k8s.DeploymentDetails{
+	Label:     "kubernetes.io/name",
+	Value:     "CoreDNS",
+	Namespace: "kube-system",
+}
 
-			deploymentsToWaitFor := []k8s.DeploymentDetails{
-				{
-					Label:     "k8s-app",
-					Value:     "metrics-server",
-					Namespace: "kube-system",
-				},
-				{
-					Label:     "app.kubernetes.io/name",
-					Value:     "colony-agent",
-					Namespace: constants.ColonyNamespace,
-				},
-				{
-					Label:     "app",
-					Value:     "hegel",
-					Namespace: constants.ColonyNamespace,
-				},
-				{
-					Label:     "app",
-					Value:     "rufio",
-					Namespace: constants.ColonyNamespace,
-				},
-				{
-					Label:     "app",
-					Value:     "smee",
-					Namespace: constants.ColonyNamespace,
-				},
-				{
-					Label:     "app",
-					Value:     "tink-server",
-					Namespace: constants.ColonyNamespace,
-				},
-				{
-					Label:       "app",
-					Value:       "tink-controller",
-					Namespace:   constants.ColonyNamespace,
-					ReadTimeout: 180,
-					WaitTimeout: 120,
-				},
-			}
+// metricsServerDeployment is bundled with k3s.
+var metricsServerDeployment = k8s.DeploymentDetails{
+	Label:     "k8s-app",
+	Value:     "metrics-server",
+	Namespace: "kube-system",
+}
 
-			if err := k8sClient.FetchAndWaitForDeployments(ctx, deploymentsToWaitFor...); err != nil {
-				return fmt.Errorf("error waiting for deployment: %w", err)
-			}
+// colonyDeployments are the deployments installed by the colony charts.
+var colonyDeployments = []k8s.DeploymentDetails{
+	{
+		Label:     "app.kubernetes.io/name",
+		Value:     "colony-agent",
+		Namespace: constants.ColonyNamespace,
+	},
+	{
+		Label:     "app",
+		Value:     "hegel",
+		Namespace: constants.ColonyNamespace,
+	},
+	{
+		Label:     "app",
+		Value:     "rufio",
+		Namespace: constants.ColonyNamespace,
+	},
+	{
+		Label:     "app",
+		Value:     "smee",
+		Namespace: constants.ColonyNamespace,
+	},
+	{
+		Label:     "app",
+		Value:     "tink-server",
+		Namespace: constants.ColonyNamespace,
+	},
+	{
+		Label:       "app",
+		Value:       "tink-controller",
+		Namespace:   constants.ColonyNamespace,
+		ReadTimeout: 180,
+		WaitTimeout: 120,
+	},
+}
 
-			if err := k8sClient.LoadMappingsFromKubernetes(); err != nil {
-				return fmt.Errorf("error loading dynamic mappings from kubernetes: %w", err)
-			}
+// installer holds the state shared by the init steps. Every step must
+// be safe to run again after a partial failure.
+type installer struct {
+	cfg            *config.Config
+	log            *logger.Logger
+	k3s            *container.K3s
+	k8sClient      *k8s.Client
+	versions       *versions.Versions
+	colonyDir      string
+	configPath     string
+	bootstrapPath  string
+	kubeconfigPath string
+	versionsPath   string
+
+	// apiTLS overrides the TLS settings of cfg, set when they were
+	// recovered from the cluster instead of the config file
+	apiTLS *colony.TLS
+}
 
-			log.Info("applying tinkerbell templates")
-			colonyTemplates, err := manifests.Templates.ReadDir("templates")
-			if err != nil {
-				return fmt.Errorf("error reading templates: %w", err)
-			}
-			var manifestsFiles []string
+func (i *installer) steps() []steps.Step {
+	if i.cfg.External() {
+		return []steps.Step{
+			{Name: "register-agent", Run: i.registerAgent},
+			{Name: "render-colony-yaml", Run: i.renderColonyYaml},
+			{Name: "copy-kubeconfig", Run: i.copyKubeconfig},
+			{Name: "wait-for-api", Run: i.waitForAPI},
+			{Name: "install-charts", Run: i.installCharts},
+			{Name: "create-secrets", Run: i.createSecrets},
+			{Name: "wait-for-deployments", Run: i.waitForDeployments},
+			{Name: "apply-templates", Run: i.applyTemplates},
+			{Name: "apply-downloads", Run: i.applyDownloads},
+			{Name: "patch-smee-clusterrole", Run: i.patchSmeeClusterRole},
+			{Name: "record-versions", Run: i.recordVersions},
+		}
+	}
 
-			for _, file := range colonyTemplates {
-				content, err := manifests.Templates.ReadFile(filepath.Join("templates", file.Name()))
-				if err != nil {
-					return fmt.Errorf("error reading templates file: %w", err)
-				}
-				manifestsFiles = append(manifestsFiles, string(content))
-			}
+	return []steps.Step{
+		{Name: "register-agent", Run: i.registerAgent},
+		{Name: "render-colony-yaml", Run: i.renderColonyYaml},
+		{Name: "create-container", Run: i.createContainer},
+		{Name: "wait-for-api", Run: i.waitForAPI},
+		{Name: "create-secrets", Run: i.createSecrets},
+		{Name: "wait-for-deployments", Run: i.waitForDeployments},
+		{Name: "apply-templates", Run: i.applyTemplates},
+		{Name: "apply-downloads", Run: i.applyDownloads},
+		{Name: "patch-smee-clusterrole", Run: i.patchSmeeClusterRole},
+		{Name: "record-versions", Run: i.recordVersions},
+	}
+}
 
-			if err := k8sClient.ApplyManifests(ctx, manifestsFiles); err != nil {
-				return fmt.Errorf("error applying templates: %w", err)
-			}
+// tls returns the colony api TLS settings to store in the colony-api
+// secret.
+func (i *installer) tls() (colony.TLS, error) {
+	if i.apiTLS != nil {
+		return *i.apiTLS, nil
+	}
+	return configTLS(i.cfg)
+}
 
-			log.Info("downloading operating systems for hook")
+// kube returns the kubernetes client, creating it on first use since
+// the kubeconfig only exists once the container is running.
+func (i *installer) kube() (*k8s.Client, error) {
+	if i.k8sClient != nil {
+		return i.k8sClient, nil
+	}
 
-			downloadTemplates, err := manifests.Downloads.ReadDir("downloads")
-			if err != nil {
-				return fmt.Errorf("error reading templates: %w", err)
-			}
+	k8sClient, err := k8s.New(i.log, i.kubeconfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Kubernetes client: %w", err)
+	}
 
-			var downloadFiles []string
+	i.k8sClient = k8sClient
+	return k8sClient, nil
+}
 
-			for _, file := range downloadTemplates {
-				content, err := manifests.Downloads.ReadFile(filepath.Join("downloads", file.Name()))
-				if err != nil {
-					return fmt.Errorf("error reading templates file: %w", err)
-				}
-				downloadFiles = append(downloadFiles, string(content))
-			}
+func (i *installer) registerAgent(ctx context.Context) error {
+	apiTLS, err := configTLS(i.cfg)
+	if err != nil {
+		return err
+	}
+
+	colonyAPI, err := newColonyAPI(i.log, &k8s.AgentConfig{APIURL: i.cfg.APIURL, APIKey: i.cfg.APIKey, TLS: apiTLS})
+	if err != nil {
+		return err
+	}
 
-			if err := k8sClient.ApplyManifests(ctx, downloadFiles); err != nil {
-				return fmt.Errorf("error applying templates: %w", err)
+	if i.cfg.AgentID == "" {
+		agent, err := colonyAPI.RegisterAgent(ctx, i.cfg.DataCenterID)
+		if err != nil {
+			if errors.Is(err, colony.ErrDataCenterAlreadyRegistered) {
+				return fmt.Errorf("data center %s already has an agent registered", i.cfg.DataCenterID)
 			}
+			return fmt.Errorf("error registering agent: %w", err)
+		}
+		i.cfg.AgentID = agent.ID
+	}
+
+	// save the agent id right away so a resumed init never registers twice
+	if err := i.cfg.Save(i.configPath); err != nil {
+		return fmt.Errorf("error saving colony config: %w", err)
+	}
+
+	if err := colonyAPI.Heartbeat(ctx, i.cfg.AgentID, colony.State{Status: colony.StatusInitializing}); err != nil {
+		return fmt.Errorf("error sending heartbeat: %w", err)
+	}
+
+	i.log.Info("agent registered successfully")
+
+	return nil
+}
+
+func (i *installer) renderColonyYaml(_ context.Context) error {
+	err := exec.CreateDirIfNotExist(filepath.Dir(i.bootstrapPath))
+	if err != nil {
+		return fmt.Errorf("error creating directory templates: %w", err)
+	}
+
+	colonyYamlTmpl, err := manifests.Colony.ReadFile(fmt.Sprintf("colony/%s.tmpl", constants.ColonyYamlPath))
+	if err != nil {
+		return fmt.Errorf("error reading templates file: %w", err)
+	}
+
+	tmpl, err := template.New("colony").Parse(string(colonyYamlTmpl))
+	if err != nil {
+		return fmt.Errorf("error parsing template: %w", err)
+	}
+
+	outputFile, err := os.Create(i.bootstrapPath)
+	if err != nil {
+		return fmt.Errorf("error creating output file: %w", err)
+	}
+	defer outputFile.Close()
+
+	err = tmpl.Execute(outputFile, &ColonyTokens{
+		LoadBalancerIP:        i.cfg.LoadBalancer.IP,
+		LoadBalancerInterface: i.cfg.LoadBalancer.Interface,
+		DataCenterID:          i.cfg.DataCenterID,
+		AgentID:               i.cfg.AgentID,
+		ColonyAPIURL:          i.cfg.APIURL,
+		CSEInstallerImage:     i.cfg.CSEInstallerImage,
+		Versions:              i.versions,
+	})
+	if err != nil {
+		return fmt.Errorf("error executing template: %w", err)
+	}
+
+	return nil
+}
+
+func (i *installer) createContainer(ctx context.Context) error {
+	containerExists, err := i.k3s.Exists(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check for running container: %w", err)
+	}
+
+	if containerExists {
+		i.log.Infof("%q container already exists, skipping", constants.ColonyK3sContainerName)
+		return nil
+	}
+
+	if err := i.k3s.Create(ctx, i.versions.K3sImage, i.bootstrapPath, i.kubeconfigPath, i.colonyDir); err != nil {
+		return fmt.Errorf("error creating container: %w", err)
+	}
+
+	return nil
+}
+
+func (i *installer) waitForAPI(ctx context.Context) error {
+	k8sClient, err := i.kube()
+	if err != nil {
+		return err
+	}
+
+	if err := k8sClient.WaitForKubernetesAPIHealthy(ctx, 5*time.Minute); err != nil {
+		return fmt.Errorf("error waiting for kubernetes api to be healthy: %w", err)
+	}
+
+	// an existing cluster may run its dns under another name
+	if i.cfg.External() {
+		return nil
+	}
+
+	if err := k8sClient.FetchAndWaitForDeployments(ctx, coreDNSDeployment); err != nil {
+		return fmt.Errorf("error waiting for coredns deployment: %w", err)
+	}
+
+	return nil
+}
+
+// copyKubeconfig copies the kubeconfig of the existing cluster to the
+// colony directory, where every other command reads it from.
+func (i *installer) copyKubeconfig(_ context.Context) error {
+	if err := k8s.CopyKubeconfig(i.cfg.Kubeconfig, i.kubeconfigPath); err != nil {
+		return fmt.Errorf("error copying kubeconfig: %w", err)
+	}
+
+	return nil
+}
+
+// installCharts installs the rendered HelmCharts with helm, since only
+// k3s runs a helm controller that installs them on its own.
+func (i *installer) installCharts(_ context.Context) error {
+	charts, err := i.renderedCharts()
+	if err != nil {
+		return err
+	}
+
+	helmClient := helm.New(i.log, i.kubeconfigPath)
+	for _, chart := range charts {
+		if err := helmClient.Install(chart); err != nil {
+			return fmt.Errorf("error installing colony charts: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// renderedManifests returns the documents of the rendered colony.yaml.
+func (i *installer) renderedManifests() ([]string, error) {
+	return readManifests(i.bootstrapPath)
+}
+
+// readManifests returns the documents of the multi-document yaml file at
+// path.
+func readManifests(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %q: %w", path, err)
+	}
+
+	var docs []string
+	for _, doc := range strings.Split(string(content), "\n---\n") {
+		if strings.TrimSpace(doc) != "" {
+			docs = append(docs, doc)
+		}
+	}
 
-			clusterRoleName := "smee-role"
+	return docs, nil
+}
+
+// renderedCharts returns the charts of the HelmCharts in the rendered
+// colony.yaml.
+func (i *installer) renderedCharts() ([]helm.Chart, error) {
+	docs, err := i.renderedManifests()
+	if err != nil {
+		return nil, err
+	}
+
+	charts, err := helm.ChartsFromManifests(docs)
+	if err != nil {
+		return nil, fmt.Errorf("error reading HelmCharts from %q: %w", i.bootstrapPath, err)
+	}
+
+	return charts, nil
+}
+
+func (i *installer) createSecrets(ctx context.Context) error {
+	k8sClient, err := i.kube()
+	if err != nil {
+		return err
+	}
+
+	apiTLS, err := i.tls()
+	if err != nil {
+		return err
+	}
+
+	apiKeySecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      constants.ColonyAPISecretName,
+			Namespace: constants.ColonyNamespace,
+		},
+		Data: map[string][]byte{
+			"api-key":  []byte(i.cfg.APIKey),
+			"api-url":  []byte(i.cfg.APIURL),
+			"agent-id": []byte(i.cfg.AgentID),
+		},
+	}
+
+	// the certificates are stored so destroy and the agent reach the api
+	// the same way init did
+	for key, value := range map[string][]byte{
+		k8s.SecretKeyCA:      apiTLS.CA,
+		k8s.SecretKeyTLSCert: apiTLS.ClientCert,
+		k8s.SecretKeyTLSKey:  apiTLS.ClientKey,
+	} {
+		if len(value) > 0 {
+			apiKeySecret.Data[key] = value
+		}
+	}
+
+	if apiTLS.Insecure {
+		apiKeySecret.Data[k8s.SecretKeyInsecure] = []byte("true")
+	}
+
+	if err := k8sClient.ApplySecret(ctx, apiKeySecret); err != nil {
+		return fmt.Errorf("error creating secret: %w", err)
+	}
+
+	tokensSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      constants.ColonyTokensSecretName,
+			Namespace: constants.ColonyNamespace,
+		},
+		Data: map[string][]byte{
+			"apigo-token":  []byte(i.cfg.Tokens.API),
+			"gitlab-token": []byte(i.cfg.Tokens.Gitlab),
+			"docker-token": []byte(i.cfg.Tokens.Docker),
+		},
+	}
+
+	if err := k8sClient.ApplySecret(ctx, tokensSecret); err != nil {
+		return fmt.Errorf("error creating secret: %w", err)
+	}
+
+	k8sconfig, err := os.ReadFile(i.kubeconfigPath)
+	if err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+
+	mgmtKubeConfigSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mgmt-kubeconfig",
+			Namespace: constants.ColonyNamespace,
+		},
+		Data: map[string][]byte{
+			"kubeconfig": k8sconfig,
+		},
+	}
+
+	if err := k8sClient.ApplySecret(ctx, mgmtKubeConfigSecret); err != nil {
+		return fmt.Errorf("error creating secret: %w", err)
+	}
+
+	return nil
+}
+
+func (i *installer) waitForDeployments(ctx context.Context) error {
+	k8sClient, err := i.kube()
+	if err != nil {
+		return err
+	}
+
+	if err := k8sClient.FetchAndWaitForDeployments(ctx, i.deployments()...); err != nil {
+		return fmt.Errorf("error waiting for deployment: %w", err)
+	}
+
+	return nil
+}
+
+// deployments returns the deployments a healthy colony runs.
+func (i *installer) deployments() []k8s.DeploymentDetails {
+	return colonyRuntimeDeployments(i.cfg)
+}
+
+func (i *installer) applyTemplates(ctx context.Context) error {
+	k8sClient, err := i.kube()
+	if err != nil {
+		return err
+	}
+
+	if err := k8sClient.LoadMappingsFromKubernetes(); err != nil {
+		return fmt.Errorf("error loading dynamic mappings from kubernetes: %w", err)
+	}
+
+	i.log.Info("applying tinkerbell templates")
+	manifestsFiles, err := readEmbeddedManifests(manifests.Templates, "templates")
+	if err != nil {
+		return err
+	}
+
+	if err := k8sClient.ApplyManifests(ctx, manifestsFiles); err != nil {
+		return fmt.Errorf("error applying templates: %w", err)
+	}
+
+	return nil
+}
+
+func (i *installer) applyDownloads(ctx context.Context) error {
+	k8sClient, err := i.kube()
+	if err != nil {
+		return err
+	}
+
+	if err := k8sClient.LoadMappingsFromKubernetes(); err != nil {
+		return fmt.Errorf("error loading dynamic mappings from kubernetes: %w", err)
+	}
+
+	i.log.Info("downloading operating systems for hook")
+
+	downloadFiles, err := readEmbeddedManifests(manifests.Downloads, "downloads")
+	if err != nil {
+		return err
+	}
+
+	// download jobs can't be updated in place, leave existing ones alone
+	if err := k8sClient.CreateManifests(ctx, downloadFiles); err != nil {
+		return fmt.Errorf("error applying templates: %w", err)
+	}
+
+	return nil
+}
+
+func (i *installer) patchSmeeClusterRole(ctx context.Context) error {
+	k8sClient, err := i.kube()
+	if err != nil {
+		return err
+	}
+
+	err = k8sClient.AddClusterRoleRule(ctx, "smee-role", rbacv1.PolicyRule{
+		APIGroups: []string{"tinkerbell.org"},
+		Resources: []string{"hardware", "hardware/status"},
+		Verbs:     []string{"create", "update"},
+	})
+	if err != nil {
+		return fmt.Errorf("error patching ClusterRole: %w", err)
+	}
+
+	return nil
+}
+
+func (i *installer) recordVersions(_ context.Context) error {
+	if err := i.versions.Save(i.versionsPath); err != nil {
+		return fmt.Errorf("error recording installed versions: %w", err)
+	}
 
-			patch := []map[string]interface{}{{
-				"op":   "add",
-				"path": "/rules/-",
-				"value": rbacv1.PolicyRule{
-					APIGroups: []string{"tinkerbell.org"},
-					Resources: []string{"hardware", "hardware/status"},
-					Verbs:     []string{"create", "update"},
-				},
-			}}
+	return nil
+}
 
-			// Convert patch to JSON
-			patchBytes, err := json.Marshal(patch)
+// colonyRuntimeDeployments returns the deployments a healthy colony runs
+// for the runtime in cfg.
+func colonyRuntimeDeployments(cfg *config.Config) []k8s.DeploymentDetails {
+	if cfg.External() {
+		return colonyDeployments
+	}
+	return append([]k8s.DeploymentDetails{metricsServerDeployment}, colonyDeployments...)
+}
+
+// resolveExternalKubeconfig sets the runtime to external and the
+// kubeconfig to an absolute path, defaulting to the one kubectl uses.
+func resolveExternalKubeconfig(cfg *config.Config) error {
+	cfg.Runtime = config.RuntimeExternal
+
+	if cfg.Kubeconfig == "" {
+		cfg.Kubeconfig = clientcmd.NewDefaultClientConfigLoadingRules().GetDefaultFilename()
+	}
+
+	path, err := filepath.Abs(cfg.Kubeconfig)
+	if err != nil {
+		return fmt.Errorf("error resolving kubeconfig path %q: %w", cfg.Kubeconfig, err)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("kubeconfig %q not found: %w", path, err)
+	}
+
+	cfg.Kubeconfig = path
+	return nil
+}
+
+// readEmbeddedManifests returns the content of every file in dir.
+func readEmbeddedManifests(fsys embed.FS, dir string) ([]string, error) {
+	files, err := fsys.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", dir, err)
+	}
+
+	contents := make([]string, 0, len(files))
+	for _, file := range files {
+		content, err := fsys.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s file: %w", dir, err)
+		}
+		contents = append(contents, string(content))
+	}
+
+	return contents, nil
+}
+
+// loadInitConfig resolves the init config and validates it.
+func loadInitConfig(cmd *cobra.Command, configFile string) (*config.Config, error) {
+	cfg, err := resolveInitConfig(cmd, configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid colony config:\n%w", err)
+	}
+
+	return cfg, nil
+}
+
+// resolveInitConfig resolves the init config from the config file, if any,
+// then COLONY_* environment variables, then flags set on the command line.
+func resolveInitConfig(cmd *cobra.Command, configFile string) (*config.Config, error) {
+	cfg := config.Default()
+	if configFile != "" {
+		var err error
+		cfg, err = config.Load(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading config: %w", err)
+		}
+	}
+
+	cfg.ApplyEnv()
+
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		cfg.Set(f.Name, f.Value.String())
+	})
+
+	if err := readTokenSources(cmd, cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// tokenFlags are the token flags that can also be read from a file or
+// stdin, keeping the tokens out of the shell history.
+var tokenFlags = []string{"api-token", "gitlab-token", "docker-token"}
+
+// addTokenSourceFlags adds a --<token>-file and --<token>-stdin flag
+// for every token flag.
+func addTokenSourceFlags(cmd *cobra.Command) {
+	for _, name := range tokenFlags {
+		cmd.Flags().String(name+"-file", "", fmt.Sprintf("read the %s from a file", name))
+		cmd.Flags().Bool(name+"-stdin", false, fmt.Sprintf("read the %s from stdin", name))
+		cmd.MarkFlagsMutuallyExclusive(name, name+"-file", name+"-stdin")
+	}
+}
+
+// readTokenSources sets the tokens passed with --<token>-file or
+// --<token>-stdin on the config.
+func readTokenSources(cmd *cobra.Command, cfg *config.Config) error {
+	var fromStdin string
+
+	for _, name := range tokenFlags {
+		if cmd.Flags().Lookup(name+"-file") == nil {
+			continue
+		}
+
+		path, err := cmd.Flags().GetString(name + "-file")
+		if err != nil {
+			return fmt.Errorf("error reading flag %q: %w", name+"-file", err)
+		}
+
+		if path != "" {
+			content, err := os.ReadFile(path)
 			if err != nil {
-				return fmt.Errorf("error marshalling clusterrole patch: %w", err)
+				return fmt.Errorf("error reading %s file %q: %w", name, path, err)
 			}
+			cfg.Set(name, strings.TrimSpace(string(content)))
+		}
 
-			err = k8sClient.PatchClusterRole(ctx, clusterRoleName, patchBytes)
-			if err != nil {
-				return fmt.Errorf("error patching ClusterRole: %w", err)
+		stdin, err := cmd.Flags().GetBool(name + "-stdin")
+		if err != nil {
+			return fmt.Errorf("error reading flag %q: %w", name+"-stdin", err)
+		}
+
+		if stdin {
+			if fromStdin != "" {
+				return fmt.Errorf("only one token can be read from stdin, got --%s-stdin and --%s-stdin", fromStdin, name)
 			}
-			log.Info("colony init completed successfully")
+			fromStdin = name
 
-			return nil
-		},
+			content, err := io.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				return fmt.Errorf("error reading %s from stdin: %w", name, err)
+			}
+			cfg.Set(name, strings.TrimSpace(string(content)))
+		}
 	}
 
-	cmd.Flags().StringVar(&apiKey, "api-key", "", "api key for interacting with colony cloud")
-	cmd.Flags().StringVar(&dataCenterID, "data-center-id", "", "data center id for interacting with colony cloud")
-	cmd.Flags().StringVar(&agentID, "agent-id", "", "agent id for interacting with colony cloud")
-	cmd.Flags().StringVar(&apiURL, "api-url", "https://colony-api.konstruct.io", "api url for interacting with colony cloud")
-	cmd.Flags().StringVar(&loadBalancerInterface, "load-balancer-interface", "", "the local network interface for colony to use")
-	cmd.Flags().StringVar(&loadBalancerIP, "load-balancer-ip", "", "the local network interface for colony to use")
-	cmd.Flags().StringVar(&apiToken, "api-token", "", "API-go token")
-	cmd.Flags().StringVar(&gitlabToken, "gitlab-token", "", "Gitlab token")
-	cmd.Flags().StringVar(&dockerToken, "docker-token", "", "Docker token")
-	cmd.Flags().StringVar(&cseInstallerImage, "cse-installer-image", "ghcr.io/konstructio/cse-installer:v0.0.10", "cse-installer image location")
-
-	cmd.MarkFlagRequired("api-key")
-	cmd.MarkFlagRequired("data-center-id")
-	cmd.MarkFlagRequired("load-balancer-interface")
-	cmd.MarkFlagRequired("load-balancer-ip")
-	cmd.MarkFlagRequired("cse-config-path")
-	cmd.MarkFlagRequired("api-token")
-	cmd.MarkFlagRequired("gitlab-token")
-	cmd.MarkFlagRequired("docker-token")
-
-	return cmd
+	return nil
 }
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/konstructio/colony/internal/config"
+	"github.com/konstructio/colony/internal/constants"
+	"github.com/konstructio/colony/internal/container"
+	"github.com/konstructio/colony/internal/logger"
+	"github.com/konstructio/colony/internal/versions"
+)
+
+func newTestInstaller(t *testing.T, runtime *container.Fake) *installer {
+	t.Helper()
+
+	target, err := versions.Target()
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	dir := t.TempDir()
+
+	return &installer{
+		cfg:            config.Default(),
+		log:            logger.NOOPLogger,
+		k3s:            container.NewK3s(logger.NOOPLogger, runtime),
+		versions:       target,
+		colonyDir:      dir,
+		bootstrapPath:  filepath.Join(dir, "k3s-bootstrap", constants.ColonyYamlPath),
+		kubeconfigPath: filepath.Join(dir, constants.KubeconfigHostPath),
+	}
+}
+
+func Test_installer_createContainer(t *testing.T) {
+	t.Run("creates the k3s container", func(tt *testing.T) {
+		runtime := container.NewFake()
+		inst := newTestInstaller(tt, runtime)
+
+		// k3s writes the kubeconfig once it runs
+		runtime.OnStart = func(*container.Container) {
+			os.WriteFile(inst.kubeconfigPath, []byte("kubeconfig"), 0o600)
+		}
+
+		if err := inst.renderColonyYaml(context.Background()); err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if err := inst.createContainer(context.Background()); err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		k3scontainer, err := runtime.Get(context.Background(), constants.ColonyK3sContainerName)
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if k3scontainer.State != "running" {
+			tt.Fatalf("expected the container to be running but got %q", k3scontainer.State)
+		}
+
+		if !mountsDir(k3scontainer, inst.colonyDir) {
+			tt.Fatalf("expected the container to mount the colony directory %q", inst.colonyDir)
+		}
+	})
+
+	t.Run("skips an existing container", func(tt *testing.T) {
+		runtime := container.NewFake()
+		inst := newTestInstaller(tt, runtime)
+
+		if _, err := runtime.Create(context.Background(), container.Spec{Name: constants.ColonyK3sContainerName}); err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		runtime.OnStart = func(*container.Container) {
+			tt.Fatalf("not expecting the existing container to be started again")
+		}
+
+		if err := inst.createContainer(context.Background()); err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if len(runtime.Containers) != 1 {
+			tt.Fatalf("expected a single container but got %d", len(runtime.Containers))
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/konstructio/colony/internal/config"
+	"github.com/konstructio/colony/internal/container"
+	"github.com/konstructio/colony/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+func getLogsCommand() *cobra.Command {
+	var follow bool
+
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "print the logs of the colony k3s container",
+		Long:  `print the logs of the colony k3s container`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			log := logger.FromContext(cmd.Context())
+			ctx := cmd.Context()
+
+			colonyCtx, err := currentContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			// installs made before the config was saved ran in docker
+			cfg, err := config.Load(colonyCtx.ConfigPath())
+			if err != nil {
+				cfg = config.Default()
+			}
+
+			if cfg.External() {
+				return errors.New("colony runs in an existing cluster, read its logs with kubectl")
+			}
+
+			runtime, err := newContainerRuntime(log, cfg)
+			if err != nil {
+				return err
+			}
+			defer runtime.Close()
+
+			if err := container.NewK3s(log, runtime).Logs(ctx, cmd.OutOrStdout(), follow); err != nil {
+				return fmt.Errorf("error printing logs: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "follow the logs")
+
+	return cmd
+}
@@ -0,0 +1,14 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/konstructio/colony/internal/printer"
+	"github.com/spf13/cobra"
+)
+
+// addOutputFlag adds the -o flag shared by the commands printing lists
+// and objects.
+func addOutputFlag(cmd *cobra.Command, output *string) {
+	cmd.Flags().StringVarP(output, "output", "o", printer.Table, "output format, one of: "+strings.Join(printer.Formats, ", "))
+}
@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/konstructio/colony/internal/constants"
+	"github.com/konstructio/colony/internal/k8s"
+	"github.com/konstructio/colony/internal/logger"
+	"github.com/konstructio/colony/internal/printer"
+	"github.com/konstructio/colony/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// powerActionDescriptions are the short descriptions of the power
+// actions.
+var powerActionDescriptions = map[string]string{
+	"on":       "power on the servers",
+	"off":      "power off the servers immediately",
+	"soft-off": "ask the operating system of the servers to shut down",
+	"cycle":    "power the servers off then on",
+	"reset":    "reset the servers without powering them off",
+}
+
+func getPowerCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "power",
+		Short: "manage the power of the servers through their bmc",
+		Long: `manage the power of the servers through their bmc
+
+servers are picked with --hardware-id, or with --selector matching the
+labels of their rufio machine, like colony.konstruct.io/board-serial.`,
+	}
+
+	actions := make([]string, 0, len(k8s.PowerActions))
+	for action := range k8s.PowerActions {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+
+	for _, action := range actions {
+		cmd.AddCommand(getPowerActionCommand(action))
+	}
+	cmd.AddCommand(getPowerStatusCommand())
+
+	return cmd
+}
+
+func getPowerActionCommand(action string) *cobra.Command {
+	var hardwareID, selector string
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   action,
+		Short: powerActionDescriptions[action],
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			log := logger.FromContext(ctx)
+
+			tasks, err := k8s.PowerTasks(action)
+			if err != nil {
+				return err
+			}
+
+			k8sClient, targets, err := listPowerTargets(cmd, hardwareID, selector)
+			if err != nil {
+				return err
+			}
+
+			if err := k8sClient.LoadMappingsFromKubernetes(); err != nil {
+				return fmt.Errorf("error loading dynamic mappings from kubernetes: %w", err)
+			}
+
+			// create every job first so the servers are handled together
+			jobIDs := make(map[string]string, len(targets))
+			var failed int
+			for _, target := range targets {
+				jobID := utils.RandomString(6)
+				if err := k8sClient.CreateRufioJob(ctx, target.Machine, action, jobID, tasks); err != nil {
+					targetLog(log, target).Errorf("failed to power %s %s: %s", action, target.Machine, err)
+					failed++
+					continue
+				}
+				jobIDs[target.Machine] = jobID
+			}
+
+			for _, target := range targets {
+				jobID, ok := jobIDs[target.Machine]
+				if !ok {
+					continue
+				}
+
+				err := k8sClient.FetchAndWaitForRufioJobs(ctx, k8s.RufioJobWaitRequest{
+					LabelValue:   target.Machine,
+					Namespace:    constants.ColonyNamespace,
+					WaitTimeout:  int(timeout.Seconds()),
+					RandomSuffix: jobID,
+				})
+				if err != nil {
+					targetLog(log, target).Errorf("failed to power %s %s: %s", action, target.Machine, err)
+					failed++
+					continue
+				}
+
+				targetLog(log, target).Infof("power %s of %s completed", action, target.Machine)
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("power %s failed on %d of %d servers", action, failed, len(targets))
+			}
+
+			return nil
+		},
+	}
+
+	addPowerSelectorFlags(cmd, &hardwareID, &selector)
+	cmd.Flags().DurationVar(&timeout, "timeout", 5*time.Minute, "time to wait for the bmcs to run the action")
+	cmd.MarkFlagsOneRequired("hardware-id", "selector")
+
+	return cmd
+}
+
+func getPowerStatusCommand() *cobra.Command {
+	var hardwareID, selector, output string
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "show the power state of the servers",
+		Long: `show the power state of the servers
+
+the power state is the last one rufio read from the bmc. without
+--hardware-id or --selector every server is shown.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			log := logger.FromContext(cmd.Context())
+
+			p, err := printer.New(output)
+			if err != nil {
+				return fmt.Errorf("error creating printer: %w", err)
+			}
+
+			_, targets, err := listPowerTargets(cmd, hardwareID, selector)
+			if err != nil && !errors.Is(err, errNoPowerTarget) {
+				return err
+			}
+
+			if len(targets) == 0 && p.IsTable() {
+				log.Info("no servers found")
+				return nil
+			}
+
+			if err := p.Print(cmd.OutOrStdout(), targets, powerRows(targets)); err != nil {
+				return fmt.Errorf("error printing power states: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	addPowerSelectorFlags(cmd, &hardwareID, &selector)
+	addOutputFlag(cmd, &output)
+
+	return cmd
+}
+
+var errNoPowerTarget = errors.New("no server matches the selection")
+
+func addPowerSelectorFlags(cmd *cobra.Command, hardwareID, selector *string) {
+	cmd.Flags().StringVar(hardwareID, "hardware-id", "", "hardware id of the server")
+	cmd.Flags().StringVarP(selector, "selector", "l", "", "label selector of the rufio machines of the servers")
+	cmd.MarkFlagsMutuallyExclusive("hardware-id", "selector")
+}
+
+// listPowerTargets returns the rufio machines picked with --hardware-id
+// or --selector.
+func listPowerTargets(cmd *cobra.Command, hardwareID, selector string) (*k8s.Client, []k8s.PowerTarget, error) {
+	colonyCtx, err := currentContext(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	k8sClient, err := k8s.New(logger.FromContext(cmd.Context()), colonyCtx.KubeconfigPath())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create k8s client: %w", err)
+	}
+
+	targets, err := k8sClient.ListPowerTargets(cmd.Context(), hardwareID, selector)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error listing servers: %w", err)
+	}
+
+	if len(targets) == 0 {
+		return k8sClient, nil, errNoPowerTarget
+	}
+
+	return k8sClient, targets, nil
+}
+
+func targetLog(log *logger.Logger, target k8s.PowerTarget) *logger.Logger {
+	return log.WithFields(logger.Fields{
+		logger.FieldBMCIP:      target.Host,
+		logger.FieldHardwareID: target.HardwareID,
+	})
+}
+
+func powerRows(targets []k8s.PowerTarget) printer.Rows {
+	rows := printer.Rows{
+		Columns: []printer.Column{
+			{Name: "machine"},
+			{Name: "hardware id"},
+			{Name: "bmc ip"},
+			{Name: "power"},
+		},
+		Items: make([]map[string]string, 0, len(targets)),
+	}
+
+	for _, target := range targets {
+		power := target.PowerState
+		if power == "" {
+			power = "unknown"
+		}
+
+		rows.Items = append(rows.Items, map[string]string{
+			"machine":     target.Machine,
+			"hardware id": target.HardwareID,
+			"bmc ip":      target.Host,
+			"power":       power,
+		})
+	}
+
+	return rows
+}
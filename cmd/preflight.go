@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/konstructio/colony/internal/config"
+	"github.com/konstructio/colony/internal/container"
+	"github.com/konstructio/colony/internal/logger"
+	"github.com/konstructio/colony/internal/preflight"
+	"github.com/spf13/cobra"
+)
+
+func getPreflightCommand() *cobra.Command {
+	var configFile string
+
+	cmd := &cobra.Command{
+		Use:   "preflight",
+		Short: "check that this host can run colony",
+		Long: `check that this host can run colony
+
+runs the same host checks as "colony init": network interface, load
+balancer ip conflicts, port availability, docker daemon capabilities,
+disk space and kernel modules. the colony api is never contacted.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			log := logger.FromContext(cmd.Context())
+			ctx := cmd.Context()
+
+			cfg, err := resolveInitConfig(cmd, configFile)
+			if err != nil {
+				return err
+			}
+
+			if cfg.LoadBalancer.IP == "" || cfg.LoadBalancer.Interface == "" {
+				return errors.New("a load balancer ip and interface are required, set them in the config file or with --load-balancer-ip and --load-balancer-interface")
+			}
+
+			colonyCtx, err := currentContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			var runtime container.Runtime
+			if !cfg.External() {
+				runtime, err = newContainerRuntime(log, cfg)
+				if err != nil {
+					return err
+				}
+				defer runtime.Close()
+			}
+
+			return runPreflight(ctx, cfg, runtime, colonyCtx.Dir)
+		},
+	}
+
+	cmd.Flags().StringVarP(&configFile, "file", "f", "", "path to a colony init config file")
+	cmd.Flags().String("load-balancer-interface", "", "the local network interface for colony to use")
+	cmd.Flags().String("load-balancer-ip", "", "the local ip address for colony to use")
+	cmd.Flags().String("runtime", config.RuntimeDocker, "where colony runs, one of: "+strings.Join(config.Runtimes, ", "))
+	cmd.Flags().String("kubeconfig", "", "check for an install into the existing cluster of this kubeconfig, implies --runtime=external")
+
+	return cmd
+}
+
+// runPreflight runs the host checks, prints the report and returns an
+// error if any check failed. The container runtime is nil when colony
+// is installed into an existing cluster.
+func runPreflight(ctx context.Context, cfg *config.Config, runtime container.Runtime, colonyDir string) error {
+	report := preflight.Run(ctx, preflight.Options{
+		LoadBalancerIP:        cfg.LoadBalancer.IP,
+		LoadBalancerInterface: cfg.LoadBalancer.Interface,
+		ColonyDir:             colonyDir,
+		Runtime:               runtime,
+		External:              cfg.External(),
+	})
+
+	report.Print()
+
+	if report.Failed() {
+		return errors.New("preflight checks failed")
+	}
+
+	return nil
+}
@@ -1,107 +1,185 @@
 package cmd
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"html/template"
-	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/konstructio/colony/internal/constants"
 	"github.com/konstructio/colony/internal/k8s"
 	"github.com/konstructio/colony/internal/logger"
+	"github.com/konstructio/colony/internal/printer"
+	"github.com/konstructio/colony/internal/rollout"
 	"github.com/konstructio/colony/internal/utils"
-	"github.com/konstructio/colony/manifests"
 	"github.com/spf13/cobra"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func getRebootCommand() *cobra.Command {
-	var hardwareID, bootDevice string
-	var efiBoot bool
+	var hardwareID, selector, bootDevice, output string
+	var efiBoot, continueOnError bool
+	var timeout time.Duration
+	opts := rollout.Options{}
+
 	rebootCmd := &cobra.Command{
 		Use:   "reboot",
-		Short: "reboots the server passed with hardware id",
+		Short: "reboots the servers passed with hardware id or selector",
+		Long: `reboots the servers passed with hardware id or selector
+
+the servers are powered off, set to boot once from the boot device and
+powered on. servers picked with --selector are rebooted in batches of
+--batch-size, waiting --pause between batches. the reboot stops at the
+first failed batch unless --continue-on-error is set, and at most
+--max-unavailable servers are rebooting at any time. a server whose rufio
+job failed is reported with the rufio message.
+
+  colony reboot --selector rack=r12 --batch-size 5 --pause 30s
+  colony reboot --selector rack=r12 --batch-size 5 --max-unavailable 5 --continue-on-error`,
+		Args: cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			ctx := cmd.Context()
-			log := logger.New(logger.Debug)
+			log := logger.FromContext(ctx)
+
+			opts.ContinueOnError = continueOnError
+			if err := opts.Validate(); err != nil {
+				return fmt.Errorf("invalid reboot options: %w", err)
+			}
 
-			homeDir, err := os.UserHomeDir()
+			p, err := printer.New(output)
 			if err != nil {
-				return fmt.Errorf("error getting user home directory: %w", err)
+				return fmt.Errorf("error creating printer: %w", err)
 			}
 
-			k8sClient, err := k8s.New(log, filepath.Join(homeDir, constants.ColonyDir, constants.KubeconfigHostPath))
+			k8sClient, targets, err := listPowerTargets(cmd, hardwareID, selector)
 			if err != nil {
-				return fmt.Errorf("failed to create k8s client: %w", err)
+				return err
 			}
 
 			if err = k8sClient.LoadMappingsFromKubernetes(); err != nil {
 				return fmt.Errorf("error loading dynamic mappings from kubernetes: %w", err)
 			}
 
-			log.Infof("rebooting hardware with id %q", hardwareID)
-			log.Infof("boot device %q", bootDevice)
-			log.Infof("efi boot %t", efiBoot)
+			log.Infof("rebooting %d servers in batches of %d with boot device %q and efi boot %t", len(targets), opts.BatchSize, bootDevice, efiBoot)
 
-			file, err := manifests.IPMI.ReadFile("ipmi/ipmi-off-pxe-on.yaml.tmpl")
-			if err != nil {
-				return fmt.Errorf("error reading templates file: %w", err)
-			}
-			randomSuffix := utils.RandomString(6)
+			tasks := k8s.RebootTasks(bootDevice, efiBoot)
 
-			tmpl, err := template.New("ipmi").Funcs(template.FuncMap{
-				"replaceDotsWithDash": func(s string) string {
-					return strings.ReplaceAll(s, ".", "-")
-				},
-			}).Parse(string(file))
-			if err != nil {
-				return fmt.Errorf("error parsing template: %w", err)
+			opts.OnBatch = func(batch int, items []int) {
+				machines := make([]string, 0, len(items))
+				for _, i := range items {
+					machines = append(machines, targets[i].Machine)
+				}
+				log.Infof("batch %d: rebooting %s", batch, strings.Join(machines, ", "))
 			}
 
-			var outputBuffer bytes.Buffer
-
-			ip, err := k8sClient.GetHardwareMachineRefFromSecretLabel(ctx, constants.ColonyNamespace, metav1.ListOptions{
-				LabelSelector: fmt.Sprintf("colony.konstruct.io/hardware-id=%s", hardwareID),
+			results := rollout.Run(ctx, targets, opts, func(ctx context.Context, target k8s.PowerTarget) error {
+				jobID := utils.RandomString(6)
+
+				if err := k8sClient.CreateRufioJob(ctx, target.Machine, "reboot", jobID, tasks); err != nil {
+					return err
+				}
+
+				if err := k8sClient.FetchAndWaitForRufioJobs(ctx, k8s.RufioJobWaitRequest{
+					LabelValue:   target.Machine,
+					Namespace:    constants.ColonyNamespace,
+					WaitTimeout:  int(timeout.Seconds()),
+					RandomSuffix: jobID,
+				}); err != nil {
+					if errors.Is(err, k8s.ErrJobFailed) {
+						return fmt.Errorf("reboot of %s failed: %w", target.Machine, err)
+					}
+					return fmt.Errorf("error waiting for the reboot of %s: %w", target.Machine, err)
+				}
+
+				targetLog(log, target).Infof("%s rebooted", target.Machine)
+				return nil
 			})
-			if err != nil {
-				return fmt.Errorf("error getting machine ref: %w", err)
-			}
 
-			err = tmpl.Execute(&outputBuffer, RufioPowerCycleRequest{
-				IP:           ip,
-				EFIBoot:      efiBoot,
-				BootDevice:   bootDevice,
-				RandomSuffix: randomSuffix,
-			})
-			if err != nil {
-				return fmt.Errorf("error executing template: %w", err)
+			if err := p.Print(cmd.OutOrStdout(), rebootResults(targets, results), rebootRows(targets, results)); err != nil {
+				return fmt.Errorf("error printing reboot results: %w", err)
 			}
 
-			log.Info(outputBuffer.String())
-
-			if err := k8sClient.ApplyManifests(ctx, []string{outputBuffer.String()}); err != nil {
-				return fmt.Errorf("error applying rufio job: %w", err)
+			var notRebooted int
+			for _, result := range results {
+				if result.Outcome != rollout.Succeeded {
+					notRebooted++
+				}
 			}
 
-			err = k8sClient.FetchAndWaitForRufioJobs(ctx, k8s.RufioJobWaitRequest{
-				LabelValue:   strings.ReplaceAll(ip, ".", "-"),
-				Namespace:    constants.ColonyNamespace,
-				WaitTimeout:  300,
-				RandomSuffix: randomSuffix,
-			})
-			if err != nil {
-				return fmt.Errorf("error get rufio job: %w", err)
+			if notRebooted > 0 {
+				return fmt.Errorf("%d of %d servers were not rebooted", notRebooted, len(targets))
 			}
 
 			return nil
 		},
 	}
 
-	rebootCmd.Flags().StringVar(&hardwareID, "hardware-id", "", "hardware id of the server to reboot")
+	addPowerSelectorFlags(rebootCmd, &hardwareID, &selector)
+	rebootCmd.MarkFlagsOneRequired("hardware-id", "selector")
 	rebootCmd.Flags().StringVar(&bootDevice, "boot-device", "pxe", "the bootdev to set (pxe, bios) defaults to pxe")
 	rebootCmd.Flags().BoolVar(&efiBoot, "efiBoot", true, "boot device option (uefi, legacy) defaults to uefi")
-	rebootCmd.MarkFlagRequired("hardware-id")
+	rebootCmd.Flags().IntVar(&opts.BatchSize, "batch-size", 1, "number of servers rebooted at once")
+	rebootCmd.Flags().IntVar(&opts.MaxUnavailable, "max-unavailable", 0, "maximum number of servers rebooting at once, defaults to --batch-size")
+	rebootCmd.Flags().DurationVar(&opts.Pause, "pause", 0, "time to wait between batches")
+	rebootCmd.Flags().BoolVar(&continueOnError, "continue-on-error", false, "reboot the next batches when a batch failed")
+	rebootCmd.Flags().DurationVar(&timeout, "timeout", 5*time.Minute, "time to wait for the reboot of a server")
+	addOutputFlag(rebootCmd, &output)
 	return rebootCmd
 }
+
+// rebootResult is the outcome of the reboot of a server.
+type rebootResult struct {
+	k8s.PowerTarget
+	Batch   int    `json:"batch,omitempty"`
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+}
+
+func rebootResults(targets []k8s.PowerTarget, results []rollout.Result) []rebootResult {
+	list := make([]rebootResult, 0, len(targets))
+	for i, target := range targets {
+		r := rebootResult{
+			PowerTarget: target,
+			Batch:       results[i].Batch,
+			Outcome:     string(results[i].Outcome),
+		}
+		if results[i].Err != nil {
+			r.Error = results[i].Err.Error()
+		}
+		list = append(list, r)
+	}
+	return list
+}
+
+func rebootRows(targets []k8s.PowerTarget, results []rollout.Result) printer.Rows {
+	rows := printer.Rows{
+		Columns: []printer.Column{
+			{Name: "machine"},
+			{Name: "hardware id"},
+			{Name: "bmc ip"},
+			{Name: "batch"},
+			{Name: "outcome"},
+			{Name: "error"},
+		},
+		Items: make([]map[string]string, 0, len(targets)),
+	}
+
+	for _, r := range rebootResults(targets, results) {
+		batch := "-"
+		if r.Batch > 0 {
+			batch = strconv.Itoa(r.Batch)
+		}
+
+		rows.Items = append(rows.Items, map[string]string{
+			"machine":     r.Machine,
+			"hardware id": r.HardwareID,
+			"bmc ip":      r.Host,
+			"batch":       batch,
+			"outcome":     r.Outcome,
+			"error":       r.Error,
+		})
+	}
+
+	return rows
+}
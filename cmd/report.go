@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/konstructio/colony/internal/colony"
+	"github.com/konstructio/colony/internal/config"
+	"github.com/konstructio/colony/internal/k8s"
+	"github.com/konstructio/colony/internal/logger"
+	"github.com/konstructio/colony/internal/workspace"
+)
+
+// replayTimeout bounds the replay of the queued updates before a command.
+const replayTimeout = 10 * time.Second
+
+// assetReporter reports asset state transitions to the colony api. The
+// updates the api cannot take are queued and replayed later, so an api
+// outage never fails the local work.
+type assetReporter struct {
+	log       *logger.Logger
+	colonyAPI *colony.API
+	agentID   string
+	queue     *colony.Queue
+}
+
+// newAssetReporter returns a reporter for the agent of the context. Without
+// a registered agent the updates are only logged.
+func newAssetReporter(ctx context.Context, log *logger.Logger, colonyCtx *workspace.Context, k8sClient *k8s.Client) *assetReporter {
+	r := &assetReporter{
+		log:   log,
+		queue: colony.NewQueue(colonyCtx.PendingUpdatesPath()),
+	}
+
+	cfg, err := config.Load(colonyCtx.ConfigPath())
+	if err != nil {
+		cfg = nil
+	}
+
+	agentConfig := loadAgentConfig(ctx, log, k8sClient, cfg)
+	if agentConfig == nil {
+		log.Warn("no registered agent found, asset states will not be reported to the colony api")
+		return r
+	}
+
+	// queue quickly rather than hold up the local work
+	colonyAPI, err := newColonyAPI(log, agentConfig, colony.WithMaxRetries(1))
+	if err != nil {
+		log.Warnf("asset states will not be reported to the colony api: %s", err)
+		return r
+	}
+
+	r.colonyAPI = colonyAPI
+	r.agentID = agentConfig.AgentID
+	return r
+}
+
+// report sends the state of the hardware with the given MAC address.
+func (r *assetReporter) report(ctx context.Context, name, mac, state string, stateErr error) {
+	if r.colonyAPI == nil {
+		return
+	}
+
+	update := colony.AssetStateUpdate{
+		Name:       name,
+		MacAddress: mac,
+		State:      state,
+		UpdatedAt:  time.Now().UTC(),
+	}
+	if stateErr != nil {
+		update.Error = stateErr.Error()
+	}
+
+	// keep the updates in order behind the ones the replay before the
+	// command could not send
+	if pending, err := r.queue.List(); err == nil && len(pending) > 0 {
+		r.enqueue(update, errors.New("earlier asset states are still queued"))
+		return
+	}
+
+	err := r.colonyAPI.UpdateAssetState(ctx, r.agentID, update)
+	switch {
+	case err == nil:
+		r.log.Infof("reported %s as %s to the colony api", name, state)
+	case colony.IsTemporary(err):
+		r.enqueue(update, err)
+	default:
+		r.log.Errorf("the colony api rejected the %s state of %s: %s", state, name, err)
+	}
+}
+
+func (r *assetReporter) enqueue(update colony.AssetStateUpdate, cause error) {
+	if err := r.queue.Add(r.agentID, update); err != nil {
+		r.log.Errorf("unable to queue the %s state of %s: %s", update.State, update.Name, err)
+		return
+	}
+	r.log.Warnf("colony api unreachable, the %s state of %s is queued and will be sent later: %s", update.State, update.Name, cause)
+}
+
+// replayPendingUpdates sends the asset states queued by a previous run,
+// if any. It runs once before every command that may reach the colony
+// api, and after every agent heartbeat. Failures are only logged.
+func replayPendingUpdates(ctx context.Context, log *logger.Logger, colonyCtx *workspace.Context) {
+	queue := colony.NewQueue(colonyCtx.PendingUpdatesPath())
+	if _, err := os.Stat(colonyCtx.PendingUpdatesPath()); err != nil {
+		return
+	}
+
+	var k8sClient *k8s.Client
+	if _, err := os.Stat(colonyCtx.KubeconfigPath()); err == nil {
+		k8sClient, _ = k8s.New(log, colonyCtx.KubeconfigPath())
+	}
+
+	cfg, err := config.Load(colonyCtx.ConfigPath())
+	if err != nil {
+		cfg = nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, replayTimeout)
+	defer cancel()
+
+	agentConfig := loadAgentConfig(ctx, log, k8sClient, cfg)
+	if agentConfig == nil {
+		return
+	}
+
+	colonyAPI, err := newColonyAPI(log, agentConfig, colony.WithMaxRetries(0))
+	if err != nil {
+		log.Warnf("unable to replay the queued asset states: %s", err)
+		return
+	}
+
+	sent, err := queue.Replay(ctx, colonyAPI)
+	if sent > 0 {
+		log.Infof("sent %d queued asset state(s) to the colony api", sent)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Warnf("unable to replay the queued asset states: %s", err)
+	}
+}
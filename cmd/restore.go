@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/konstructio/colony/internal/backup"
+	"github.com/konstructio/colony/internal/k8s"
+	"github.com/konstructio/colony/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+func getRestoreCommand() *cobra.Command {
+	var (
+		passphraseFile, colonyYAMLOut string
+		overwrite, includeAPISecret   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "restore <backup-file>",
+		Short: "restore the provisioning state of your data center from a backup",
+		Long: `restore the provisioning state of your data center from a backup
+
+re-applies the objects of a "colony backup" archive into a freshly
+initialised colony, in dependency order: secrets, machines, hardware,
+templates and workflows. existing objects are left untouched unless
+--overwrite is set. workflows that already finished are skipped so they
+do not run again on the next netboot of their hardware.
+
+the colony-api secret is only restored with --include-api-secret, since
+the new colony registered its own agent. the passphrase of encrypted
+backups is read from --passphrase-file or the ` + backupPassphraseEnv + `
+environment variable.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := logger.FromContext(cmd.Context())
+			ctx := cmd.Context()
+
+			passphrase, err := readPassphrase(passphraseFile)
+			if err != nil {
+				return err
+			}
+
+			file, err := os.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("error opening backup file %q: %w", args[0], err)
+			}
+			defer file.Close()
+
+			b, err := backup.Read(file, passphrase)
+			if err != nil {
+				return fmt.Errorf("error reading backup %q: %w", args[0], err)
+			}
+
+			log.Infof("restoring backup created at %s", b.Manifest.CreatedAt)
+
+			colonyCtx, err := currentContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			k8sClient, err := k8s.New(log, colonyCtx.KubeconfigPath())
+			if err != nil {
+				return fmt.Errorf("failed to create k8s client: %w", err)
+			}
+
+			if err := k8sClient.LoadMappingsFromKubernetes(); err != nil {
+				return fmt.Errorf("error loading dynamic mappings from kubernetes: %w", err)
+			}
+
+			for _, kind := range backup.Kinds {
+				var manifests []string
+				for _, entry := range b.Entries[kind.Name] {
+					if kind.Secret && entry.Name == backup.APISecretName && !includeAPISecret {
+						log.Infof("skipping secret %q, use --include-api-secret to restore it", entry.Name)
+						continue
+					}
+
+					reason, err := kind.SkipReason(entry.Manifest)
+					if err != nil {
+						return fmt.Errorf("error restoring %s: %w", kind.Name, err)
+					}
+					if reason != "" {
+						log.Infof("skipping %s %q, %s", kind.Name, entry.Name, reason)
+						continue
+					}
+
+					manifests = append(manifests, string(entry.Manifest))
+				}
+
+				if len(manifests) == 0 {
+					continue
+				}
+
+				log.Infof("restoring %d %s", len(manifests), kind.Name)
+
+				if overwrite {
+					err = k8sClient.ApplyManifests(ctx, manifests)
+				} else {
+					err = k8sClient.CreateManifests(ctx, manifests)
+				}
+				if err != nil {
+					return fmt.Errorf("error restoring %s: %w", kind.Name, err)
+				}
+			}
+
+			if colonyYAMLOut != "" && len(b.ColonyYAML) > 0 {
+				if err := os.WriteFile(colonyYAMLOut, b.ColonyYAML, 0o600); err != nil {
+					return fmt.Errorf("error writing colony.yaml to %q: %w", colonyYAMLOut, err)
+				}
+				log.Infof("colony.yaml from the backup written to %q", colonyYAMLOut)
+			}
+
+			log.Info("restore completed successfully")
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "file holding the passphrase the secrets were encrypted with")
+	cmd.Flags().StringVar(&colonyYAMLOut, "colony-yaml-out", "", "write the colony.yaml of the backup to this path, for reference")
+	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "update objects that already exist")
+	cmd.Flags().BoolVar(&includeAPISecret, "include-api-secret", false, "also restore the colony-api secret of the backed up agent")
+
+	return cmd
+}
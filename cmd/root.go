@@ -1,26 +1,153 @@
 package cmd
 
 import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/konstructio/colony/internal/logger"
+	"github.com/konstructio/colony/internal/utils"
 	"github.com/spf13/cobra"
 )
 
 // GetRootCommand returns the root cobra command
 func GetRootCommand() *cobra.Command {
+	var logLevel, logFormat, logFile string
+	var quiet bool
+
+	// closeLog closes the --log-file once the command is done
+	closeLog := func() error { return nil }
+
 	cmd := &cobra.Command{
 		Use:           "colony",
 		Short:         "colony is a tool to manage your data center",
 		Long:          ``,
 		SilenceUsage:  true,
 		SilenceErrors: true, // we print the errors ourselves on main
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			log, closeFile, err := newLogger(logLevel, logFormat, logFile, quiet)
+			if err != nil {
+				return err
+			}
+			closeLog = closeFile
+
+			cmd.SetContext(logger.NewContext(cmd.Context(), log))
+
+			// asset states queued while the colony api was unreachable
+			if replaysPendingUpdates(cmd) {
+				if colonyCtx, err := currentContext(cmd); err == nil {
+					replayPendingUpdates(cmd.Context(), log, colonyCtx)
+				}
+			}
+
+			return nil
+		},
+		PersistentPostRunE: func(_ *cobra.Command, _ []string) error {
+			return closeLog()
+		},
 	}
 
 	cmd.AddCommand(
 		getDestroyCommand(),
 		getInitCommand(),
+		getPreflightCommand(),
 		getAddIPMICommand(),
 		getRebootCommand(),
 		getVersionCommand(),
 		getAssetsCommand(),
-		getDeprovisionCommand())
+		getDeprovisionCommand(),
+		getUpgradeCommand(),
+		getStatusCommand(),
+		getLogsCommand(),
+		getBackupCommand(),
+		getRestoreCommand(),
+		getContextCommand(),
+		getAgentCommand(),
+		getBMCCommand(),
+		getPowerCommand(),
+		getHardwareCommand())
+
+	cmd.PersistentFlags().String("context", "", "colony context to use, defaults to the current context")
+	cmd.PersistentFlags().StringVar(&logLevel, "log-level", string(logger.Info), "log level, one of: "+strings.Join(logger.Levels, ", "))
+	cmd.PersistentFlags().StringVar(&logFormat, "log-format", string(logger.Text), "log format, one of: "+strings.Join(logger.Formats, ", "))
+	cmd.PersistentFlags().StringVar(&logFile, "log-file", "", "also append the logs to this file")
+	cmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "only print results and errors, the logs still go to --log-file")
+
 	return cmd
 }
+
+// noReplayCommands never send the queued asset states: they must not
+// reach the colony api or be slowed down by it, or, like the agent,
+// replay them on their own.
+var noReplayCommands = map[string]bool{
+	"agent":      true,
+	"completion": true,
+	"context":    true,
+	"destroy":    true,
+	"help":       true,
+	"init":       true,
+	"preflight":  true,
+	"version":    true,
+}
+
+// replaysPendingUpdates returns true if the queued asset states are sent
+// before cmd runs.
+func replaysPendingUpdates(cmd *cobra.Command) bool {
+	if !cmd.HasParent() {
+		return false
+	}
+
+	for c := cmd; c.HasParent(); c = c.Parent() {
+		if noReplayCommands[c.Name()] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newLogger builds the logger of a colony run. Every message carries the
+// id of the run so the logs of one run can be found in a log shipper.
+// Quiet runs only log to the log file; errors are still printed by main.
+// The returned func closes the log file.
+func newLogger(level, format, file string, quiet bool) (*logger.Logger, func() error, error) {
+	var output io.Writer = os.Stderr
+	if quiet {
+		output = io.Discard
+	}
+
+	closeFile := func() error { return nil }
+
+	if file != "" {
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error opening log file: %w", err)
+		}
+
+		closeFile = func() error {
+			if err := f.Close(); err != nil {
+				return fmt.Errorf("error closing log file: %w", err)
+			}
+			return nil
+		}
+
+		if quiet {
+			output = f
+		} else {
+			output = io.MultiWriter(os.Stderr, f)
+		}
+	}
+
+	log, err := logger.NewWithOptions(logger.Options{
+		Level:  logger.LogLevel(level),
+		Format: logger.Format(format),
+		Output: output,
+	})
+	if err != nil {
+		closeFile()
+		return nil, nil, fmt.Errorf("error configuring logging: %w", err)
+	}
+
+	return log.WithField(logger.FieldOperationID, utils.RandomString(12)), closeFile, nil
+}
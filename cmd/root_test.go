@@ -0,0 +1,31 @@
+package cmd
+
+import "testing"
+
+func Test_replaysPendingUpdates(t *testing.T) {
+	root := GetRootCommand()
+
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{args: []string{"deprovision"}, want: true},
+		{args: []string{"power", "status"}, want: true},
+		{args: []string{"preflight"}, want: false},
+		{args: []string{"version"}, want: false},
+		{args: []string{"context", "list"}, want: false},
+		{args: []string{"agent", "run"}, want: false},
+		{args: []string{}, want: false},
+	}
+
+	for _, tc := range tests {
+		cmd, _, err := root.Find(tc.args)
+		if err != nil {
+			t.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if got := replaysPendingUpdates(cmd); got != tc.want {
+			t.Fatalf("expected %t for %v but got %t", tc.want, tc.args, got)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/konstructio/colony/internal/config"
+	"github.com/konstructio/colony/internal/container"
+	"github.com/konstructio/colony/internal/docker"
+	"github.com/konstructio/colony/internal/logger"
+)
+
+// newContainerRuntime returns the container runtime that runs the colony
+// k3s container for cfg.
+func newContainerRuntime(log *logger.Logger, cfg *config.Config) (container.Runtime, error) {
+	switch cfg.Runtime {
+	case "", config.RuntimeDocker:
+		client, err := docker.New(log)
+		if err != nil {
+			return nil, fmt.Errorf("error creating docker client: %w", err)
+		}
+		return client, nil
+	case config.RuntimePodman:
+		client, err := docker.NewPodman(log)
+		if err != nil {
+			return nil, fmt.Errorf("error creating podman client: %w", err)
+		}
+		return client, nil
+	default:
+		return nil, fmt.Errorf("runtime %q does not run a container", cfg.Runtime)
+	}
+}
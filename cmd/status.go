@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/konstructio/colony/internal/agent"
+	"github.com/konstructio/colony/internal/checks"
+	"github.com/konstructio/colony/internal/colony"
+	"github.com/konstructio/colony/internal/config"
+	"github.com/konstructio/colony/internal/constants"
+	"github.com/konstructio/colony/internal/container"
+	"github.com/konstructio/colony/internal/k8s"
+	"github.com/konstructio/colony/internal/logger"
+	"github.com/konstructio/colony/internal/printer"
+	"github.com/konstructio/colony/manifests"
+	"github.com/spf13/cobra"
+)
+
+// helmInstallJobs are the jobs the k3s helm controller runs to install
+// the colony HelmCharts.
+var helmInstallJobs = []string{"helm-install-colony", "helm-install-tink-stack"}
+
+// statusReport is the json output of `colony status`.
+type statusReport struct {
+	Healthy bool          `json:"healthy"`
+	Checks  checks.Report `json:"checks"`
+}
+
+func getStatusCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:     "status",
+		Aliases: []string{"doctor"},
+		Short:   "report the health of colony on this host",
+		Long: `report the health of colony on this host
+
+checks the colony k3s container (unless colony runs in an existing
+cluster), the kubernetes api, the readiness of every
+colony deployment, the HelmChart install jobs, the colony-api secret, the
+colony api heartbeat and the tinkerbell templates and download jobs.
+
+exits with a non-zero status if any check fails.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			log := logger.FromContext(cmd.Context())
+			ctx := cmd.Context()
+
+			p, err := printer.New(output)
+			if err != nil {
+				return fmt.Errorf("error creating printer: %w", err)
+			}
+
+			colonyCtx, err := currentContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			// installs made before the config was saved ran in docker
+			cfg, err := config.Load(colonyCtx.ConfigPath())
+			if err != nil {
+				cfg = config.Default()
+			}
+
+			var k3s *container.K3s
+			if !cfg.External() {
+				runtime, err := newContainerRuntime(log, cfg)
+				if err != nil {
+					return err
+				}
+				defer runtime.Close()
+				k3s = container.NewK3s(log, runtime)
+			}
+
+			report := runStatusChecks(ctx, log, cfg, k3s, colonyCtx.KubeconfigPath())
+
+			status := statusReport{Healthy: !report.Failed(), Checks: report}
+			if err := p.Print(cmd.OutOrStdout(), status, report.Rows()); err != nil {
+				return fmt.Errorf("error printing status report: %w", err)
+			}
+
+			if report.Failed() {
+				return errors.New("colony is unhealthy")
+			}
+
+			return nil
+		},
+	}
+
+	addOutputFlag(cmd, &output)
+
+	return cmd
+}
+
+// statusDeployments are the deployments of a healthy colony installation.
+func statusDeployments(cfg *config.Config) []k8s.DeploymentDetails {
+	deployments := colonyRuntimeDeployments(cfg)
+	if !cfg.External() {
+		deployments = append([]k8s.DeploymentDetails{coreDNSDeployment}, deployments...)
+	}
+	return deployments
+}
+
+// runStatusChecks checks every part of a colony installation. Checks
+// that depend on the kubernetes api are reported as failed when it is
+// not reachable.
+func runStatusChecks(ctx context.Context, log *logger.Logger, cfg *config.Config, k3s *container.K3s, kubeconfigPath string) checks.Report {
+	var report checks.Report
+
+	if k3s != nil {
+		report = append(report, checkContainer(ctx, k3s))
+	}
+
+	k8sClient, err := k8s.New(log, kubeconfigPath)
+	if err != nil {
+		return append(report, checks.Failf("kubernetes api", "unable to create kubernetes client: %s", err))
+	}
+
+	version, err := k8sClient.ServerVersion()
+	if err != nil {
+		return append(report, checks.Failf("kubernetes api", "api server is not reachable: %s", err))
+	}
+	report = append(report, checks.Passf("kubernetes api", "api server %s is reachable", version))
+
+	for _, deployment := range statusDeployments(cfg) {
+		report = append(report, checkDeployment(ctx, k8sClient, deployment))
+	}
+
+	// helm install jobs are only run by the k3s helm controller
+	if !cfg.External() {
+		for _, job := range helmInstallJobs {
+			report = append(report, checkHelmInstallJob(ctx, k8sClient, job))
+		}
+	}
+
+	agentConfig, result := checkAPISecret(ctx, k8sClient)
+	report = append(report, result)
+
+	if agentConfig != nil {
+		state := agent.NewCollector(k8sClient, statusDeployments(cfg)).Collect(ctx)
+		report = append(report, checkHeartbeat(ctx, agentConfig, state))
+	} else {
+		report = append(report, checks.Failf("colony api heartbeat", "skipped, the colony-api secret is not valid"))
+	}
+
+	if err := k8sClient.LoadMappingsFromKubernetes(); err != nil {
+		return append(report, checks.Failf("manifests", "error loading dynamic mappings from kubernetes: %s", err))
+	}
+
+	report = append(report,
+		checkManifests(ctx, k8sClient, "templates", manifests.Templates, "templates"),
+		checkManifests(ctx, k8sClient, "download jobs", manifests.Downloads, "downloads"),
+	)
+
+	return report
+}
+
+func checkContainer(ctx context.Context, k3s *container.K3s) checks.Result {
+	const name = "k3s container"
+
+	state, err := k3s.State(ctx)
+	if err != nil {
+		return checks.Failf(name, "%s", err)
+	}
+
+	if state != "running" {
+		return checks.Failf(name, "%s container %q is %s", k3s.Runtime().Name(), constants.ColonyK3sContainerName, state)
+	}
+
+	return checks.Passf(name, "%s container %q is running", k3s.Runtime().Name(), constants.ColonyK3sContainerName)
+}
+
+func checkDeployment(ctx context.Context, k8sClient *k8s.Client, deployment k8s.DeploymentDetails) checks.Result {
+	name := "deployment " + deployment.Value
+
+	status, err := k8sClient.GetDeploymentStatus(ctx, deployment)
+	if err != nil {
+		return checks.Failf(name, "%s", err)
+	}
+
+	if status.Ready < status.Desired {
+		return checks.Failf(name, "%d/%d replicas ready", status.Ready, status.Desired)
+	}
+
+	return checks.Passf(name, "%d/%d replicas ready", status.Ready, status.Desired)
+}
+
+func checkHelmInstallJob(ctx context.Context, k8sClient *k8s.Client, jobName string) checks.Result {
+	name := "job " + jobName
+
+	job, err := k8sClient.GetJob(ctx, constants.ColonyNamespace, jobName)
+	if err != nil {
+		return checks.Failf(name, "%s", err)
+	}
+
+	switch {
+	case job.Status.Succeeded > 0:
+		return checks.Passf(name, "succeeded")
+	case job.Status.Failed > 0:
+		return checks.Failf(name, "failed %d time(s)", job.Status.Failed)
+	default:
+		return checks.Warnf(name, "still running")
+	}
+}
+
+func checkAPISecret(ctx context.Context, k8sClient *k8s.Client) (*k8s.AgentConfig, checks.Result) {
+	const name = "colony-api secret"
+
+	agentConfig, err := k8sClient.GetAgentConfig(ctx)
+	if err != nil {
+		return nil, checks.Failf(name, "%s", err)
+	}
+
+	if u, err := url.Parse(agentConfig.APIURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, checks.Failf(name, "api-url %q is not a valid url", agentConfig.APIURL)
+	}
+
+	if agentConfig.TLS.Insecure {
+		return agentConfig, checks.Warnf(name, "agent %s is configured for %s without certificate verification", agentConfig.AgentID, agentConfig.APIURL)
+	}
+
+	return agentConfig, checks.Passf(name, "agent %s is configured for %s", agentConfig.AgentID, agentConfig.APIURL)
+}
+
+func checkHeartbeat(ctx context.Context, agentConfig *k8s.AgentConfig, state colony.State) checks.Result {
+	const name = "colony api heartbeat"
+
+	// checkAPISecret already reports an insecure api
+	colonyAPI, err := newColonyAPI(logger.NOOPLogger, agentConfig, colony.WithMaxRetries(1))
+	if err != nil {
+		return checks.Failf(name, "%s", err)
+	}
+
+	if err := colonyAPI.Heartbeat(ctx, agentConfig.AgentID, state); err != nil {
+		return checks.Failf(name, "%s", err)
+	}
+
+	return checks.Passf(name, "heartbeat accepted by %s", agentConfig.APIURL)
+}
+
+func checkManifests(ctx context.Context, k8sClient *k8s.Client, name string, fsys embed.FS, dir string) checks.Result {
+	contents, err := readEmbeddedManifests(fsys, dir)
+	if err != nil {
+		return checks.Failf(name, "%s", err)
+	}
+
+	missing, err := k8sClient.MissingManifests(ctx, contents)
+	if err != nil {
+		return checks.Failf(name, "%s", err)
+	}
+
+	if len(missing) > 0 {
+		return checks.Failf(name, "missing: %s", strings.Join(missing, ", "))
+	}
+
+	return checks.Passf(name, "all %d present", len(contents))
+}
@@ -0,0 +1,359 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/konstructio/colony/internal/colony"
+	"github.com/konstructio/colony/internal/config"
+	"github.com/konstructio/colony/internal/constants"
+	"github.com/konstructio/colony/internal/container"
+	"github.com/konstructio/colony/internal/helm"
+	"github.com/konstructio/colony/internal/k8s"
+	"github.com/konstructio/colony/internal/logger"
+	"github.com/konstructio/colony/internal/table"
+	"github.com/konstructio/colony/internal/versions"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/yaml"
+)
+
+func getUpgradeCommand() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "upgrade colony on your host to the versions shipped with this cli",
+		Long: `upgrade colony on your host to the versions shipped with this cli
+
+re-renders the colony and tink-stack HelmCharts with the target versions,
+recreates the k3s container on the same persistent data when the k3s
+version changes and waits for the colony deployments to be healthy again.
+colony installed into an existing cluster has its charts upgraded with helm.
+installs made before the config file was saved have their settings
+recovered from the rendered colony.yaml and the colony secrets.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			log := logger.FromContext(cmd.Context())
+			ctx := cmd.Context()
+
+			colonyCtx, err := currentContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			var k8sClient *k8s.Client
+			var apiTLS *colony.TLS
+
+			// installs made before the config was saved only have their
+			// settings in the rendered colony.yaml and the cluster secrets
+			cfg, err := config.Load(colonyCtx.ConfigPath())
+			if err != nil {
+				log.Warnf("unable to load the config saved by `colony init`, recovering it from the cluster: %s", err)
+
+				k8sClient, err = k8s.New(log, colonyCtx.KubeconfigPath())
+				if err != nil {
+					return fmt.Errorf("error creating Kubernetes client: %w", err)
+				}
+
+				cfg, apiTLS, err = recoverConfig(ctx, colonyCtx.BootstrapPath(), k8sClient)
+				if err != nil {
+					return err
+				}
+			}
+
+			current, err := versions.LoadInstalled(colonyCtx.VersionsPath())
+			if err != nil {
+				return fmt.Errorf("error reading installed versions: %w", err)
+			}
+
+			target, err := versions.Target()
+			if err != nil {
+				return fmt.Errorf("error reading target versions: %w", err)
+			}
+
+			printVersions(current, target)
+
+			if current != nil && *current == *target && !force {
+				log.Info("colony is already up to date")
+				return nil
+			}
+
+			inst := &installer{
+				cfg:            cfg,
+				log:            log,
+				versions:       target,
+				colonyDir:      colonyCtx.Dir,
+				configPath:     colonyCtx.ConfigPath(),
+				bootstrapPath:  colonyCtx.BootstrapPath(),
+				kubeconfigPath: colonyCtx.KubeconfigPath(),
+				versionsPath:   colonyCtx.VersionsPath(),
+				k8sClient:      k8sClient,
+				apiTLS:         apiTLS,
+			}
+
+			if cfg.External() {
+				if err := upgradeExternal(ctx, inst); err != nil {
+					return err
+				}
+			} else if err := upgradeContainer(ctx, inst, current, force); err != nil {
+				return err
+			}
+
+			if err := inst.waitForDeployments(ctx); err != nil {
+				return err
+			}
+
+			if err := inst.recordVersions(ctx); err != nil {
+				return err
+			}
+
+			log.Info("colony upgrade completed successfully")
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "re-apply the target versions even if they are already installed")
+
+	return cmd
+}
+
+// upgradeContainer upgrades colony running in the k3s container, letting
+// the k3s helm controller upgrade the charts.
+func upgradeContainer(ctx context.Context, inst *installer, current *versions.Versions, force bool) error {
+	runtime, err := newContainerRuntime(inst.log, inst.cfg)
+	if err != nil {
+		return err
+	}
+	defer runtime.Close()
+
+	inst.k3s = container.NewK3s(inst.log, runtime)
+
+	containerExists, err := inst.k3s.Exists(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check for running container: %w", err)
+	}
+
+	if !containerExists {
+		return errors.New("colony is not installed on this host, run `colony init` first")
+	}
+
+	if err := inst.renderColonyYaml(ctx); err != nil {
+		return err
+	}
+
+	if current == nil || current.K3sImage != inst.versions.K3sImage || force {
+		inst.log.Infof("recreating %q container with image %q", constants.ColonyK3sContainerName, inst.versions.K3sImage)
+		if err := inst.k3s.Recreate(ctx, inst.versions.K3sImage, inst.bootstrapPath, inst.kubeconfigPath, inst.colonyDir); err != nil {
+			return fmt.Errorf("error recreating container: %w", err)
+		}
+	}
+
+	if err := inst.waitForAPI(ctx); err != nil {
+		return err
+	}
+
+	// installs from older versions of colony have the cloud
+	// tokens in the HelmChart values and no tokens secret yet
+	if err := inst.createSecrets(ctx); err != nil {
+		return err
+	}
+
+	return inst.applyHelmCharts(ctx)
+}
+
+// upgradeExternal upgrades colony installed into an existing cluster,
+// upgrading the charts with helm.
+func upgradeExternal(ctx context.Context, inst *installer) error {
+	if err := inst.renderColonyYaml(ctx); err != nil {
+		return err
+	}
+
+	if err := inst.waitForAPI(ctx); err != nil {
+		return err
+	}
+
+	if err := inst.createSecrets(ctx); err != nil {
+		return err
+	}
+
+	return inst.installCharts(ctx)
+}
+
+// secretGetter reads secrets from the cluster colony runs in.
+type secretGetter interface {
+	GetSecret(ctx context.Context, namespace, name string) (*corev1.Secret, error)
+}
+
+// renderedValues is the subset of the rendered chart values holding init
+// settings.
+type renderedValues struct {
+	ColonyAgent struct {
+		ExtraEnv map[string]interface{} `json:"extraEnv"`
+	} `json:"colony-agent"`
+	Stack struct {
+		Kubevip struct {
+			Interface string `json:"interface"`
+		} `json:"kubevip"`
+	} `json:"stack"`
+}
+
+// recoverConfig rebuilds the config of installs made before `colony init`
+// saved it, from the values of the rendered colony.yaml and the colony
+// secrets. The TLS settings are returned on their own since the secret
+// holds the certificates, not the paths the config points to.
+func recoverConfig(ctx context.Context, bootstrapPath string, secrets secretGetter) (*config.Config, *colony.TLS, error) {
+	cfg := config.Default()
+
+	if err := recoverRenderedValues(cfg, bootstrapPath); err != nil {
+		return nil, nil, err
+	}
+
+	apiSecret, err := secrets.GetSecret(ctx, constants.ColonyNamespace, constants.ColonyAPISecretName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading colony api settings: %w", err)
+	}
+
+	setFromSecret(&cfg.APIKey, apiSecret.Data["api-key"])
+	setFromSecret(&cfg.APIURL, apiSecret.Data["api-url"])
+	setFromSecret(&cfg.AgentID, apiSecret.Data["agent-id"])
+
+	// installs from older versions of colony have the cloud tokens in
+	// the HelmChart values and no tokens secret yet
+	tokensSecret, err := secrets.GetSecret(ctx, constants.ColonyNamespace, constants.ColonyTokensSecretName)
+	switch {
+	case err == nil:
+		setFromSecret(&cfg.Tokens.API, tokensSecret.Data["apigo-token"])
+		setFromSecret(&cfg.Tokens.Gitlab, tokensSecret.Data["gitlab-token"])
+		setFromSecret(&cfg.Tokens.Docker, tokensSecret.Data["docker-token"])
+	case !k8serrors.IsNotFound(err):
+		return nil, nil, fmt.Errorf("error reading colony tokens: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("unable to recover the colony config from the cluster, run `colony init` with the config to save it: %w", err)
+	}
+
+	apiTLS := &colony.TLS{
+		CA:         apiSecret.Data[k8s.SecretKeyCA],
+		ClientCert: apiSecret.Data[k8s.SecretKeyTLSCert],
+		ClientKey:  apiSecret.Data[k8s.SecretKeyTLSKey],
+		Insecure:   string(apiSecret.Data[k8s.SecretKeyInsecure]) == "true",
+	}
+
+	return cfg, apiTLS, nil
+}
+
+// recoverRenderedValues sets the config values found in the HelmChart
+// values of the rendered colony.yaml.
+func recoverRenderedValues(cfg *config.Config, bootstrapPath string) error {
+	docs, err := readManifests(bootstrapPath)
+	if err != nil {
+		return err
+	}
+
+	charts, err := helm.ChartsFromManifests(docs)
+	if err != nil {
+		return fmt.Errorf("error reading HelmCharts from %q: %w", bootstrapPath, err)
+	}
+
+	for _, chart := range charts {
+		var values renderedValues
+		// agent and data center ids may look like numbers, keep them as written
+		if err := yaml.Unmarshal([]byte(chart.Values), &values, func(d *json.Decoder) *json.Decoder {
+			d.UseNumber()
+			return d
+		}); err != nil {
+			return fmt.Errorf("error parsing values of HelmChart %q: %w", chart.Name, err)
+		}
+
+		env := func(name string) string {
+			if value, ok := values.ColonyAgent.ExtraEnv[name]; ok && value != nil {
+				return fmt.Sprint(value)
+			}
+			return ""
+		}
+
+		setFromValue(&cfg.LoadBalancer.IP, env("LOAD_BALANCER"))
+		setFromValue(&cfg.DataCenterID, env("DATA_CENTER_ID"))
+		setFromValue(&cfg.AgentID, env("AGENT_ID"))
+		setFromValue(&cfg.APIURL, env("COLONY_API_URL"))
+		setFromValue(&cfg.CSEInstallerImage, env("CSE_INSTALLER_IMAGE"))
+		setFromValue(&cfg.Tokens.API, env("APIGO_TOKEN"))
+		setFromValue(&cfg.Tokens.Gitlab, env("GITLAB_TOKEN"))
+		setFromValue(&cfg.Tokens.Docker, env("DOCKER_TOKEN"))
+		setFromValue(&cfg.LoadBalancer.Interface, values.Stack.Kubevip.Interface)
+	}
+
+	return nil
+}
+
+// setFromValue overrides dst with value, when set.
+func setFromValue(dst *string, value string) {
+	if value != "" {
+		*dst = value
+	}
+}
+
+// setFromSecret overrides dst with the secret value, when set.
+func setFromSecret(dst *string, value []byte) {
+	setFromValue(dst, string(value))
+}
+
+func printVersions(current, target *versions.Versions) {
+	printer := table.NewTablePrinter([]table.Column{
+		{Name: "component", Align: "left"},
+		{Name: "current", Align: "left"},
+		{Name: "target", Align: "left"},
+	})
+
+	var installed []versions.Component
+	if current != nil {
+		installed = current.Components()
+	}
+
+	targets := target.Components()
+	rows := make([]map[string]string, 0, len(targets))
+	for i, component := range targets {
+		version := "unknown"
+		if installed != nil && installed[i].Version != "" {
+			version = installed[i].Version
+		}
+
+		rows = append(rows, map[string]string{
+			"component": component.Name,
+			"current":   version,
+			"target":    component.Version,
+		})
+	}
+
+	printer.PrintTable(rows)
+}
+
+// applyHelmCharts applies the rendered colony.yaml so the helm controller
+// upgrades the charts to the rendered versions.
+func (i *installer) applyHelmCharts(ctx context.Context) error {
+	k8sClient, err := i.kube()
+	if err != nil {
+		return err
+	}
+
+	if err := k8sClient.LoadMappingsFromKubernetes(); err != nil {
+		return fmt.Errorf("error loading dynamic mappings from kubernetes: %w", err)
+	}
+
+	docs, err := i.renderedManifests()
+	if err != nil {
+		return err
+	}
+
+	i.log.Info("applying colony HelmCharts")
+	if err := k8sClient.ApplyManifests(ctx, docs); err != nil {
+		return fmt.Errorf("error applying HelmCharts: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/konstructio/colony/internal/config"
+	"github.com/konstructio/colony/internal/constants"
+	"github.com/konstructio/colony/internal/k8s"
+	"github.com/konstructio/colony/internal/logger"
+	"github.com/konstructio/colony/internal/versions"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type fakeSecrets map[string]*corev1.Secret
+
+func (f fakeSecrets) GetSecret(_ context.Context, _, name string) (*corev1.Secret, error) {
+	if secret, ok := f[name]; ok {
+		return secret, nil
+	}
+	return nil, k8serrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, name)
+}
+
+// oldColonyYaml is colony.yaml as rendered by versions of colony that
+// kept the cloud tokens in the HelmChart values.
+const oldColonyYaml = `apiVersion: helm.cattle.io/v1
+kind: HelmChart
+metadata:
+  name: colony
+  namespace: tink-system
+spec:
+  chart: colony
+  valuesContent: |-
+    colony-agent:
+      extraEnv:
+        COLONY_API_URL: https://colony-api.konstruct.io
+        LOAD_BALANCER: 10.0.0.10
+        DATA_CENTER_ID: 12345
+        AGENT_ID: 
+        COLONY_API_URL: https://colony.example.com
+        GITLAB_TOKEN: gitlab
+        APIGO_TOKEN : apigo
+        DOCKER_TOKEN: docker
+        CSE_INSTALLER_IMAGE: ghcr.io/konstructio/cse-installer:v0.0.1
+---
+apiVersion: helm.cattle.io/v1
+kind: HelmChart
+metadata:
+  name: tink-stack
+  namespace: tink-system
+spec:
+  chart: oci://ghcr.io/tinkerbell/charts/stack
+  valuesContent: |-
+    stack:
+      loadBalancerIP: 10.0.0.10
+      kubevip:
+        interface: eth1
+`
+
+func Test_recoverConfig(t *testing.T) {
+	apiSecret := &corev1.Secret{
+		Data: map[string][]byte{
+			"api-key":             []byte("api-key"),
+			"api-url":             []byte("https://colony.example.com"),
+			"agent-id":            []byte("agent"),
+			k8s.SecretKeyCA:       []byte("ca"),
+			k8s.SecretKeyInsecure: []byte("true"),
+		},
+	}
+
+	t.Run("rendered by the current version", func(tt *testing.T) {
+		want := &config.Config{
+			APIVersion:        config.APIVersion,
+			Kind:              config.Kind,
+			APIKey:            "api-key",
+			APIURL:            "https://colony.example.com",
+			DataCenterID:      "dc",
+			AgentID:           "agent",
+			LoadBalancer:      config.LoadBalancer{IP: "10.0.0.10", Interface: "eth1"},
+			Tokens:            config.Tokens{API: "apigo", Gitlab: "gitlab", Docker: "docker"},
+			CSEInstallerImage: config.DefaultCSEInstallerImage,
+		}
+
+		target, err := versions.Target()
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		inst := &installer{
+			cfg:           want,
+			log:           logger.NOOPLogger,
+			versions:      target,
+			bootstrapPath: filepath.Join(tt.TempDir(), constants.ColonyYamlPath),
+		}
+		if err := inst.renderColonyYaml(context.Background()); err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		secrets := fakeSecrets{
+			constants.ColonyAPISecretName: apiSecret,
+			constants.ColonyTokensSecretName: {Data: map[string][]byte{
+				"apigo-token":  []byte("apigo"),
+				"gitlab-token": []byte("gitlab"),
+				"docker-token": []byte("docker"),
+			}},
+		}
+
+		got, apiTLS, err := recoverConfig(context.Background(), inst.bootstrapPath, secrets)
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if *got != *want {
+			tt.Fatalf("expected config %+v but got %+v", want, got)
+		}
+
+		if string(apiTLS.CA) != "ca" || !apiTLS.Insecure {
+			tt.Fatalf("expected the TLS settings of the secret but got %+v", apiTLS)
+		}
+	})
+
+	t.Run("tokens in the HelmChart values", func(tt *testing.T) {
+		path := filepath.Join(tt.TempDir(), constants.ColonyYamlPath)
+		if err := os.WriteFile(path, []byte(oldColonyYaml), 0o600); err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		got, _, err := recoverConfig(context.Background(), path, fakeSecrets{constants.ColonyAPISecretName: apiSecret})
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if got.DataCenterID != "12345" {
+			tt.Fatalf("expected data center id %q but got %q", "12345", got.DataCenterID)
+		}
+
+		if got.Tokens != (config.Tokens{API: "apigo", Gitlab: "gitlab", Docker: "docker"}) {
+			tt.Fatalf("expected the tokens of the HelmChart values but got %+v", got.Tokens)
+		}
+
+		if got.LoadBalancer != (config.LoadBalancer{IP: "10.0.0.10", Interface: "eth1"}) {
+			tt.Fatalf("expected the load balancer of the HelmChart values but got %+v", got.LoadBalancer)
+		}
+
+		if got.CSEInstallerImage != "ghcr.io/konstructio/cse-installer:v0.0.1" {
+			tt.Fatalf("expected the installed cse-installer image but got %q", got.CSEInstallerImage)
+		}
+	})
+
+	t.Run("missing colony-api secret", func(tt *testing.T) {
+		path := filepath.Join(tt.TempDir(), constants.ColonyYamlPath)
+		if err := os.WriteFile(path, []byte(oldColonyYaml), 0o600); err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if _, _, err := recoverConfig(context.Background(), path, fakeSecrets{}); err == nil {
+			tt.Fatalf("expected an error but got none")
+		}
+	})
+
+	t.Run("missing colony.yaml", func(tt *testing.T) {
+		path := filepath.Join(tt.TempDir(), constants.ColonyYamlPath)
+		if _, _, err := recoverConfig(context.Background(), path, fakeSecrets{constants.ColonyAPISecretName: apiSecret}); err == nil {
+			tt.Fatalf("expected an error but got none")
+		}
+	})
+}
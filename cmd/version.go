@@ -1,8 +1,9 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/konstructio/colony/configs"
-	"github.com/konstructio/colony/internal/logger"
 	"github.com/spf13/cobra"
 )
 
@@ -11,9 +12,9 @@ func getVersionCommand() *cobra.Command {
 		Use:   "version",
 		Short: "print the version for colony cli",
 		Long:  `print the version for colony cli`,
-		RunE: func(_ *cobra.Command, _ []string) error {
-			log := logger.New(logger.Debug)
-			log.Info("colony cli version: ", configs.Version)
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			// the version is a result, printed even with --quiet
+			fmt.Fprintln(cmd.OutOrStdout(), "colony cli version:", configs.Version)
 			return nil
 		},
 	}
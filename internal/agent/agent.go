@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/konstructio/colony/internal/colony"
+	"github.com/konstructio/colony/internal/logger"
+)
+
+const (
+	// DefaultInterval is the time between two heartbeats.
+	DefaultInterval = 30 * time.Second
+
+	// DefaultMaxBackoff caps the time between two heartbeats while the
+	// colony api is unreachable.
+	DefaultMaxBackoff = 5 * time.Minute
+)
+
+// Heartbeater reports the agent state to the colony api.
+type Heartbeater interface {
+	Heartbeat(ctx context.Context, agentID string, state colony.State) error
+}
+
+// CollectFunc returns the current state of the data center.
+type CollectFunc func(ctx context.Context) colony.State
+
+// Agent periodically reports the state of the data center to the colony
+// api.
+type Agent struct {
+	log     *logger.Logger
+	api     Heartbeater
+	agentID string
+	collect CollectFunc
+
+	Interval   time.Duration
+	MaxBackoff time.Duration
+
+	// AfterHeartbeat, if set, is called after every accepted heartbeat.
+	AfterHeartbeat func(ctx context.Context)
+}
+
+// New returns an agent reporting the collected state as agentID.
+func New(log *logger.Logger, api Heartbeater, agentID string, collect CollectFunc) *Agent {
+	return &Agent{
+		log:        log,
+		api:        api,
+		agentID:    agentID,
+		collect:    collect,
+		Interval:   DefaultInterval,
+		MaxBackoff: DefaultMaxBackoff,
+	}
+}
+
+// Run sends a heartbeat every interval until ctx is done. While the api
+// is unreachable the heartbeats back off exponentially, up to the max
+// backoff. Run only returns once ctx is done.
+func (a *Agent) Run(ctx context.Context) error {
+	var failures int
+
+	for {
+		state := a.collect(ctx)
+
+		delay := a.Interval
+		if err := a.api.Heartbeat(ctx, a.agentID, state); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			failures++
+			delay = backoff(a.Interval, a.MaxBackoff, failures)
+			a.log.Warnf("heartbeat failed %d time(s), retrying in %s: %s", failures, delay.Round(time.Second), err)
+		} else {
+			if failures > 0 {
+				a.log.Infof("heartbeat accepted after %d failure(s)", failures)
+			}
+			failures = 0
+			a.log.Debugf("heartbeat accepted, status %q", state.Status)
+
+			if a.AfterHeartbeat != nil {
+				a.AfterHeartbeat(ctx)
+			}
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the delay after the given number of consecutive
+// failures: the interval doubled for every failure, capped at max, minus
+// up to a fifth of jitter so agents that lost the api together do not
+// retry together.
+func backoff(interval, maxDelay time.Duration, failures int) time.Duration {
+	delay := interval
+	for i := 0; i < failures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	return delay - time.Duration(rand.Int64N(int64(delay)/5+1))
+}
@@ -0,0 +1,92 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/konstructio/colony/internal/colony"
+	"github.com/konstructio/colony/internal/logger"
+)
+
+type fakeAPI struct {
+	mu       sync.Mutex
+	calls    int
+	failures int
+	states   []colony.State
+	cancel   context.CancelFunc
+}
+
+func (f *fakeAPI) Heartbeat(_ context.Context, agentID string, state colony.State) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if agentID != "agent-1" {
+		return errors.New("unknown agent")
+	}
+
+	f.calls++
+	if f.calls <= f.failures {
+		return errors.New("bad gateway")
+	}
+
+	f.states = append(f.states, state)
+	if len(f.states) == 2 {
+		f.cancel()
+	}
+
+	return nil
+}
+
+func TestAgent_Run(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	api := &fakeAPI{failures: 3, cancel: cancel}
+
+	a := New(logger.NOOPLogger, api, "agent-1", func(context.Context) colony.State {
+		return colony.State{Status: colony.StatusHealthy}
+	})
+	a.Interval = time.Millisecond
+	a.MaxBackoff = 4 * time.Millisecond
+
+	if err := a.Run(ctx); err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Fatalf("expected the agent to stop once cancelled, got %v", ctx.Err())
+	}
+
+	if api.calls != 5 || len(api.states) != 2 {
+		t.Fatalf("expected 3 failed and 2 accepted heartbeats, got %d calls and %d states", api.calls, len(api.states))
+	}
+
+	if api.states[0].Status != colony.StatusHealthy {
+		t.Fatalf("expected the collected state to be sent, got %+v", api.states[0])
+	}
+}
+
+func Test_backoff(t *testing.T) {
+	interval := 10 * time.Second
+	maxDelay := time.Minute
+
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{failures: 1, expected: 20 * time.Second},
+		{failures: 2, expected: 40 * time.Second},
+		{failures: 3, expected: time.Minute},
+		{failures: 50, expected: time.Minute},
+	}
+
+	for _, tc := range tests {
+		delay := backoff(interval, maxDelay, tc.failures)
+		if delay > tc.expected || delay < tc.expected*4/5 {
+			t.Fatalf("expected a delay of %s minus jitter after %d failures, got %s", tc.expected, tc.failures, delay)
+		}
+	}
+}
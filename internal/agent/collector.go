@@ -0,0 +1,84 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/konstructio/colony/internal/colony"
+	"github.com/konstructio/colony/internal/k8s"
+)
+
+// collectTimeout bounds the kubernetes calls of a single collection.
+const collectTimeout = 20 * time.Second
+
+// Collector reads the state of the data center from the cluster colony
+// runs on.
+type Collector struct {
+	client      *k8s.Client
+	deployments []k8s.DeploymentDetails
+}
+
+// NewCollector returns a collector reporting the readiness of the
+// deployments.
+func NewCollector(client *k8s.Client, deployments []k8s.DeploymentDetails) *Collector {
+	return &Collector{
+		client:      client,
+		deployments: deployments,
+	}
+}
+
+// Collect returns the current state. Failures are reported in the state,
+// which is then degraded.
+func (c *Collector) Collect(ctx context.Context) colony.State {
+	ctx, cancel := context.WithTimeout(ctx, collectTimeout)
+	defer cancel()
+
+	state := colony.State{Status: colony.StatusHealthy}
+
+	degrade := func(format string, args ...interface{}) {
+		state.Status = colony.StatusDegraded
+		state.Errors = append(state.Errors, fmt.Sprintf(format, args...))
+	}
+
+	version, err := c.client.ServerVersion()
+	if err != nil {
+		state.ControlPlane = &colony.ControlPlaneState{Healthy: false}
+		degrade("kubernetes api is not reachable: %s", err)
+		return state
+	}
+	state.ControlPlane = &colony.ControlPlaneState{Healthy: true, Version: version}
+
+	for _, deployment := range c.deployments {
+		status, err := c.client.GetDeploymentStatus(ctx, deployment)
+		if err != nil {
+			degrade("%s", err)
+			continue
+		}
+
+		state.Deployments = append(state.Deployments, colony.DeploymentState{
+			Name:      status.Name,
+			Namespace: status.Namespace,
+			Ready:     status.Ready,
+			Desired:   status.Desired,
+		})
+
+		if status.Ready < status.Desired {
+			degrade("deployment %q has %d/%d replicas ready", status.Name, status.Ready, status.Desired)
+		}
+	}
+
+	if state.Hardware, err = c.client.CountHardwareByState(ctx); err != nil {
+		degrade("%s", err)
+	}
+
+	if state.RunningJobs, err = c.client.RunningRufioJobs(ctx); err != nil {
+		degrade("%s", err)
+	}
+
+	if state.RunningWorkflows, err = c.client.RunningWorkflows(ctx); err != nil {
+		degrade("%s", err)
+	}
+
+	return state
+}
@@ -0,0 +1,145 @@
+// Package assets compares the hardware of the data center with the assets
+// known to the colony cloud.
+package assets
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/konstructio/colony/internal/colony"
+	"github.com/konstructio/colony/internal/k8s"
+)
+
+// Kind is the kind of drift between a local hardware and a cloud asset.
+type Kind string
+
+const (
+	// MissingInCloud is a hardware with no matching cloud asset.
+	MissingInCloud Kind = "missing-in-cloud"
+
+	// MissingLocally is a cloud asset with no matching hardware.
+	MissingLocally Kind = "missing-locally"
+
+	// Mismatch is a hardware whose cloud asset has a different MAC or IP.
+	Mismatch Kind = "mismatch"
+)
+
+// Drift is a difference between the hardware and the cloud assets.
+type Drift struct {
+	Kind     Kind   `json:"kind"`
+	Name     string `json:"name"`
+	AssetID  string `json:"assetID,omitempty"`
+	LocalMAC string `json:"localMAC,omitempty"`
+	CloudMAC string `json:"cloudMAC,omitempty"`
+	LocalIP  string `json:"localIP,omitempty"`
+	CloudIP  string `json:"cloudIP,omitempty"`
+
+	Local *k8s.Asset     `json:"-"`
+	Cloud *colony.Assets `json:"-"`
+}
+
+// Diff matches the hardware with the cloud assets by MAC address, then by
+// name for the hardware whose MAC changed, and returns their differences
+// sorted by name.
+func Diff(local []k8s.Asset, cloud []colony.Assets) []Drift {
+	byMAC := make(map[string]int, len(cloud))
+	byName := make(map[string]int, len(cloud))
+	for i, asset := range cloud {
+		if mac := normalizeMAC(primaryNetwork(asset).MacAddress); mac != "" {
+			byMAC[mac] = i
+		}
+		byName[asset.Name] = i
+	}
+
+	matched := make(map[int]bool, len(cloud))
+	var drifts []Drift
+
+	for i := range local {
+		hw := &local[i]
+
+		idx, ok := byMAC[normalizeMAC(hw.MAC)]
+		if !ok || matched[idx] {
+			idx, ok = byName[hw.Name]
+		}
+		if !ok || matched[idx] {
+			drifts = append(drifts, Drift{Kind: MissingInCloud, Name: hw.Name, LocalMAC: hw.MAC, LocalIP: hw.IP, Local: hw})
+			continue
+		}
+		matched[idx] = true
+
+		asset := &cloud[idx]
+		network := primaryNetwork(*asset)
+		if normalizeMAC(network.MacAddress) == normalizeMAC(hw.MAC) && network.IPAddresses == hw.IP && asset.Name == hw.Name {
+			continue
+		}
+
+		drifts = append(drifts, Drift{
+			Kind:     Mismatch,
+			Name:     hw.Name,
+			AssetID:  asset.ID,
+			LocalMAC: hw.MAC,
+			CloudMAC: network.MacAddress,
+			LocalIP:  hw.IP,
+			CloudIP:  network.IPAddresses,
+			Local:    hw,
+			Cloud:    asset,
+		})
+	}
+
+	for i := range cloud {
+		if matched[i] {
+			continue
+		}
+		asset := &cloud[i]
+		network := primaryNetwork(*asset)
+		drifts = append(drifts, Drift{
+			Kind:     MissingLocally,
+			Name:     asset.Name,
+			AssetID:  asset.ID,
+			CloudMAC: network.MacAddress,
+			CloudIP:  network.IPAddresses,
+			Cloud:    asset,
+		})
+	}
+
+	sort.SliceStable(drifts, func(i, j int) bool {
+		return drifts[i].Name < drifts[j].Name
+	})
+
+	return drifts
+}
+
+// ToCloud returns the cloud asset of a hardware. An existing asset is
+// updated in place, keeping the details only the cloud knows.
+func ToCloud(hw k8s.Asset, existing *colony.Assets) colony.Assets {
+	var asset colony.Assets
+	if existing != nil {
+		asset = *existing
+		asset.Networks = append([]colony.Network(nil), existing.Networks...)
+	}
+
+	asset.Name = hw.Name
+	if hw.Status != "" {
+		asset.Status = hw.Status
+	}
+
+	if len(asset.Networks) == 0 {
+		asset.Networks = []colony.Network{{Name: "eth0", AssetID: asset.ID}}
+	}
+	asset.Networks[0].MacAddress = hw.MAC
+	asset.Networks[0].IPAddresses = hw.IP
+
+	return asset
+}
+
+// primaryNetwork is the network tinkerbell provisions the hardware on.
+func primaryNetwork(asset colony.Assets) colony.Network {
+	if len(asset.Networks) == 0 {
+		return colony.Network{}
+	}
+	return asset.Networks[0]
+}
+
+func normalizeMAC(mac string) string {
+	return strings.ToLower(strings.ReplaceAll(mac, "-", ":"))
+}
@@ -0,0 +1,70 @@
+package assets
+
+import (
+	"testing"
+
+	"github.com/konstructio/colony/internal/colony"
+	"github.com/konstructio/colony/internal/k8s"
+)
+
+func TestDiff(t *testing.T) {
+	local := []k8s.Asset{
+		{Name: "hw-1", MAC: "AA:BB:CC:DD:EE:01", IP: "10.0.0.1"},
+		{Name: "hw-2", MAC: "aa:bb:cc:dd:ee:02", IP: "10.0.0.2"},
+		{Name: "hw-3", MAC: "aa:bb:cc:dd:ee:03", IP: "10.0.0.3"},
+		{Name: "hw-4", MAC: "aa:bb:cc:dd:ee:44", IP: "10.0.0.4"},
+	}
+
+	cloud := []colony.Assets{
+		{ID: "1", Name: "hw-1", Networks: []colony.Network{{MacAddress: "aa:bb:cc:dd:ee:01", IPAddresses: "10.0.0.1"}}},
+		{ID: "2", Name: "hw-2", Networks: []colony.Network{{MacAddress: "aa:bb:cc:dd:ee:02", IPAddresses: "10.0.0.99"}}},
+		{ID: "4", Name: "hw-4", Networks: []colony.Network{{MacAddress: "aa:bb:cc:dd:ee:04", IPAddresses: "10.0.0.4"}}},
+		{ID: "5", Name: "hw-5", Networks: []colony.Network{{MacAddress: "aa:bb:cc:dd:ee:05"}}},
+	}
+
+	drifts := Diff(local, cloud)
+
+	expected := []struct {
+		name    string
+		kind    Kind
+		assetID string
+	}{
+		{name: "hw-2", kind: Mismatch, assetID: "2"},
+		{name: "hw-3", kind: MissingInCloud},
+		{name: "hw-4", kind: Mismatch, assetID: "4"},
+		{name: "hw-5", kind: MissingLocally, assetID: "5"},
+	}
+
+	if len(drifts) != len(expected) {
+		t.Fatalf("expected %d drifts but got %d: %+v", len(expected), len(drifts), drifts)
+	}
+
+	for i, e := range expected {
+		if drifts[i].Name != e.name || drifts[i].Kind != e.kind || drifts[i].AssetID != e.assetID {
+			t.Fatalf("expected drift %d to be %+v but got %+v", i, e, drifts[i])
+		}
+	}
+}
+
+func TestToCloud(t *testing.T) {
+	existing := &colony.Assets{
+		ID:       "1",
+		Name:     "old",
+		CPUCores: 8,
+		Networks: []colony.Network{{ID: "n1", MacAddress: "aa:bb:cc:dd:ee:01"}, {ID: "n2"}},
+	}
+
+	asset := ToCloud(k8s.Asset{Name: "hw-1", MAC: "aa:bb:cc:dd:ee:02", IP: "10.0.0.2"}, existing)
+
+	if asset.ID != "1" || asset.CPUCores != 8 || asset.Name != "hw-1" || len(asset.Networks) != 2 {
+		t.Fatalf("expected the existing asset to be updated but got %+v", asset)
+	}
+
+	if asset.Networks[0].MacAddress != "aa:bb:cc:dd:ee:02" || asset.Networks[0].IPAddresses != "10.0.0.2" {
+		t.Fatalf("expected the primary network to be updated but got %+v", asset.Networks[0])
+	}
+
+	if existing.Networks[0].MacAddress != "aa:bb:cc:dd:ee:01" {
+		t.Fatalf("expected the existing asset to be left untouched")
+	}
+}
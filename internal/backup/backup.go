@@ -0,0 +1,317 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Version is the current version of the backup archive layout.
+const Version = 1
+
+const (
+	manifestFile   = "manifest.json"
+	colonyYAMLFile = "colony.yaml"
+	encryptedExt   = ".enc"
+)
+
+// ErrWrongPassphrase is returned when encrypted secrets cannot be
+// decrypted with the given passphrase.
+var ErrWrongPassphrase = errors.New("wrong passphrase for encrypted secrets")
+
+// Manifest describes the content of a backup archive.
+type Manifest struct {
+	Version   int            `json:"version"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Encrypted bool           `json:"encrypted"`
+	Salt      []byte         `json:"salt,omitempty"`
+	Counts    map[string]int `json:"counts"`
+}
+
+// Entry is a single object of a backup, stored as a YAML manifest.
+type Entry struct {
+	Name     string
+	Manifest []byte
+}
+
+// Backup is the content of a backup archive.
+type Backup struct {
+	Manifest   Manifest
+	ColonyYAML []byte
+
+	// Entries are the objects of every kind, by kind name.
+	Entries map[string][]Entry
+}
+
+// New returns an empty backup.
+func New() *Backup {
+	return &Backup{
+		Manifest: Manifest{
+			Version:   Version,
+			CreatedAt: time.Now().UTC(),
+			Counts:    make(map[string]int),
+		},
+		Entries: make(map[string][]Entry),
+	}
+}
+
+// Add adds an object of the given kind to the backup.
+func (b *Backup) Add(kind, name string, manifest []byte) {
+	b.Entries[kind] = append(b.Entries[kind], Entry{Name: name, Manifest: manifest})
+	b.Manifest.Counts[kind]++
+}
+
+// Write writes the backup as a gzipped tar archive. Objects of the
+// secret kinds are encrypted with the passphrase, unless it is empty.
+func (b *Backup) Write(w io.Writer, passphrase string) error {
+	secretKinds := SecretKinds()
+
+	var key []byte
+	b.Manifest.Encrypted = passphrase != ""
+	if b.Manifest.Encrypted {
+		b.Manifest.Salt = make([]byte, 16)
+		if _, err := rand.Read(b.Manifest.Salt); err != nil {
+			return fmt.Errorf("error generating salt: %w", err)
+		}
+
+		var err error
+		key, err = deriveKey(passphrase, b.Manifest.Salt)
+		if err != nil {
+			return err
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifest, err := json.MarshalIndent(b.Manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling backup manifest: %w", err)
+	}
+
+	if err := writeFile(tw, manifestFile, manifest); err != nil {
+		return err
+	}
+
+	if len(b.ColonyYAML) > 0 {
+		if err := writeFile(tw, colonyYAMLFile, b.ColonyYAML); err != nil {
+			return err
+		}
+	}
+
+	kinds := make([]string, 0, len(b.Entries))
+	for kind := range b.Entries {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	for _, kind := range kinds {
+		encrypt := key != nil && slices.Contains(secretKinds, kind)
+
+		for _, entry := range b.Entries[kind] {
+			name := path.Join(kind, entry.Name+".yaml")
+			content := entry.Manifest
+
+			if encrypt {
+				name += encryptedExt
+				content, err = seal(key, content)
+				if err != nil {
+					return fmt.Errorf("error encrypting %s: %w", name, err)
+				}
+			}
+
+			if err := writeFile(tw, name, content); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("error closing backup archive: %w", err)
+	}
+
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("error closing backup archive: %w", err)
+	}
+
+	return nil
+}
+
+// Read reads a backup archive written by Write. The passphrase is only
+// needed if the secrets are encrypted.
+func Read(r io.Reader, passphrase string) (*Backup, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading backup archive: %w", err)
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	var names []string
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading backup archive: %w", err)
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s from backup archive: %w", header.Name, err)
+		}
+
+		files[header.Name] = content
+		names = append(names, header.Name)
+	}
+
+	rawManifest, ok := files[manifestFile]
+	if !ok {
+		return nil, fmt.Errorf("backup archive has no %s, is it a colony backup?", manifestFile)
+	}
+
+	b := New()
+	if err := json.Unmarshal(rawManifest, &b.Manifest); err != nil {
+		return nil, fmt.Errorf("error parsing backup manifest: %w", err)
+	}
+
+	if b.Manifest.Version != Version {
+		return nil, fmt.Errorf("unsupported backup version %d, expected %d", b.Manifest.Version, Version)
+	}
+
+	var key []byte
+	if b.Manifest.Encrypted {
+		if passphrase == "" {
+			return nil, errors.New("the backup secrets are encrypted, a passphrase is required")
+		}
+
+		key, err = deriveKey(passphrase, b.Manifest.Salt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b.ColonyYAML = files[colonyYAMLFile]
+
+	for _, name := range names {
+		kind, file := path.Split(name)
+		if kind == "" {
+			continue
+		}
+		kind = strings.TrimSuffix(kind, "/")
+
+		content := files[name]
+		if strings.HasSuffix(file, encryptedExt) {
+			if key == nil {
+				return nil, fmt.Errorf("%s is encrypted but the backup manifest is not marked as encrypted", name)
+			}
+
+			content, err = open(key, content)
+			if err != nil {
+				return nil, err
+			}
+			file = strings.TrimSuffix(file, encryptedExt)
+		}
+
+		b.Entries[kind] = append(b.Entries[kind], Entry{
+			Name:     strings.TrimSuffix(file, ".yaml"),
+			Manifest: content,
+		})
+	}
+
+	return b, nil
+}
+
+func writeFile(tw *tar.Writer, name string, content []byte) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o600,
+		Size:    int64(len(content)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("error writing %s to backup archive: %w", name, err)
+	}
+
+	if _, err := tw.Write(content); err != nil {
+		return fmt.Errorf("error writing %s to backup archive: %w", name, err)
+	}
+
+	return nil
+}
+
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving key from passphrase: %w", err)
+	}
+	return key, nil
+}
+
+// seal encrypts plaintext with AES-GCM, prefixing the random nonce.
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %w", err)
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %w", err)
+	}
+
+	return gcm, nil
+}
@@ -0,0 +1,182 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestWriteRead(t *testing.T) {
+	b := New()
+	b.ColonyYAML = []byte("kind: HelmChart")
+	b.Add("secrets", "10-0-0-1", []byte("kind: Secret"))
+	b.Add("hardware", "node-1", []byte("kind: Hardware"))
+
+	t.Run("unencrypted", func(tt *testing.T) {
+		var buf bytes.Buffer
+		if err := b.Write(&buf, ""); err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if !bytes.Contains(gunzip(tt, buf.Bytes()), []byte("kind: Secret")) {
+			tt.Fatalf("expected the secret to be stored in plaintext")
+		}
+
+		restored, err := Read(&buf, "")
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		assertRestored(tt, restored)
+	})
+
+	t.Run("encrypted", func(tt *testing.T) {
+		var buf bytes.Buffer
+		if err := b.Write(&buf, "correct horse"); err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		archive := buf.Bytes()
+		if bytes.Contains(gunzip(tt, archive), []byte("kind: Secret")) {
+			tt.Fatalf("expected the secret to be encrypted")
+		}
+
+		if _, err := Read(bytes.NewReader(archive), "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+			tt.Fatalf("expected %q but got: %v", ErrWrongPassphrase, err)
+		}
+
+		if _, err := Read(bytes.NewReader(archive), ""); err == nil {
+			tt.Fatalf("expecting an error without a passphrase but got nil")
+		}
+
+		restored, err := Read(bytes.NewReader(archive), "correct horse")
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		assertRestored(tt, restored)
+	})
+}
+
+func TestMarshal(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "tinkerbell.org/v1alpha1",
+		"kind":       "Hardware",
+		"metadata": map[string]interface{}{
+			"name":            "node-1",
+			"namespace":       "tink-system",
+			"uid":             "1234",
+			"resourceVersion": "42",
+			"labels":          map[string]interface{}{"app": "colony"},
+		},
+		"status": map[string]interface{}{"state": "provisioned"},
+	}}
+
+	content, err := Kind{Name: "hardware"}.Marshal(obj)
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	for _, removed := range []string{"uid", "resourceVersion", "status"} {
+		if strings.Contains(string(content), removed) {
+			t.Fatalf("expected %q to be removed, got:\n%s", removed, content)
+		}
+	}
+
+	if !strings.Contains(string(content), "app: colony") {
+		t.Fatalf("expected the labels to be kept, got:\n%s", content)
+	}
+
+	if obj.GetUID() != "1234" {
+		t.Fatalf("expected the object not to be modified")
+	}
+}
+
+func TestKind_SkipReason(t *testing.T) {
+	workflows := Kind{Name: "workflows", Stateful: true}
+
+	workflow := func(state string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "tinkerbell.org/v1alpha1",
+			"kind":       "Workflow",
+			"metadata":   map[string]interface{}{"name": "wipe-disks", "namespace": "tink-system"},
+		}}
+		if state != "" {
+			obj.Object["status"] = map[string]interface{}{"state": state}
+		}
+		return obj
+	}
+
+	tests := []struct {
+		state   string
+		skipped bool
+	}{
+		{state: "STATE_PENDING"},
+		{state: "STATE_RUNNING"},
+		{state: "STATE_SUCCESS", skipped: true},
+		{state: "STATE_FAILED", skipped: true},
+		{state: "STATE_TIMEOUT", skipped: true},
+		{state: "", skipped: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.state, func(tt *testing.T) {
+			content, err := workflows.Marshal(workflow(tc.state))
+			if err != nil {
+				tt.Fatalf("not expecting an error but got: %s", err)
+			}
+
+			reason, err := workflows.SkipReason(content)
+			if err != nil {
+				tt.Fatalf("not expecting an error but got: %s", err)
+			}
+
+			if skipped := reason != ""; skipped != tc.skipped {
+				tt.Fatalf("expected skipped to be %t but got reason %q", tc.skipped, reason)
+			}
+		})
+	}
+
+	if reason, err := (Kind{Name: "hardware"}).SkipReason([]byte("kind: Hardware")); err != nil || reason != "" {
+		t.Fatalf("expected hardware to be restored but got %q, %v", reason, err)
+	}
+}
+
+func assertRestored(t *testing.T, b *Backup) {
+	t.Helper()
+
+	if string(b.ColonyYAML) != "kind: HelmChart" {
+		t.Fatalf("expected colony.yaml to be restored, got %q", b.ColonyYAML)
+	}
+
+	secrets := b.Entries["secrets"]
+	if len(secrets) != 1 || secrets[0].Name != "10-0-0-1" || string(secrets[0].Manifest) != "kind: Secret" {
+		t.Fatalf("unexpected secrets restored: %+v", secrets)
+	}
+
+	hardware := b.Entries["hardware"]
+	if len(hardware) != 1 || hardware[0].Name != "node-1" {
+		t.Fatalf("unexpected hardware restored: %+v", hardware)
+	}
+}
+
+func gunzip(t *testing.T, archive []byte) []byte {
+	t.Helper()
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	content, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	return content
+}
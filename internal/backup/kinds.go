@@ -0,0 +1,120 @@
+package backup
+
+import (
+	"fmt"
+
+	"github.com/kubefirst/tink/api/v1alpha1"
+	rufiov1alpha1 "github.com/tinkerbell/rufio/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// Kind is a kind of object colony backs up.
+type Kind struct {
+	Name string
+	GVR  schema.GroupVersionResource
+
+	// LabelSelector selects the objects to back up, all objects when
+	// empty and no Names are set.
+	LabelSelector string
+	// Names are objects backed up in addition to the selected ones.
+	Names []string
+	// Secret objects are encrypted when a passphrase is given.
+	Secret bool
+	// Stateful objects keep their status in the backup so restore can
+	// skip the ones that already ran.
+	Stateful bool
+}
+
+// Kinds are the kinds colony backs up, in the order they are restored:
+// machines reference the auth secrets, workflows reference hardware and
+// templates.
+var Kinds = []Kind{
+	{
+		Name:          "secrets",
+		GVR:           schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
+		LabelSelector: "colony.konstruct.io/type=ipmi-auth",
+		Names:         []string{APISecretName},
+		Secret:        true,
+	},
+	{
+		Name: "machines",
+		GVR:  rufiov1alpha1.GroupVersion.WithResource("machines"),
+	},
+	{
+		Name: "hardware",
+		GVR:  v1alpha1.GroupVersion.WithResource("hardware"),
+	},
+	{
+		Name: "templates",
+		GVR:  v1alpha1.GroupVersion.WithResource("templates"),
+	},
+	{
+		Name:     "workflows",
+		GVR:      v1alpha1.GroupVersion.WithResource("workflows"),
+		Stateful: true,
+	},
+}
+
+// APISecretName is the secret holding the colony agent credentials. It
+// is backed up, but only restored on request since a freshly initialised
+// colony has its own agent.
+const APISecretName = "colony-api"
+
+// SecretKinds returns the names of the kinds holding secrets.
+func SecretKinds() []string {
+	var names []string
+	for _, kind := range Kinds {
+		if kind.Secret {
+			names = append(names, kind.Name)
+		}
+	}
+	return names
+}
+
+// Marshal returns the object as a YAML manifest without the fields set
+// by the API server, so it can be created again in another cluster. The
+// status is only kept for stateful kinds.
+func (k Kind) Marshal(obj *unstructured.Unstructured) ([]byte, error) {
+	obj = obj.DeepCopy()
+
+	for _, field := range []string{"uid", "resourceVersion", "generation", "creationTimestamp", "managedFields", "selfLink", "ownerReferences"} {
+		unstructured.RemoveNestedField(obj.Object, "metadata", field)
+	}
+	if !k.Stateful {
+		unstructured.RemoveNestedField(obj.Object, "status")
+	}
+
+	content, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling %s %q: %w", obj.GetKind(), obj.GetName(), err)
+	}
+
+	return content, nil
+}
+
+// SkipReason returns why restore must skip the manifest, or an empty
+// string if it can be restored. Workflows are recreated pending, so the
+// ones that finished, or whose state was not backed up, would wipe or
+// reprovision their hardware again on its next netboot.
+func (k Kind) SkipReason(manifest []byte) (string, error) {
+	if !k.Stateful {
+		return "", nil
+	}
+
+	obj := map[string]interface{}{}
+	if err := yaml.Unmarshal(manifest, &obj); err != nil {
+		return "", fmt.Errorf("error parsing %s manifest: %w", k.Name, err)
+	}
+
+	state, _, _ := unstructured.NestedString(obj, "status", "state")
+	switch v1alpha1.WorkflowState(state) {
+	case v1alpha1.WorkflowStatePending, v1alpha1.WorkflowStateRunning:
+		return "", nil
+	case "":
+		return "its state is not in the backup", nil
+	default:
+		return fmt.Sprintf("it already finished with %s", state), nil
+	}
+}
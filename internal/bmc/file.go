@@ -0,0 +1,219 @@
+// Package bmc reads and validates the BMCs colony manages.
+package bmc
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultUsername is the BMC username used when none is set.
+const DefaultUsername = "admin"
+
+// Entry is a BMC and its credentials.
+type Entry struct {
+	IP          string `json:"ip"`
+	Username    string `json:"username,omitempty"`
+	Password    string `json:"password"`
+	InsecureTLS *bool  `json:"insecureTLS,omitempty"`
+}
+
+// Insecure returns whether the BMC certificate is not verified, which is
+// the default as most BMCs use self-signed certificates.
+func (e Entry) Insecure() bool {
+	return e.InsecureTLS == nil || *e.InsecureTLS
+}
+
+// RowError is a problem with an entry of a BMC file.
+type RowError struct {
+	// Row is the 1-based line of a csv file, or index of a yaml or json
+	// list.
+	Row     int
+	Message string
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, e.Message)
+}
+
+// csvColumns are the columns of a BMC csv file, matched by the header.
+var csvColumns = []string{"ip", "username", "password", "insecure_tls"}
+
+// ParseFile reads the BMCs of a csv, yaml or json file, picked by the
+// file extension. Every entry is validated and all problems are reported
+// at once.
+func ParseFile(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %q: %w", path, err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	var rows []int
+	var rowErrs []error
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv":
+		entries, rows, rowErrs, err = parseCSV(f)
+	case ".yaml", ".yml", ".json":
+		entries, err = parseYAML(f)
+		rows = make([]int, len(entries))
+		for i := range rows {
+			rows[i] = i + 1
+		}
+	default:
+		return nil, fmt.Errorf("unsupported file %q, expected a .csv, .yaml or .json file", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %q: %w", path, err)
+	}
+
+	if err := validate(entries, rows, rowErrs); err != nil {
+		return nil, fmt.Errorf("invalid bmc file %q:\n%w", path, err)
+	}
+
+	return entries, nil
+}
+
+// parseCSV returns the entries with their line, and the problems of
+// the rows that could not be parsed.
+func parseCSV(r io.Reader) ([]Entry, []int, []error, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error reading header: %w", err)
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(csvColumns, name) {
+			return nil, nil, nil, fmt.Errorf("unknown column %q, expected: %s", name, strings.Join(csvColumns, ", "))
+		}
+		index[name] = i
+	}
+
+	for _, name := range []string{"ip", "password"} {
+		if _, ok := index[name]; !ok {
+			return nil, nil, nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+
+	var entries []Entry
+	var rows []int
+	var errs []error
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("error reading record: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		// missing trailing columns are empty
+		get := func(name string) string {
+			i, ok := index[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		entry := Entry{
+			IP:       get("ip"),
+			Username: get("username"),
+			Password: get("password"),
+		}
+
+		if value := get("insecure_tls"); value != "" {
+			insecure, err := strconv.ParseBool(value)
+			if err != nil {
+				errs = append(errs, &RowError{Row: line, Message: fmt.Sprintf("insecure_tls %q is not a boolean", value)})
+			} else {
+				entry.InsecureTLS = &insecure
+			}
+		}
+
+		entries = append(entries, entry)
+		rows = append(rows, line)
+	}
+
+	return entries, rows, errs, nil
+}
+
+// bmcFile is a yaml or json BMC file, either a list of entries or an
+// object with a bmcs list.
+type bmcFile struct {
+	BMCs []Entry `json:"bmcs"`
+}
+
+func parseYAML(r io.Reader) ([]Entry, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	var entries []Entry
+	if err := yaml.UnmarshalStrict(content, &entries); err == nil {
+		return entries, nil
+	}
+
+	var f bmcFile
+	if err := yaml.UnmarshalStrict(content, &f); err != nil {
+		return nil, fmt.Errorf("expected a list of bmcs or an object with a bmcs list: %w", err)
+	}
+
+	return f.BMCs, nil
+}
+
+// validate checks the entries and sets the default username. The
+// problems found while parsing are reported along.
+func validate(entries []Entry, rows []int, errs []error) error {
+	seen := make(map[string]int, len(entries))
+
+	for i := range entries {
+		entry := &entries[i]
+		row := rows[i]
+
+		if entry.Username == "" {
+			entry.Username = DefaultUsername
+		}
+
+		switch {
+		case entry.IP == "":
+			errs = append(errs, &RowError{Row: row, Message: "ip is required"})
+		case net.ParseIP(entry.IP) == nil:
+			errs = append(errs, &RowError{Row: row, Message: fmt.Sprintf("%q is not a valid ip address", entry.IP)})
+		default:
+			if first, ok := seen[entry.IP]; ok {
+				errs = append(errs, &RowError{Row: row, Message: fmt.Sprintf("%s is already listed on row %d", entry.IP, first)})
+			}
+			seen[entry.IP] = row
+		}
+
+		if entry.Password == "" {
+			errs = append(errs, &RowError{Row: row, Message: "password is required"})
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,106 @@
+package bmc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("error writing bmc file: %s", err)
+	}
+	return path
+}
+
+func TestParseFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "csv",
+			file: "bmcs.csv",
+			content: `password, ip, insecure_tls
+secret, 10.0.0.1, false
+# a comment
+secret, 10.0.0.2
+`,
+		},
+		{
+			name: "yaml list",
+			file: "bmcs.yaml",
+			content: `- ip: 10.0.0.1
+  password: secret
+  insecureTLS: false
+- ip: 10.0.0.2
+  password: secret
+`,
+		},
+		{
+			name:    "json object",
+			file:    "bmcs.json",
+			content: `{"bmcs": [{"ip": "10.0.0.1", "password": "secret", "insecureTLS": false}, {"ip": "10.0.0.2", "password": "secret"}]}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(tt *testing.T) {
+			entries, err := ParseFile(writeFile(tt, tc.file, tc.content))
+			if err != nil {
+				tt.Fatalf("not expecting an error but got: %s", err)
+			}
+
+			if len(entries) != 2 {
+				tt.Fatalf("expected 2 entries but got %d", len(entries))
+			}
+
+			if entries[0].IP != "10.0.0.1" || entries[0].Username != DefaultUsername || entries[0].Insecure() {
+				tt.Fatalf("unexpected first entry: %+v", entries[0])
+			}
+
+			if entries[1].IP != "10.0.0.2" || !entries[1].Insecure() {
+				tt.Fatalf("unexpected second entry: %+v", entries[1])
+			}
+		})
+	}
+}
+
+func TestParseFile_Invalid(t *testing.T) {
+	path := writeFile(t, "bmcs.csv", `ip,username,password,insecure_tls
+10.0.0.1,root
+10.0.0.300,root,secret
+10.0.0.1,root,secret,maybe
+`)
+
+	_, err := ParseFile(path)
+	if err == nil {
+		t.Fatalf("expecting an error but got nil")
+	}
+
+	for _, expected := range []string{
+		"row 2: password is required",
+		`row 3: "10.0.0.300" is not a valid ip address`,
+		"row 4: 10.0.0.1 is already listed on row 2",
+		`row 4: insecure_tls "maybe" is not a boolean`,
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("expected the error to contain %q but got:\n%s", expected, err)
+		}
+	}
+
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) {
+		t.Fatalf("expected a row error but got: %v", err)
+	}
+
+	if _, err := ParseFile(writeFile(t, "bmcs.csv", "ip,user\n")); err == nil {
+		t.Fatalf("expecting an error for an unknown column but got nil")
+	}
+}
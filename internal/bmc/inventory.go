@@ -0,0 +1,163 @@
+package bmc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bmc-toolbox/bmclib/v2"
+	"github.com/bmc-toolbox/common"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ReadInventory logs into the BMC at ip and reads the inventory of its
+// server: cpus, memory, drives, nics, power supplies and firmware versions.
+func ReadInventory(ctx context.Context, ip string, cred Credential) (*common.Device, error) {
+	client := bmclib.NewClient(ip, cred.Username, cred.Password)
+	if err := client.Open(ctx); err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %w", ip, err)
+	}
+	defer client.Close(context.Background())
+
+	inventory, err := client.Inventory(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting inventory of %s: %w", ip, err)
+	}
+
+	return inventory, nil
+}
+
+// Component is a single part of an inventory, flattened for display.
+type Component struct {
+	Kind     string
+	ID       string
+	Vendor   string
+	Model    string
+	Serial   string
+	Firmware string
+	Details  string
+}
+
+// Components lists the parts of the inventory, the server first then its
+// firmware, cpus, memory, drives, nics and power supplies.
+func Components(device *common.Device) []Component {
+	if device == nil {
+		return nil
+	}
+
+	components := []Component{newComponent("server", "", &device.Common, "")}
+
+	if device.BMC != nil {
+		components = append(components, newComponent("bmc", device.BMC.ID, &device.BMC.Common, ""))
+	}
+	if device.BIOS != nil {
+		components = append(components, newComponent("bios", "", &device.BIOS.Common, ""))
+	}
+	if device.Mainboard != nil {
+		components = append(components, newComponent("mainboard", "", &device.Mainboard.Common, ""))
+	}
+
+	for _, cpu := range device.CPUs {
+		details := []string{}
+		if cpu.Cores > 0 {
+			details = append(details, strconv.Itoa(cpu.Cores)+" cores")
+		}
+		if cpu.Threads > 0 {
+			details = append(details, strconv.Itoa(cpu.Threads)+" threads")
+		}
+		if cpu.ClockSpeedHz > 0 {
+			details = append(details, fmt.Sprintf("%.2fGHz", float64(cpu.ClockSpeedHz)/1e9))
+		}
+		components = append(components, newComponent("cpu", firstOf(cpu.Slot, cpu.ID), &cpu.Common, strings.Join(details, ", ")))
+	}
+
+	for _, dimm := range device.Memory {
+		components = append(components, newComponent("memory", firstOf(dimm.Slot, dimm.ID), &dimm.Common, joinDetails(byteSize(dimm.SizeBytes), dimm.Type)))
+	}
+
+	for _, drive := range device.Drives {
+		components = append(components, newComponent("drive", drive.ID, &drive.Common, joinDetails(byteSize(drive.CapacityBytes), drive.Type, drive.Protocol)))
+	}
+
+	for _, nic := range device.NICs {
+		macs := make([]string, 0, len(nic.NICPorts))
+		for _, port := range nic.NICPorts {
+			if port.MacAddress != "" {
+				macs = append(macs, port.MacAddress)
+			}
+		}
+		components = append(components, newComponent("nic", nic.ID, &nic.Common, strings.Join(macs, ", ")))
+	}
+
+	for _, psu := range device.PSUs {
+		details := ""
+		if psu.PowerCapacityWatts > 0 {
+			details = strconv.FormatInt(psu.PowerCapacityWatts, 10) + "W"
+		}
+		components = append(components, newComponent("psu", psu.ID, &psu.Common, details))
+	}
+
+	return components
+}
+
+// MACAddresses returns the MAC addresses of the NIC ports of the
+// inventory.
+func MACAddresses(device *common.Device) []string {
+	if device == nil {
+		return nil
+	}
+
+	var macs []string
+	for _, nic := range device.NICs {
+		for _, port := range nic.NICPorts {
+			if port.MacAddress != "" {
+				macs = append(macs, port.MacAddress)
+			}
+		}
+	}
+
+	return macs
+}
+
+func newComponent(kind, id string, c *common.Common, details string) Component {
+	component := Component{
+		Kind:    kind,
+		ID:      id,
+		Vendor:  c.Vendor,
+		Model:   firstOf(c.Model, c.ProductName),
+		Serial:  c.Serial,
+		Details: details,
+	}
+	if c.Firmware != nil {
+		component.Firmware = c.Firmware.Installed
+	}
+	return component
+}
+
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func joinDetails(details ...string) string {
+	nonEmpty := make([]string, 0, len(details))
+	for _, d := range details {
+		if d != "" {
+			nonEmpty = append(nonEmpty, d)
+		}
+	}
+	return strings.Join(nonEmpty, ", ")
+}
+
+// byteSize formats a size in binary units, like 32Gi.
+func byteSize(size int64) string {
+	if size <= 0 {
+		return ""
+	}
+	return resource.NewQuantity(size, resource.BinarySI).String()
+}
@@ -0,0 +1,53 @@
+package bmc
+
+import (
+	"testing"
+
+	"github.com/bmc-toolbox/common"
+)
+
+func TestComponents(t *testing.T) {
+	device := &common.Device{
+		Common: common.Common{Vendor: "Dell", Model: "R650", Serial: "SN1"},
+		BMC:    &common.BMC{Common: common.Common{Firmware: &common.Firmware{Installed: "7.00"}}},
+		CPUs: []*common.CPU{
+			{Common: common.Common{Vendor: "Intel", Model: "Xeon"}, Slot: "CPU.1", Cores: 32, Threads: 64, ClockSpeedHz: 2_800_000_000},
+		},
+		Memory: []*common.Memory{
+			{Common: common.Common{Vendor: "Samsung"}, Slot: "A1", SizeBytes: 32 << 30, Type: "DDR4"},
+		},
+		Drives: []*common.Drive{
+			{Common: common.Common{Model: "PM883"}, ID: "Disk.0", CapacityBytes: 960 << 30, Type: "SSD"},
+		},
+		NICs: []*common.NIC{
+			{ID: "NIC.1", NICPorts: []*common.NICPort{{MacAddress: "00:11:22:33:44:55"}, {MacAddress: "00:11:22:33:44:56"}}},
+		},
+		PSUs: []*common.PSU{{ID: "PSU.1", PowerCapacityWatts: 800}},
+	}
+
+	components := Components(device)
+
+	expected := []Component{
+		{Kind: "server", Vendor: "Dell", Model: "R650", Serial: "SN1"},
+		{Kind: "bmc", Firmware: "7.00"},
+		{Kind: "cpu", ID: "CPU.1", Vendor: "Intel", Model: "Xeon", Details: "32 cores, 64 threads, 2.80GHz"},
+		{Kind: "memory", ID: "A1", Vendor: "Samsung", Details: "32Gi, DDR4"},
+		{Kind: "drive", ID: "Disk.0", Model: "PM883", Details: "960Gi, SSD"},
+		{Kind: "nic", ID: "NIC.1", Details: "00:11:22:33:44:55, 00:11:22:33:44:56"},
+		{Kind: "psu", ID: "PSU.1", Details: "800W"},
+	}
+
+	if len(components) != len(expected) {
+		t.Fatalf("expected %d components but got %d: %+v", len(expected), len(components), components)
+	}
+
+	for i := range expected {
+		if components[i] != expected[i] {
+			t.Fatalf("expected component %d to be %+v but got %+v", i, expected[i], components[i])
+		}
+	}
+
+	if Components(nil) != nil {
+		t.Fatal("expected no components without an inventory")
+	}
+}
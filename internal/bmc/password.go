@@ -0,0 +1,63 @@
+package bmc
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// PasswordLength is the length of the generated BMC passwords. IPMI 2.0
+// caps passwords at 20 characters.
+const PasswordLength = 20
+
+// passwordClasses are the character classes of a generated password.
+// Symbols are left out as many BMCs reject some of them.
+var passwordClasses = []string{
+	"ABCDEFGHJKLMNPQRSTUVWXYZ",
+	"abcdefghijkmnopqrstuvwxyz",
+	"23456789",
+}
+
+// GeneratePassword returns a random password of the given length with at
+// least one character of every class.
+func GeneratePassword(length int) (string, error) {
+	if length < len(passwordClasses) {
+		return "", fmt.Errorf("a password needs at least %d characters", len(passwordClasses))
+	}
+
+	all := strings.Join(passwordClasses, "")
+	password := make([]byte, length)
+
+	for i := range password {
+		charset := all
+		if i < len(passwordClasses) {
+			charset = passwordClasses[i]
+		}
+
+		c, err := randomChar(charset)
+		if err != nil {
+			return "", err
+		}
+		password[i] = c
+	}
+
+	// move the required characters to random positions
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", fmt.Errorf("error generating password: %w", err)
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+
+	return string(password), nil
+}
+
+func randomChar(charset string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+	if err != nil {
+		return 0, fmt.Errorf("error generating password: %w", err)
+	}
+	return charset[n.Int64()], nil
+}
@@ -0,0 +1,31 @@
+package bmc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGeneratePassword(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		password, err := GeneratePassword(PasswordLength)
+		if err != nil {
+			t.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if len(password) != PasswordLength || seen[password] {
+			t.Fatalf("expected a new password of %d characters but got %q", PasswordLength, password)
+		}
+		seen[password] = true
+
+		for _, class := range passwordClasses {
+			if !strings.ContainsAny(password, class) {
+				t.Fatalf("expected %q to contain one of %q", password, class)
+			}
+		}
+	}
+
+	if _, err := GeneratePassword(2); err == nil {
+		t.Fatalf("expecting an error for a short password but got nil")
+	}
+}
@@ -0,0 +1,300 @@
+package bmc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// RedfishPort is the https port of the redfish api.
+	RedfishPort = 443
+
+	// IPMIPort is the udp port of the ipmi lan interface.
+	IPMIPort = 623
+
+	// DefaultProbeTimeout bounds the probe of a single port.
+	DefaultProbeTimeout = 2 * time.Second
+
+	// maxScanHosts caps the size of a scanned network, a /16.
+	maxScanHosts = 1 << 16
+)
+
+// rmcpPresencePing is an ASF presence ping over RMCP. Every ipmi lan
+// interface answers it with a presence pong, without authentication.
+var rmcpPresencePing = []byte{
+	0x06, 0x00, 0xff, 0x06, // rmcp version 1.0, no ack, asf class
+	0x00, 0x00, 0x11, 0xbe, // asf iana enterprise number
+	0x80, 0x00, 0x00, 0x00, // presence ping, tag, reserved, no data
+}
+
+// rmcpPresencePong is the message type of a presence pong.
+const rmcpPresencePong = 0x40
+
+// Credential is a username and password tried on the scanned BMCs.
+type Credential struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// credentialsFile is a yaml or json credentials file, either a list of
+// credentials or an object with a credentials list.
+type credentialsFile struct {
+	Credentials []Credential `json:"credentials"`
+}
+
+// ParseCredentials reads the credential sets of a yaml or json file.
+func ParseCredentials(path string) ([]Credential, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %q: %w", path, err)
+	}
+
+	var creds []Credential
+	if err := yaml.UnmarshalStrict(content, &creds); err != nil {
+		var f credentialsFile
+		if err := yaml.UnmarshalStrict(content, &f); err != nil {
+			return nil, fmt.Errorf("error parsing %q, expected a list of credentials or an object with a credentials list: %w", path, err)
+		}
+		creds = f.Credentials
+	}
+
+	if len(creds) == 0 {
+		return nil, fmt.Errorf("no credentials found in %q", path)
+	}
+
+	for i := range creds {
+		if creds[i].Username == "" {
+			creds[i].Username = DefaultUsername
+		}
+		if creds[i].Password == "" {
+			return nil, fmt.Errorf("credentials %d of %q: password is required", i+1, path)
+		}
+	}
+
+	return creds, nil
+}
+
+// Hosts returns the host addresses of an IPv4 network, leaving out the
+// network and broadcast addresses.
+func Hosts(cidr string) ([]string, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cidr %q: %w", cidr, err)
+	}
+
+	if !prefix.Addr().Is4() {
+		return nil, fmt.Errorf("invalid cidr %q: only ipv4 networks can be scanned", cidr)
+	}
+
+	bits := 32 - prefix.Bits()
+	if 1<<bits > maxScanHosts {
+		return nil, fmt.Errorf("cidr %q is too large, scan at most a /16", cidr)
+	}
+
+	prefix = prefix.Masked()
+	hosts := make([]string, 0, 1<<bits)
+	for addr := prefix.Addr(); prefix.Contains(addr); addr = addr.Next() {
+		hosts = append(hosts, addr.String())
+	}
+
+	// /31 and /32 networks have no network or broadcast address
+	if bits > 1 {
+		hosts = hosts[1 : len(hosts)-1]
+	}
+
+	return hosts, nil
+}
+
+// Identity is what a BMC reports once logged in.
+type Identity struct {
+	Vendor      string
+	Model       string
+	BoardSerial string
+}
+
+// LoginFunc logs into the BMC at ip and returns its identity.
+type LoginFunc func(ctx context.Context, ip string, cred Credential) (Identity, error)
+
+// Login logs into the BMC with bmclib and reads its inventory.
+func Login(ctx context.Context, ip string, cred Credential) (Identity, error) {
+	inventory, err := ReadInventory(ctx, ip, cred)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	return Identity{
+		Vendor:      inventory.Vendor,
+		Model:       inventory.Model,
+		BoardSerial: inventory.Serial,
+	}, nil
+}
+
+// ScanResult is a BMC found by a scan.
+type ScanResult struct {
+	IP          string `json:"ip"`
+	Redfish     bool   `json:"redfish"`
+	IPMI        bool   `json:"ipmi"`
+	Vendor      string `json:"vendor,omitempty"`
+	Model       string `json:"model,omitempty"`
+	BoardSerial string `json:"boardSerial,omitempty"`
+	Username    string `json:"username,omitempty"`
+	Error       string `json:"error,omitempty"`
+
+	// Credential is the credential set that logged in, if any.
+	Credential *Credential `json:"-"`
+}
+
+// Scanner looks for BMCs in a network.
+type Scanner struct {
+	Credentials []Credential
+	Concurrency int
+	Timeout     time.Duration
+	Login       LoginFunc
+
+	// RedfishPort and IPMIPort default to the standard ports.
+	RedfishPort int
+	IPMIPort    int
+}
+
+// Scan probes the hosts for a redfish or ipmi endpoint, then tries the
+// credential sets on every BMC found. It returns the BMCs in the order of
+// the hosts.
+func (s *Scanner) Scan(ctx context.Context, hosts []string) []ScanResult {
+	results := make([]*ScanResult, len(hosts))
+	sem := make(chan struct{}, max(s.Concurrency, 1))
+
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if ctx.Err() != nil {
+				return
+			}
+			results[i] = s.scanHost(ctx, host)
+		}()
+	}
+	wg.Wait()
+
+	found := make([]ScanResult, 0)
+	for _, result := range results {
+		if result != nil {
+			found = append(found, *result)
+		}
+	}
+
+	return found
+}
+
+func (s *Scanner) scanHost(ctx context.Context, ip string) *ScanResult {
+	result := &ScanResult{
+		IP:      ip,
+		Redfish: s.probeRedfish(ctx, ip),
+		IPMI:    s.probeIPMI(ctx, ip),
+	}
+	if !result.Redfish && !result.IPMI {
+		return nil
+	}
+
+	login := s.Login
+	if login == nil {
+		login = Login
+	}
+
+	var errs []error
+	for i := range s.Credentials {
+		cred := s.Credentials[i]
+
+		loginCtx, cancel := context.WithTimeout(ctx, 10*s.timeout())
+		identity, err := login(loginCtx, ip, cred)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cred.Username, err))
+			continue
+		}
+
+		result.Vendor = identity.Vendor
+		result.Model = identity.Model
+		result.BoardSerial = identity.BoardSerial
+		result.Username = cred.Username
+		result.Credential = &cred
+		return result
+	}
+
+	result.Error = fmt.Sprintf("no credentials accepted: %s", errors.Join(errs...))
+	return result
+}
+
+func (s *Scanner) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return DefaultProbeTimeout
+}
+
+func (s *Scanner) probeRedfish(ctx context.Context, ip string) bool {
+	port := s.RedfishPort
+	if port == 0 {
+		port = RedfishPort
+	}
+
+	dialer := net.Dialer{Timeout: s.timeout()}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, strconv.Itoa(port)))
+	if err != nil {
+		return false
+	}
+	conn.Close()
+
+	return true
+}
+
+func (s *Scanner) probeIPMI(ctx context.Context, ip string) bool {
+	port := s.IPMIPort
+	if port == 0 {
+		port = IPMIPort
+	}
+
+	dialer := net.Dialer{Timeout: s.timeout()}
+	conn, err := dialer.DialContext(ctx, "udp", net.JoinHostPort(ip, strconv.Itoa(port)))
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(s.timeout())); err != nil {
+		return false
+	}
+
+	if _, err := conn.Write(rmcpPresencePing); err != nil {
+		return false
+	}
+
+	pong := make([]byte, 64)
+	n, err := conn.Read(pong)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false
+	}
+
+	return isPresencePong(pong[:n])
+}
+
+// isPresencePong returns true for an ASF presence pong over RMCP.
+func isPresencePong(msg []byte) bool {
+	return len(msg) >= 9 && msg[0] == 0x06 && msg[3] == 0x06 &&
+		bytes.Equal(msg[4:8], rmcpPresencePing[4:8]) && msg[8] == rmcpPresencePong
+}
@@ -0,0 +1,98 @@
+package bmc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHosts(t *testing.T) {
+	tests := []struct {
+		cidr     string
+		expected []string
+	}{
+		{cidr: "10.0.0.0/30", expected: []string{"10.0.0.1", "10.0.0.2"}},
+		{cidr: "10.0.0.5/30", expected: []string{"10.0.0.5", "10.0.0.6"}},
+		{cidr: "10.0.0.4/31", expected: []string{"10.0.0.4", "10.0.0.5"}},
+		{cidr: "10.0.0.9/32", expected: []string{"10.0.0.9"}},
+	}
+
+	for _, tc := range tests {
+		hosts, err := Hosts(tc.cidr)
+		if err != nil {
+			t.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if len(hosts) != len(tc.expected) {
+			t.Fatalf("expected %v for %s but got %v", tc.expected, tc.cidr, hosts)
+		}
+		for i := range hosts {
+			if hosts[i] != tc.expected[i] {
+				t.Fatalf("expected %v for %s but got %v", tc.expected, tc.cidr, hosts)
+			}
+		}
+	}
+
+	for _, cidr := range []string{"10.0.0.0", "10.0.0.0/8", "fd00::/120"} {
+		if _, err := Hosts(cidr); err == nil {
+			t.Fatalf("expecting an error for %q but got nil", cidr)
+		}
+	}
+}
+
+func TestScanner_Scan(t *testing.T) {
+	redfish, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %s", err)
+	}
+	defer redfish.Close()
+
+	ipmi, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %s", err)
+	}
+	defer ipmi.Close()
+
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			_, addr, err := ipmi.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pong := append([]byte{}, rmcpPresencePing...)
+			pong[8] = rmcpPresencePong
+			_, _ = ipmi.WriteTo(pong, addr)
+		}
+	}()
+
+	scanner := &Scanner{
+		Credentials: []Credential{{Username: "admin", Password: "wrong"}, {Username: "root", Password: "calvin"}},
+		Concurrency: 2,
+		Timeout:     200 * time.Millisecond,
+		RedfishPort: redfish.Addr().(*net.TCPAddr).Port,
+		IPMIPort:    ipmi.LocalAddr().(*net.UDPAddr).Port,
+		Login: func(_ context.Context, ip string, cred Credential) (Identity, error) {
+			if ip != "127.0.0.1" || cred.Password != "calvin" {
+				return Identity{}, errors.New("invalid credentials")
+			}
+			return Identity{Vendor: "Dell Inc.", Model: "PowerEdge R640", BoardSerial: "ABC123"}, nil
+		},
+	}
+
+	results := scanner.Scan(context.Background(), []string{"127.0.0.1", "127.0.0.2"})
+	if len(results) != 1 {
+		t.Fatalf("expected a single bmc but got %+v", results)
+	}
+
+	result := results[0]
+	if !result.Redfish || !result.IPMI {
+		t.Fatalf("expected redfish and ipmi to be found but got %+v", result)
+	}
+
+	if result.BoardSerial != "ABC123" || result.Username != "root" || result.Credential == nil || result.Error != "" {
+		t.Fatalf("expected the second credentials to log in but got %+v", result)
+	}
+}
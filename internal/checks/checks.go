@@ -0,0 +1,86 @@
+package checks
+
+import (
+	"fmt"
+
+	"github.com/konstructio/colony/internal/printer"
+	"github.com/konstructio/colony/internal/table"
+)
+
+// Status is the outcome of a single check.
+type Status string
+
+const (
+	Pass Status = "pass"
+	Warn Status = "warn"
+	Fail Status = "fail"
+)
+
+// Result is the outcome of a single check.
+type Result struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+}
+
+// Passf returns a passing result.
+func Passf(name, format string, args ...interface{}) Result {
+	return Result{Name: name, Status: Pass, Message: fmt.Sprintf(format, args...)}
+}
+
+// Warnf returns a warning result.
+func Warnf(name, format string, args ...interface{}) Result {
+	return Result{Name: name, Status: Warn, Message: fmt.Sprintf(format, args...)}
+}
+
+// Failf returns a failing result.
+func Failf(name, format string, args ...interface{}) Result {
+	return Result{Name: name, Status: Fail, Message: fmt.Sprintf(format, args...)}
+}
+
+// Report is a list of check results.
+type Report []Result
+
+// Failed returns true if any check in the report failed.
+func (r Report) Failed() bool {
+	for _, result := range r {
+		if result.Status == Fail {
+			return true
+		}
+	}
+	return false
+}
+
+// Print prints the report as a table.
+func (r Report) Print() {
+	rows := r.Rows()
+
+	columns := make([]table.Column, 0, len(rows.Columns))
+	for _, col := range rows.Columns {
+		columns = append(columns, table.Column{Name: col.Name, Align: "left"})
+	}
+
+	table.NewTablePrinter(columns).PrintTable(rows.Items)
+}
+
+// Rows returns the table view of the report.
+func (r Report) Rows() printer.Rows {
+	rows := printer.Rows{
+		Columns: []printer.Column{
+			{Name: "check"},
+			{Name: "status"},
+			{Name: "message"},
+		},
+		Items: make([]map[string]string, 0, len(r)),
+	}
+
+	for _, result := range r {
+		rows.Items = append(rows.Items, map[string]string{
+			"check":   result.Name,
+			"status":  string(result.Status),
+			"message": result.Message,
+		})
+	}
+
+	return rows
+}
@@ -0,0 +1,51 @@
+package checks
+
+import "testing"
+
+func TestReport_Failed(t *testing.T) {
+	tests := []struct {
+		name   string
+		report Report
+		want   bool
+	}{
+		{name: "empty report", report: Report{}, want: false},
+		{name: "all passing", report: Report{Passf("a", "ok"), Passf("b", "ok")}, want: false},
+		{name: "warnings only", report: Report{Passf("a", "ok"), Warnf("b", "careful")}, want: false},
+		{name: "one failure", report: Report{Passf("a", "ok"), Warnf("b", "careful"), Failf("c", "broken")}, want: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(tt *testing.T) {
+			if got := tc.report.Failed(); got != tc.want {
+				tt.Fatalf("expected %t but got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestReport_Rows(t *testing.T) {
+	report := Report{
+		Passf("ports", "all %d ports are available", 3),
+		Warnf("disk-space", "only %.1f GiB free", 12.5),
+		Failf("container-runtime", "daemon is not reachable"),
+	}
+
+	rows := report.Rows()
+	if len(rows.Items) != len(report) {
+		t.Fatalf("expected %d rows but got %d", len(report), len(rows.Items))
+	}
+
+	want := []map[string]string{
+		{"check": "ports", "status": "pass", "message": "all 3 ports are available"},
+		{"check": "disk-space", "status": "warn", "message": "only 12.5 GiB free"},
+		{"check": "container-runtime", "status": "fail", "message": "daemon is not reachable"},
+	}
+
+	for i, row := range rows.Items {
+		for key, value := range want[i] {
+			if row[key] != value {
+				t.Fatalf("row %d: expected %s %q but got %q", i, key, value, row[key])
+			}
+		}
+	}
+}
@@ -7,148 +7,480 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math/rand/v2"
 	"net/http"
+	"strconv"
 	"time"
 )
 
+const (
+	// DefaultTimeout bounds a single attempt of a request.
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultMaxRetries is the number of times a failed request is retried.
+	DefaultMaxRetries = 4
+
+	defaultMinBackoff = 500 * time.Millisecond
+	defaultMaxBackoff = 15 * time.Second
+
+	// maxErrorBody caps how much of an error response is kept.
+	maxErrorBody = 64 << 10
+)
+
 type API struct {
-	client  *http.Client
-	baseURL string
-	token   string
+	client     *http.Client
+	baseURL    string
+	token      string
+	timeout    time.Duration
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
 }
 
 var ErrDataCenterAlreadyRegistered = errors.New("data center already has an agent registered")
 
-// New creates a new colony API client
-func New(baseURL, token string) *API {
-	return &API{
+// Option configures the colony API client.
+type Option func(*API)
+
+// WithTimeout bounds every attempt of a request. A zero timeout only
+// relies on the context deadline.
+func WithTimeout(timeout time.Duration) Option {
+	return func(a *API) {
+		a.timeout = timeout
+	}
+}
+
+// WithMaxRetries sets how many times a failed request is retried. Zero
+// disables retries.
+func WithMaxRetries(maxRetries int) Option {
+	return func(a *API) {
+		a.maxRetries = maxRetries
+	}
+}
+
+// WithBackoff sets the delay before the first retry and the maximum delay
+// between two retries.
+func WithBackoff(minBackoff, maxBackoff time.Duration) Option {
+	return func(a *API) {
+		a.minBackoff = minBackoff
+		a.maxBackoff = maxBackoff
+	}
+}
+
+// WithTLSConfig sets the TLS config used to reach the API.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(a *API) {
+		// clone the transport, the client may be shared with copies made by With
+		transport := a.client.Transport.(*http.Transport).Clone()
+		transport.TLSClientConfig = cfg
+		a.client = &http.Client{Transport: transport}
+	}
+}
+
+// New creates a new colony API client. TLS certificates are verified
+// against the system roots unless configured otherwise.
+func New(baseURL, token string, opts ...Option) *API {
+	a := &API{
 		baseURL: baseURL,
 		token:   token,
 		client: &http.Client{
-			Timeout: time.Second * 10,
 			Transport: &http.Transport{
 				MaxIdleConns:        100,
 				MaxConnsPerHost:     100,
 				MaxIdleConnsPerHost: 100,
-				TLSClientConfig:     &tls.Config{InsecureSkipVerify: true}, //nolint:gosec
+				TLSClientConfig:     &tls.Config{MinVersion: tls.VersionTLS12},
 			},
 		},
+		timeout:    DefaultTimeout,
+		maxRetries: DefaultMaxRetries,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+	}
+
+	for _, opt := range opts {
+		opt(a)
 	}
+
+	return a
 }
 
-type RegisterAgentRequest struct {
-	DataCenterID string          `json:"datacenter_id"`
-	State        json.RawMessage `json:"state"`
+// With returns a copy of the client with the options applied, so a single
+// call can use its own timeout or retries:
+//
+//	api.With(colony.WithTimeout(time.Minute)).CleanDatacenter(ctx, agentID)
+func (a *API) With(opts ...Option) *API {
+	c := *a
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return &c
 }
 
-type RegisterAgentResponse struct {
-	Data Agent `json:"data"`
+// APIError is a non successful response of the colony API.
+type APIError struct {
+	StatusCode int
+	RequestID  string
+	Message    string
+	Body       []byte
 }
 
-type Agent struct {
-	ID string `json:"id"`
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("colony api returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.RequestID != "" {
+		msg += fmt.Sprintf(" (request id %s)", e.RequestID)
+	}
+	return msg
 }
 
-func (a *API) RegisterAgent(ctx context.Context, dataCenterID string) (*Agent, error) {
-	registerAgentRequest := RegisterAgentRequest{
-		DataCenterID: dataCenterID,
-		State:        json.RawMessage(`{"status": "initializing"}`),
+// Temporary reports whether the request may succeed if retried.
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// errorBody is the body of the colony API error responses.
+type errorBody struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+func newAPIError(res *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+
+	apiErr := &APIError{
+		StatusCode: res.StatusCode,
+		RequestID:  res.Header.Get("X-Request-Id"),
+		Body:       body,
 	}
 
-	body, err := json.Marshal(registerAgentRequest)
-	if err != nil {
-		return nil, fmt.Errorf("error marshalling register agent request: %w", err)
+	var eb errorBody
+	if err := json.Unmarshal(body, &eb); err == nil {
+		apiErr.Message = eb.Message
+		if apiErr.Message == "" {
+			apiErr.Message = eb.Error
+		}
 	}
 
-	registerAgentEndpoint := fmt.Sprintf("%s/api/v1/agents", a.baseURL)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, registerAgentEndpoint, bytes.NewReader(body))
+	return apiErr
+}
+
+// request is a single call to the colony API.
+type request struct {
+	method string
+	path   string
+	body   interface{}
+	// expected is the status code of a successful response.
+	expected int
+	// idempotent requests are also retried on network errors and 5xx
+	// responses, the others only when rate limited.
+	idempotent bool
+}
+
+// do sends the request, retrying it with a jittered exponential backoff,
+// and decodes a successful response into out if not nil. A Retry-After
+// longer than the maximum backoff is not waited for.
+func (a *API) do(ctx context.Context, r request, out interface{}) error {
+	var body []byte
+	if r.body != nil {
+		var err error
+		body, err = json.Marshal(r.body)
+		if err != nil {
+			return fmt.Errorf("error marshalling request: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := a.attempt(ctx, r, body, out)
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= a.maxRetries || !r.retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		delay := backoff(a.minBackoff, a.maxBackoff, attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+
+		// a server asking to wait longer than the maximum backoff, or
+		// than the context leaves, gets its error returned right away
+		if delay > a.maxBackoff {
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt sends the request once. It returns the delay the server asked
+// to wait before retrying, if any.
+func (a *API) attempt(ctx context.Context, r request, body []byte, out interface{}) (time.Duration, error) {
+	if a.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.timeout)
+		defer cancel()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.method, a.baseURL+r.path, reader)
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+		return 0, fmt.Errorf("error creating request: %w", err)
 	}
 
-	req.Header.Add("Content-Type", "application/json")
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	req.Header.Add("Accept", "application/json")
 	req.Header.Add("Authorization", "Bearer "+a.token)
 
 	res, err := a.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error making request: %w", err)
+		return 0, fmt.Errorf("error making request: %w", err)
 	}
-
 	defer res.Body.Close()
 
-	if res.StatusCode == http.StatusConflict {
-		return nil, ErrDataCenterAlreadyRegistered
+	if res.StatusCode != r.expected {
+		return parseRetryAfter(res.Header.Get("Retry-After"), time.Now()), newAPIError(res)
 	}
 
-	if res.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	if out != nil {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			return 0, fmt.Errorf("error decoding response: %w", err)
+		}
 	}
 
-	var resp RegisterAgentResponse
-	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
-		return nil, fmt.Errorf("error decoding response: %w", err)
+	return 0, nil
+}
+
+func (r request) retryable(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		// the request may not have reached the api
+		return r.idempotent
 	}
 
-	return &resp.Data, nil
-}
+	if apiErr.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
 
-type HeartbeatRequest struct {
-	State json.RawMessage `json:"state"`
+	return r.idempotent && apiErr.Temporary()
 }
 
-func (a *API) Heartbeat(ctx context.Context, agentID string) error {
-	initialState := HeartbeatRequest{
-		State: json.RawMessage(`{"status": "initializing"}`),
+// backoff returns the delay before the retry following the given attempt:
+// min doubled for every attempt, capped at max, with full jitter over the
+// upper half so clients failing together do not retry together.
+func backoff(minDelay, maxDelay time.Duration, attempt int) time.Duration {
+	delay := minDelay
+	for i := 0; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
 	}
 
-	body, err := json.Marshal(initialState)
-	if err != nil {
-		return fmt.Errorf("error marshalling initial state: %w", err)
+	if delay > maxDelay {
+		delay = maxDelay
 	}
 
-	heartbeatEndpoint := fmt.Sprintf("%s/api/v1/agents/%s/heartbeat", a.baseURL, agentID)
+	return delay/2 + time.Duration(rand.Int64N(int64(delay)/2+1))
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, heartbeatEndpoint, bytes.NewReader(body))
-	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+// parseRetryAfter returns the delay of a Retry-After header, given either
+// in seconds or as an http date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
 	}
 
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Authorization", "Bearer "+a.token)
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
 
-	res, err := a.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("error making request: %w", err)
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	return 0
+}
+
+type RegisterAgentRequest struct {
+	DataCenterID string `json:"datacenter_id"`
+	State        State  `json:"state"`
+}
+
+type RegisterAgentResponse struct {
+	Data Agent `json:"data"`
+}
+
+type Agent struct {
+	ID string `json:"id"`
+}
+
+func (a *API) RegisterAgent(ctx context.Context, dataCenterID string) (*Agent, error) {
+	registerAgentRequest := RegisterAgentRequest{
+		DataCenterID: dataCenterID,
+		State:        State{Status: StatusInitializing},
 	}
 
-	return nil
+	var resp RegisterAgentResponse
+	err := a.do(ctx, request{
+		method:   http.MethodPost,
+		path:     "/api/v1/agents",
+		body:     registerAgentRequest,
+		expected: http.StatusCreated,
+	}, &resp)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+			return nil, fmt.Errorf("%w: %w", ErrDataCenterAlreadyRegistered, err)
+		}
+		return nil, err
+	}
+
+	return &resp.Data, nil
+}
+
+type HeartbeatRequest struct {
+	State State `json:"state"`
+}
+
+// Heartbeat reports the state of the agent.
+func (a *API) Heartbeat(ctx context.Context, agentID string, state State) error {
+	return a.do(ctx, request{
+		method:     http.MethodPatch,
+		path:       fmt.Sprintf("/api/v1/agents/%s/heartbeat", agentID),
+		body:       HeartbeatRequest{State: state},
+		expected:   http.StatusNoContent,
+		idempotent: true,
+	}, nil)
 }
 
 func (a *API) CleanDatacenter(ctx context.Context, agentID string) error {
-	cleanDatacenterEndpoint := fmt.Sprintf("%s/api/v1/agents/%s/clean-datacenter", a.baseURL, agentID)
-	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, cleanDatacenterEndpoint, nil)
+	return a.do(ctx, request{
+		method:     http.MethodDelete,
+		path:       fmt.Sprintf("/api/v1/agents/%s/clean-datacenter", agentID),
+		expected:   http.StatusNoContent,
+		idempotent: true,
+	}, nil)
+}
+
+type assetResponse struct {
+	Data Assets `json:"data"`
+}
+
+// ListAssets returns the assets of the data center of the agent.
+func (a *API) ListAssets(ctx context.Context, agentID string) ([]Assets, error) {
+	var resp APIResponse[Assets]
+	err := a.do(ctx, request{
+		method:     http.MethodGet,
+		path:       fmt.Sprintf("/api/v1/agents/%s/assets", agentID),
+		expected:   http.StatusOK,
+		idempotent: true,
+	}, &resp)
 	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+		return nil, err
 	}
 
-	req.Header.Add("Authorization", "Bearer "+a.token)
+	return resp.Data, nil
+}
 
-	res, err := a.client.Do(req)
+// GetAsset returns a single asset of the data center of the agent.
+func (a *API) GetAsset(ctx context.Context, agentID, assetID string) (*Assets, error) {
+	var resp assetResponse
+	err := a.do(ctx, request{
+		method:     http.MethodGet,
+		path:       fmt.Sprintf("/api/v1/agents/%s/assets/%s", agentID, assetID),
+		expected:   http.StatusOK,
+		idempotent: true,
+	}, &resp)
 	if err != nil {
-		return fmt.Errorf("error making request: %w", err)
+		return nil, err
 	}
 
-	defer res.Body.Close()
+	return &resp.Data, nil
+}
 
-	if res.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("unexpected status code: %d", res.StatusCode)
+// CreateAsset adds an asset to the data center of the agent.
+func (a *API) CreateAsset(ctx context.Context, agentID string, asset Assets) (*Assets, error) {
+	var resp assetResponse
+	err := a.do(ctx, request{
+		method:   http.MethodPost,
+		path:     fmt.Sprintf("/api/v1/agents/%s/assets", agentID),
+		body:     asset,
+		expected: http.StatusCreated,
+	}, &resp)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return &resp.Data, nil
+}
+
+// UpdateAsset replaces an asset of the data center of the agent.
+func (a *API) UpdateAsset(ctx context.Context, agentID string, asset Assets) error {
+	return a.do(ctx, request{
+		method:     http.MethodPut,
+		path:       fmt.Sprintf("/api/v1/agents/%s/assets/%s", agentID, asset.ID),
+		body:       asset,
+		expected:   http.StatusOK,
+		idempotent: true,
+	}, nil)
+}
+
+// DeleteAsset removes an asset from the data center of the agent.
+func (a *API) DeleteAsset(ctx context.Context, agentID, assetID string) error {
+	return a.do(ctx, request{
+		method:     http.MethodDelete,
+		path:       fmt.Sprintf("/api/v1/agents/%s/assets/%s", agentID, assetID),
+		expected:   http.StatusNoContent,
+		idempotent: true,
+	}, nil)
+}
+
+// Asset states reported by the agent.
+const (
+	AssetStateDeprovisioning = "deprovisioning"
+	AssetStateRemoved        = "removed"
+	AssetStateFailed         = "failed"
+)
+
+// AssetStateUpdate is a state transition of an asset, identified by its
+// MAC address.
+type AssetStateUpdate struct {
+	Name       string    `json:"name"`
+	MacAddress string    `json:"mac_address"`
+	State      string    `json:"state"`
+	Error      string    `json:"error,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// UpdateAssetState reports a state transition of an asset. Updates older
+// than the current state of the asset are ignored by the api, so they can
+// be replayed.
+func (a *API) UpdateAssetState(ctx context.Context, agentID string, update AssetStateUpdate) error {
+	return a.do(ctx, request{
+		method:     http.MethodPut,
+		path:       fmt.Sprintf("/api/v1/agents/%s/assets/state", agentID),
+		body:       update,
+		expected:   http.StatusNoContent,
+		idempotent: true,
+	}, nil)
 }
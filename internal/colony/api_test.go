@@ -0,0 +1,290 @@
+package colony
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestAPI(url string, opts ...Option) *API {
+	opts = append([]Option{WithBackoff(time.Millisecond, 4*time.Millisecond)}, opts...)
+	return New(url, "token", opts...)
+}
+
+func TestAPI_Heartbeat(t *testing.T) {
+	t.Run("retries 5xx responses", func(tt *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+
+			var req HeartbeatRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.State.Status != StatusHealthy {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		err := newTestAPI(server.URL).Heartbeat(context.Background(), "agent-1", State{Status: StatusHealthy})
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if calls.Load() != 3 {
+			tt.Fatalf("expected 3 calls, got %d", calls.Load())
+		}
+	})
+
+	t.Run("returns the api error once out of retries", func(tt *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls.Add(1)
+			w.Header().Set("X-Request-Id", "req-1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"error": "maintenance"}`))
+		}))
+		defer server.Close()
+
+		err := newTestAPI(server.URL, WithMaxRetries(2)).Heartbeat(context.Background(), "agent-1", State{})
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			tt.Fatalf("expected an api error but got: %v", err)
+		}
+
+		if apiErr.StatusCode != http.StatusServiceUnavailable || apiErr.RequestID != "req-1" || apiErr.Message != "maintenance" {
+			tt.Fatalf("unexpected api error: %+v", apiErr)
+		}
+
+		if calls.Load() != 3 {
+			tt.Fatalf("expected 3 calls, got %d", calls.Load())
+		}
+	})
+
+	t.Run("does not retry client errors", func(tt *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer server.Close()
+
+		if err := newTestAPI(server.URL).Heartbeat(context.Background(), "agent-1", State{}); err == nil {
+			tt.Fatalf("expecting an error but got nil")
+		}
+
+		if calls.Load() != 1 {
+			tt.Fatalf("expected 1 call, got %d", calls.Load())
+		}
+	})
+
+	t.Run("times out a slow attempt", func(tt *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if calls.Add(1) == 1 {
+				time.Sleep(200 * time.Millisecond)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		api := newTestAPI(server.URL, WithTimeout(50*time.Millisecond))
+		if err := api.Heartbeat(context.Background(), "agent-1", State{}); err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if calls.Load() != 2 {
+			tt.Fatalf("expected 2 calls, got %d", calls.Load())
+		}
+	})
+}
+
+func TestAPI_RegisterAgent(t *testing.T) {
+	t.Run("retries only when rate limited", func(tt *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			switch calls.Add(1) {
+			case 1:
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+			case 2:
+				w.WriteHeader(http.StatusBadGateway)
+			default:
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte(`{"data": {"id": "agent-1"}}`))
+			}
+		}))
+		defer server.Close()
+
+		_, err := newTestAPI(server.URL).RegisterAgent(context.Background(), "dc-1")
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+			tt.Fatalf("expected a bad gateway api error but got: %v", err)
+		}
+
+		if calls.Load() != 2 {
+			tt.Fatalf("expected 2 calls, got %d", calls.Load())
+		}
+	})
+
+	t.Run("does not wait for a large retry-after", func(tt *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		start := time.Now()
+		_, err := newTestAPI(server.URL).RegisterAgent(context.Background(), "dc-1")
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+			tt.Fatalf("expected a too many requests api error but got: %v", err)
+		}
+
+		if calls.Load() != 1 {
+			tt.Fatalf("expected 1 call, got %d", calls.Load())
+		}
+
+		if elapsed := time.Since(start); elapsed > time.Second {
+			tt.Fatalf("expected the error right away but waited %s", elapsed)
+		}
+	})
+
+	t.Run("does not wait past the context deadline", func(tt *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Retry-After", "5")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		_, err := newTestAPI(server.URL, WithBackoff(time.Millisecond, time.Minute)).RegisterAgent(ctx, "dc-1")
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+			tt.Fatalf("expected a too many requests api error but got: %v", err)
+		}
+
+		if ctx.Err() != nil {
+			tt.Fatalf("expected the error before the context deadline")
+		}
+
+		if calls.Load() != 1 {
+			tt.Fatalf("expected 1 call, got %d", calls.Load())
+		}
+	})
+
+	t.Run("already registered", func(tt *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusConflict)
+		}))
+		defer server.Close()
+
+		_, err := newTestAPI(server.URL).RegisterAgent(context.Background(), "dc-1")
+		if !errors.Is(err, ErrDataCenterAlreadyRegistered) {
+			tt.Fatalf("expected ErrDataCenterAlreadyRegistered but got: %v", err)
+		}
+	})
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{value: "", expected: 0},
+		{value: "3", expected: 3 * time.Second},
+		{value: "-1", expected: 0},
+		{value: now.Add(time.Minute).Format(http.TimeFormat), expected: time.Minute},
+		{value: now.Add(-time.Minute).Format(http.TimeFormat), expected: 0},
+		{value: "soon", expected: 0},
+	}
+
+	for _, tc := range tests {
+		if got := parseRetryAfter(tc.value, now); got != tc.expected {
+			t.Fatalf("expected %s for %q, got %s", tc.expected, tc.value, got)
+		}
+	}
+}
+
+func TestAPI_TLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	tests := []struct {
+		name    string
+		tls     TLS
+		wantErr bool
+	}{
+		{name: "verified by default", tls: TLS{}, wantErr: true},
+		{name: "custom ca", tls: TLS{CA: ca}},
+		{name: "insecure", tls: TLS{Insecure: true}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(tt *testing.T) {
+			tlsConfig, err := tc.tls.Config()
+			if err != nil {
+				tt.Fatalf("not expecting an error but got: %s", err)
+			}
+
+			api := newTestAPI(server.URL, WithTLSConfig(tlsConfig), WithMaxRetries(0))
+			err = api.Heartbeat(context.Background(), "agent-1", State{})
+			if tc.wantErr && err == nil {
+				tt.Fatalf("expecting an error but got nil")
+			}
+			if !tc.wantErr && err != nil {
+				tt.Fatalf("not expecting an error but got: %s", err)
+			}
+		})
+	}
+}
+
+func TestAPI_ListAssets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/api/v1/agents/agent-1/assets" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": [{"id": "1", "name": "hw-1", "networks": [{"mac_address": "aa:bb:cc:dd:ee:01"}]}]}`))
+	}))
+	defer server.Close()
+
+	assets, err := newTestAPI(server.URL).ListAssets(context.Background(), "agent-1")
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	if len(assets) != 1 || assets[0].Name != "hw-1" || assets[0].Networks[0].MacAddress != "aa:bb:cc:dd:ee:01" {
+		t.Fatalf("unexpected assets: %+v", assets)
+	}
+}
@@ -0,0 +1,161 @@
+package colony
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// Queue stores the asset state updates that could not be sent while the
+// colony api was unreachable, so they can be replayed later.
+type Queue struct {
+	path string
+}
+
+// QueuedUpdate is an asset state update waiting to be sent.
+type QueuedUpdate struct {
+	AgentID string           `json:"agentID"`
+	Update  AssetStateUpdate `json:"update"`
+}
+
+// NewQueue returns the queue stored in the file at path.
+func NewQueue(path string) *Queue {
+	return &Queue{path: path}
+}
+
+// Add appends an update to the queue.
+func (q *Queue) Add(agentID string, update AssetStateUpdate) error {
+	updates, err := q.List()
+	if err != nil {
+		return err
+	}
+
+	return q.save(append(updates, QueuedUpdate{AgentID: agentID, Update: update}))
+}
+
+// List returns the queued updates, oldest first.
+func (q *Queue) List() ([]QueuedUpdate, error) {
+	content, err := os.ReadFile(q.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading queue %q: %w", q.path, err)
+	}
+
+	var updates []QueuedUpdate
+	if err := json.Unmarshal(content, &updates); err != nil {
+		return nil, fmt.Errorf("error parsing queue %q: %w", q.path, err)
+	}
+
+	return updates, nil
+}
+
+// Replay sends the queued updates in order. It stops at the first update
+// the api cannot take yet and keeps it queued with the ones after it.
+// Updates the api rejects are dropped. It returns the number of updates
+// sent.
+func (q *Queue) Replay(ctx context.Context, api *API) (int, error) {
+	updates, err := q.List()
+	if err != nil || len(updates) == 0 {
+		return 0, err
+	}
+
+	var sent int
+	var errs []error
+	for _, u := range updates {
+		err := api.UpdateAssetState(ctx, u.AgentID, u.Update)
+		if err != nil && IsTemporary(err) {
+			errs = append(errs, err)
+			break
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("dropping %s update of %s: %w", u.Update.State, u.Update.Name, err))
+		} else {
+			sent++
+		}
+		updates = updates[1:]
+	}
+
+	if err := q.save(updates); err != nil {
+		errs = append(errs, err)
+	}
+
+	return sent, errors.Join(errs...)
+}
+
+func (q *Queue) save(updates []QueuedUpdate) error {
+	if len(updates) == 0 {
+		if err := os.Remove(q.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error removing queue %q: %w", q.path, err)
+		}
+		return nil
+	}
+
+	content, err := json.MarshalIndent(updates, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling queue: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(q.path), 0o700); err != nil {
+		return fmt.Errorf("unable to create directory for %q: %w", q.path, err)
+	}
+
+	// write then rename so a crash never leaves a truncated queue
+	tmp := q.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		return fmt.Errorf("error writing queue %q: %w", q.path, err)
+	}
+
+	if err := os.Rename(tmp, q.path); err != nil {
+		return fmt.Errorf("error writing queue %q: %w", q.path, err)
+	}
+
+	return nil
+}
+
+// IsTemporary returns true if a request failed because the colony api
+// could not be reached or could not handle it for now.
+func IsTemporary(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+
+	// a url.Error is a net.Error, certificate and tls failures are
+	// configuration errors that retrying will not fix
+	if isTLSError(err) {
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func isTLSError(err error) bool {
+	var (
+		unknownAuthority   x509.UnknownAuthorityError
+		hostname           x509.HostnameError
+		certificateInvalid x509.CertificateInvalidError
+		systemRoots        x509.SystemRootsError
+		insecureAlgorithm  x509.InsecureAlgorithmError
+		recordHeader       tls.RecordHeaderError
+		alert              tls.AlertError
+		verification       *tls.CertificateVerificationError
+	)
+
+	return errors.As(err, &unknownAuthority) ||
+		errors.As(err, &hostname) ||
+		errors.As(err, &certificateInvalid) ||
+		errors.As(err, &systemRoots) ||
+		errors.As(err, &insecureAlgorithm) ||
+		errors.As(err, &recordHeader) ||
+		errors.As(err, &alert) ||
+		errors.As(err, &verification)
+}
@@ -0,0 +1,105 @@
+package colony
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestQueue_Replay(t *testing.T) {
+	var status atomic.Int32
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var update AssetStateUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if update.Name == "rejected" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(int(status.Load()))
+		if status.Load() == http.StatusNoContent {
+			received = append(received, update.Name+"/"+update.State)
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "pending-updates.json")
+	queue := NewQueue(path)
+	api := newTestAPI(server.URL, WithMaxRetries(0))
+
+	for _, update := range []AssetStateUpdate{
+		{Name: "hw-1", State: AssetStateDeprovisioning},
+		{Name: "rejected", State: AssetStateFailed},
+		{Name: "hw-1", State: AssetStateRemoved},
+	} {
+		if err := queue.Add("agent-1", update); err != nil {
+			t.Fatalf("not expecting an error but got: %s", err)
+		}
+	}
+
+	t.Run("keeps the updates while the api is down", func(tt *testing.T) {
+		status.Store(http.StatusBadGateway)
+
+		sent, err := queue.Replay(context.Background(), api)
+		if err == nil || !IsTemporary(err) || sent != 0 {
+			tt.Fatalf("expected a temporary error and nothing sent, got %d sent and %v", sent, err)
+		}
+
+		updates, err := queue.List()
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+		if len(updates) != 3 {
+			tt.Fatalf("expected 3 queued updates but got %d", len(updates))
+		}
+	})
+
+	t.Run("sends the updates in order and drops rejected ones", func(tt *testing.T) {
+		status.Store(http.StatusNoContent)
+
+		sent, err := queue.Replay(context.Background(), api)
+		if err == nil {
+			tt.Fatalf("expected an error for the rejected update but got nil")
+		}
+
+		if sent != 2 || len(received) != 2 || received[0] != "hw-1/deprovisioning" || received[1] != "hw-1/removed" {
+			tt.Fatalf("expected the hw-1 updates in order but got %d sent: %v", sent, received)
+		}
+
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			tt.Fatalf("expected the queue file to be removed but got: %v", err)
+		}
+	})
+}
+
+func TestIsTemporary(t *testing.T) {
+	t.Run("unreachable api", func(tt *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		_, err := newTestAPI(server.URL, WithMaxRetries(0)).ListAssets(context.Background(), "agent-1")
+		if err == nil || !IsTemporary(err) {
+			tt.Fatalf("expected a temporary error but got %v", err)
+		}
+	})
+
+	t.Run("untrusted certificate", func(tt *testing.T) {
+		server := httptest.NewTLSServer(http.NotFoundHandler())
+		defer server.Close()
+
+		_, err := newTestAPI(server.URL, WithMaxRetries(0)).ListAssets(context.Background(), "agent-1")
+		if err == nil || IsTemporary(err) {
+			tt.Fatalf("expected a permanent error but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package colony
+
+// Agent statuses reported with the state.
+const (
+	StatusInitializing = "initializing"
+	StatusHealthy      = "healthy"
+	StatusDegraded     = "degraded"
+)
+
+// State is the state document an agent reports when registering and
+// with every heartbeat.
+type State struct {
+	Status           string             `json:"status"`
+	ControlPlane     *ControlPlaneState `json:"control_plane,omitempty"`
+	Deployments      []DeploymentState  `json:"deployments,omitempty"`
+	Hardware         map[string]int     `json:"hardware,omitempty"`
+	RunningJobs      []string           `json:"running_jobs,omitempty"`
+	RunningWorkflows []string           `json:"running_workflows,omitempty"`
+	Errors           []string           `json:"errors,omitempty"`
+}
+
+// ControlPlaneState is the health of the Kubernetes API colony runs on.
+type ControlPlaneState struct {
+	Healthy bool   `json:"healthy"`
+	Version string `json:"version,omitempty"`
+}
+
+// DeploymentState is the readiness of a colony deployment.
+type DeploymentState struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Ready     int32  `json:"ready"`
+	Desired   int32  `json:"desired"`
+}
@@ -0,0 +1,77 @@
+package colony
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLS holds the settings used to reach the colony API over https. The
+// certificates are PEM encoded.
+type TLS struct {
+	CA         []byte
+	ClientCert []byte
+	ClientKey  []byte
+	Insecure   bool
+}
+
+// LoadTLS reads the CA and client certificate files. Empty paths are
+// skipped.
+func LoadTLS(caFile, certFile, keyFile string, insecure bool) (TLS, error) {
+	t := TLS{Insecure: insecure}
+
+	if (certFile == "") != (keyFile == "") {
+		return TLS{}, errors.New("a client certificate and key must be set together")
+	}
+
+	for _, f := range []struct {
+		path  string
+		value *[]byte
+		name  string
+	}{
+		{caFile, &t.CA, "ca"},
+		{certFile, &t.ClientCert, "client certificate"},
+		{keyFile, &t.ClientKey, "client key"},
+	} {
+		if f.path == "" {
+			continue
+		}
+
+		content, err := os.ReadFile(f.path)
+		if err != nil {
+			return TLS{}, fmt.Errorf("error reading %s file %q: %w", f.name, f.path, err)
+		}
+		*f.value = content
+	}
+
+	return t, nil
+}
+
+// Config returns the TLS client config. Without a CA the system roots are
+// trusted.
+func (t TLS) Config() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: t.Insecure, //nolint:gosec // explicit opt-in with --api-insecure
+	}
+
+	if len(t.CA) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(t.CA) {
+			return nil, errors.New("no valid certificate found in the ca")
+		}
+		cfg.RootCAs = pool
+	}
+
+	if len(t.ClientCert) > 0 || len(t.ClientKey) > 0 {
+		cert, err := tls.X509KeyPair(t.ClientCert, t.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
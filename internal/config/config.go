@@ -0,0 +1,251 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// APIVersion is the current version of the colony config file schema.
+	APIVersion = "colony.konstruct.io/v1alpha1"
+
+	// Kind is the kind of the colony init config file.
+	Kind = "InitConfig"
+
+	// DefaultAPIURL is the colony cloud API used when none is configured.
+	DefaultAPIURL = "https://colony-api.konstruct.io"
+
+	// DefaultCSEInstallerImage is the cse-installer image used when none is configured.
+	DefaultCSEInstallerImage = "ghcr.io/konstructio/cse-installer:v0.0.10"
+
+	// RuntimeDocker runs colony in a k3s container managed by docker.
+	RuntimeDocker = "docker"
+
+	// RuntimePodman runs colony in a k3s container managed by podman.
+	RuntimePodman = "podman"
+
+	// RuntimeExternal installs colony into an existing Kubernetes cluster.
+	RuntimeExternal = "external"
+)
+
+// Config is the declarative configuration for `colony init`.
+type Config struct {
+	APIVersion        string       `json:"apiVersion"`
+	Kind              string       `json:"kind"`
+	APIKey            string       `json:"apiKey,omitempty"`
+	APIURL            string       `json:"apiURL,omitempty"`
+	APITLS            APITLS       `json:"apiTLS"`
+	DataCenterID      string       `json:"dataCenterID,omitempty"`
+	AgentID           string       `json:"agentID,omitempty"`
+	LoadBalancer      LoadBalancer `json:"loadBalancer"`
+	Tokens            Tokens       `json:"tokens"`
+	CSEInstallerImage string       `json:"cseInstallerImage,omitempty"`
+	Runtime           string       `json:"runtime,omitempty"`
+	Kubeconfig        string       `json:"kubeconfig,omitempty"`
+}
+
+// LoadBalancer holds the local network settings colony uses to serve
+// the provisioning stack.
+type LoadBalancer struct {
+	IP        string `json:"ip,omitempty"`
+	Interface string `json:"interface,omitempty"`
+}
+
+// APITLS holds the TLS settings used to reach the colony cloud API.
+type APITLS struct {
+	CAFile     string `json:"caFile,omitempty"`
+	ClientCert string `json:"clientCert,omitempty"`
+	ClientKey  string `json:"clientKey,omitempty"`
+	// Insecure skips the verification of the API certificate.
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// Tokens holds the cloud tokens handed to the colony agent.
+type Tokens struct {
+	API    string `json:"api,omitempty"`
+	Gitlab string `json:"gitlab,omitempty"`
+	Docker string `json:"docker,omitempty"`
+}
+
+// field maps a config value to the flag and environment variable
+// that can override it.
+type field struct {
+	path     string
+	flag     string
+	env      string
+	required bool
+	value    func(*Config) *string
+}
+
+var fields = []field{
+	{"apiKey", "api-key", "COLONY_API_KEY", true, func(c *Config) *string { return &c.APIKey }},
+	{"apiURL", "api-url", "COLONY_API_URL", true, func(c *Config) *string { return &c.APIURL }},
+	{"apiTLS.caFile", "api-ca-file", "COLONY_API_CA_FILE", false, func(c *Config) *string { return &c.APITLS.CAFile }},
+	{"apiTLS.clientCert", "api-client-cert", "COLONY_API_CLIENT_CERT", false, func(c *Config) *string { return &c.APITLS.ClientCert }},
+	{"apiTLS.clientKey", "api-client-key", "COLONY_API_CLIENT_KEY", false, func(c *Config) *string { return &c.APITLS.ClientKey }},
+	{"dataCenterID", "data-center-id", "COLONY_DATA_CENTER_ID", true, func(c *Config) *string { return &c.DataCenterID }},
+	{"agentID", "agent-id", "COLONY_AGENT_ID", false, func(c *Config) *string { return &c.AgentID }},
+	{"loadBalancer.ip", "load-balancer-ip", "COLONY_LOAD_BALANCER_IP", true, func(c *Config) *string { return &c.LoadBalancer.IP }},
+	{"loadBalancer.interface", "load-balancer-interface", "COLONY_LOAD_BALANCER_INTERFACE", true, func(c *Config) *string { return &c.LoadBalancer.Interface }},
+	{"tokens.api", "api-token", "COLONY_API_TOKEN", true, func(c *Config) *string { return &c.Tokens.API }},
+	{"tokens.gitlab", "gitlab-token", "COLONY_GITLAB_TOKEN", true, func(c *Config) *string { return &c.Tokens.Gitlab }},
+	{"tokens.docker", "docker-token", "COLONY_DOCKER_TOKEN", true, func(c *Config) *string { return &c.Tokens.Docker }},
+	{"cseInstallerImage", "cse-installer-image", "COLONY_CSE_INSTALLER_IMAGE", true, func(c *Config) *string { return &c.CSEInstallerImage }},
+	{"runtime", "runtime", "COLONY_RUNTIME", false, func(c *Config) *string { return &c.Runtime }},
+	{"kubeconfig", "kubeconfig", "COLONY_KUBECONFIG", false, func(c *Config) *string { return &c.Kubeconfig }},
+}
+
+// FieldError is a validation error for a single config field.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// Default returns a config with every optional value set to its default.
+func Default() *Config {
+	return &Config{
+		APIVersion:        APIVersion,
+		Kind:              Kind,
+		APIURL:            DefaultAPIURL,
+		CSEInstallerImage: DefaultCSEInstallerImage,
+	}
+}
+
+// Load reads the config file at path on top of the defaults.
+// Unknown fields and unsupported schema versions are rejected.
+func Load(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file %q: %w", path, err)
+	}
+
+	cfg := Default()
+	if err := yaml.UnmarshalStrict(content, cfg); err != nil {
+		return nil, fmt.Errorf("error parsing config file %q: %w", path, err)
+	}
+
+	if cfg.APIVersion != APIVersion {
+		return nil, fmt.Errorf("config file %q: %w", path, &FieldError{Field: "apiVersion", Message: fmt.Sprintf("unsupported version %q, expected %q", cfg.APIVersion, APIVersion)})
+	}
+
+	if cfg.Kind != Kind {
+		return nil, fmt.Errorf("config file %q: %w", path, &FieldError{Field: "kind", Message: fmt.Sprintf("unsupported kind %q, expected %q", cfg.Kind, Kind)})
+	}
+
+	return cfg, nil
+}
+
+// Save writes the config to path. The file holds credentials so it is
+// only readable by the current user.
+func (c *Config) Save(path string) error {
+	content, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("error marshalling config: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("unable to create directory for %q: %w", path, err)
+	}
+
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		return fmt.Errorf("error writing config file %q: %w", path, err)
+	}
+
+	return nil
+}
+
+// insecureFlag and insecureEnv override apiTLS.insecure, the only boolean
+// setting.
+const (
+	insecureFlag = "api-insecure"
+	insecureEnv  = "COLONY_API_INSECURE"
+)
+
+// ApplyEnv overrides config values with the matching COLONY_* environment
+// variables, when set.
+func (c *Config) ApplyEnv() {
+	for _, f := range fields {
+		if value, ok := os.LookupEnv(f.env); ok && value != "" {
+			*f.value(c) = value
+		}
+	}
+
+	if value, ok := os.LookupEnv(insecureEnv); ok && value != "" {
+		c.Set(insecureFlag, value)
+	}
+}
+
+// Set overrides the config value bound to the given flag name. It returns
+// false if the flag is not backed by a config value.
+func (c *Config) Set(flag, value string) bool {
+	if flag == insecureFlag {
+		c.APITLS.Insecure, _ = strconv.ParseBool(value)
+		return true
+	}
+
+	for _, f := range fields {
+		if f.flag == flag {
+			*f.value(c) = value
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that every required value is set and well formed.
+// All problems are reported at once, each pointing to its field.
+func (c *Config) Validate() error {
+	var errs []error
+
+	for _, f := range fields {
+		if f.required && *f.value(c) == "" {
+			errs = append(errs, &FieldError{Field: f.path, Message: fmt.Sprintf("required value is missing (set it in the config file, with --%s or with %s)", f.flag, f.env)})
+		}
+	}
+
+	if c.LoadBalancer.IP != "" && net.ParseIP(c.LoadBalancer.IP) == nil {
+		errs = append(errs, &FieldError{Field: "loadBalancer.ip", Message: fmt.Sprintf("%q is not a valid IP address", c.LoadBalancer.IP)})
+	}
+
+	if u, err := url.Parse(c.APIURL); c.APIURL != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+		errs = append(errs, &FieldError{Field: "apiURL", Message: fmt.Sprintf("%q is not a valid http(s) URL", c.APIURL)})
+	}
+
+	if (c.APITLS.ClientCert == "") != (c.APITLS.ClientKey == "") {
+		errs = append(errs, &FieldError{Field: "apiTLS", Message: "clientCert and clientKey must be set together"})
+	}
+
+	switch c.Runtime {
+	case "", RuntimeExternal:
+	case RuntimeDocker, RuntimePodman:
+		if c.Kubeconfig != "" {
+			errs = append(errs, &FieldError{Field: "kubeconfig", Message: fmt.Sprintf("a kubeconfig can only be used with the %q runtime", RuntimeExternal)})
+		}
+	default:
+		errs = append(errs, &FieldError{Field: "runtime", Message: fmt.Sprintf("unsupported runtime %q, must be one of: %s", c.Runtime, strings.Join(Runtimes, ", "))})
+	}
+
+	return errors.Join(errs...)
+}
+
+// Runtimes are the supported values of the runtime setting.
+var Runtimes = []string{RuntimeDocker, RuntimePodman, RuntimeExternal}
+
+// External returns true if colony is installed into an existing
+// Kubernetes cluster instead of a k3s container. Setting a kubeconfig
+// implies the external runtime.
+func (c *Config) External() bool {
+	return c.Runtime == RuntimeExternal || (c.Runtime == "" && c.Kubeconfig != "")
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const validConfig = `apiVersion: colony.konstruct.io/v1alpha1
+kind: InitConfig
+apiKey: file-api-key
+dataCenterID: dc-1
+loadBalancer:
+  ip: 10.0.0.10
+  interface: eth0
+tokens:
+  api: api-token
+  gitlab: gitlab-token
+  docker: docker-token
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "colony-config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("error writing config file: %s", err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("valid file with defaults", func(tt *testing.T) {
+		cfg, err := Load(writeConfig(tt, validConfig))
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if err := cfg.Validate(); err != nil {
+			tt.Fatalf("not expecting a validation error but got: %s", err)
+		}
+
+		if cfg.APIURL != DefaultAPIURL {
+			tt.Fatalf("expected default api url %q but got %q", DefaultAPIURL, cfg.APIURL)
+		}
+	})
+
+	t.Run("unsupported version", func(tt *testing.T) {
+		_, err := Load(writeConfig(tt, "apiVersion: colony.konstruct.io/v9\nkind: InitConfig\n"))
+
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) || fieldErr.Field != "apiVersion" {
+			tt.Fatalf("expected an apiVersion field error but got: %v", err)
+		}
+	})
+
+	t.Run("unknown field", func(tt *testing.T) {
+		_, err := Load(writeConfig(tt, validConfig+"loadBalancerIP: 10.0.0.10\n"))
+		if err == nil {
+			tt.Fatalf("expecting an error but got nil")
+		}
+	})
+}
+
+func TestPrecedence(t *testing.T) {
+	cfg, err := Load(writeConfig(t, validConfig))
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	t.Setenv("COLONY_API_KEY", "env-api-key")
+	t.Setenv("COLONY_DATA_CENTER_ID", "env-dc")
+	cfg.ApplyEnv()
+
+	if !cfg.Set("data-center-id", "flag-dc") {
+		t.Fatalf("expected data-center-id to be a config flag")
+	}
+
+	if cfg.Set("file", "other.yaml") {
+		t.Fatalf("expected file not to be a config flag")
+	}
+
+	if cfg.APIKey != "env-api-key" {
+		t.Fatalf("expected environment to override file, got %q", cfg.APIKey)
+	}
+
+	if cfg.DataCenterID != "flag-dc" {
+		t.Fatalf("expected flag to override environment, got %q", cfg.DataCenterID)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.LoadBalancer.IP = "not-an-ip"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatalf("expecting an error but got nil")
+	}
+
+	for _, name := range []string{"apiKey", "dataCenterID", "loadBalancer.ip", "loadBalancer.interface", "tokens.gitlab"} {
+		if !strings.Contains(err.Error(), name+": ") {
+			t.Fatalf("expected a validation error for %q, got: %s", name, err)
+		}
+	}
+}
+
+func TestExternal(t *testing.T) {
+	tests := []struct {
+		name       string
+		runtime    string
+		kubeconfig string
+		want       bool
+	}{
+		{name: "default", want: false},
+		{name: "docker", runtime: RuntimeDocker, want: false},
+		{name: "external", runtime: RuntimeExternal, want: true},
+		{name: "kubeconfig implies external", kubeconfig: "/root/.kube/config", want: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(tt *testing.T) {
+			cfg := Default()
+			cfg.Runtime = tc.runtime
+			cfg.Kubeconfig = tc.kubeconfig
+
+			if got := cfg.External(); got != tc.want {
+				tt.Fatalf("expected External() to be %t but got %t", tc.want, got)
+			}
+		})
+	}
+}
@@ -1,12 +1,17 @@
 package constants
 
 const (
-	ColonyK3sContainerName = "colony-k3s"
-	DefaultDockerIDLength  = 12
-	KubeconfigHostPath     = "kubeconfig"
-	KubeconfigDockerPath   = "/output/kubeconfig"
-	ColonyYamlPath         = "colony.yaml"
-	ColonyDir              = ".colony"
-	ColonyNamespace        = "tink-system"
-	ColonyAPISecretName    = "colony-api"
+	ColonyK3sContainerName   = "colony-k3s"
+	DefaultDockerIDLength    = 12
+	KubeconfigHostPath       = "kubeconfig"
+	KubeconfigDockerPath     = "/output/kubeconfig"
+	ColonyYamlPath           = "colony.yaml"
+	ColonyDir                = ".colony"
+	ColonyNamespace          = "tink-system"
+	ColonyAPISecretName      = "colony-api"
+	ColonyTokensSecretName   = "colony-tokens"
+	ColonyConfigPath         = "config.yaml"
+	ColonyInitStatePath      = "init-state.json"
+	ColonyVersionsPath       = "versions.yaml"
+	ColonyPendingUpdatesPath = "pending-updates.json"
 )
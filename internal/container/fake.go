@@ -0,0 +1,156 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Fake is an in-memory Runtime for tests.
+type Fake struct {
+	mu sync.Mutex
+
+	// Containers are the containers by ID.
+	Containers map[string]*Container
+	// RemovedVolumes are the volumes removed along with a container.
+	RemovedVolumes []string
+	// Daemon is returned by Info.
+	Daemon DaemonInfo
+	// LogOutput is written by Logs.
+	LogOutput string
+	// OnStart is called when a container starts, e.g. to write the
+	// files the real container would.
+	OnStart func(c *Container)
+
+	nextID int
+}
+
+var _ Runtime = (*Fake)(nil)
+
+// NewFake returns an empty fake runtime for a linux daemon.
+func NewFake() *Fake {
+	return &Fake{
+		Containers: make(map[string]*Container),
+		Daemon:     DaemonInfo{ServerVersion: "fake", OSType: "linux", CgroupVersion: "2"},
+	}
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) Info(_ context.Context) (*DaemonInfo, error) {
+	info := f.Daemon
+	return &info, nil
+}
+
+func (f *Fake) Get(_ context.Context, name string) (*Container, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, c := range f.Containers {
+		if c.Name == name {
+			found := *c
+			return &found, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (f *Fake) Create(_ context.Context, spec Spec) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, c := range f.Containers {
+		if c.Name == spec.Name {
+			return "", fmt.Errorf("container name %q is already in use", spec.Name)
+		}
+	}
+
+	f.nextID++
+	id := fmt.Sprintf("fake%020d", f.nextID)
+
+	f.Containers[id] = &Container{
+		ID:     id,
+		Name:   spec.Name,
+		State:  "created",
+		Mounts: append([]Mount(nil), spec.Mounts...),
+	}
+
+	return id, nil
+}
+
+func (f *Fake) Start(_ context.Context, id string) error {
+	f.mu.Lock()
+	c, ok := f.Containers[id]
+	if ok {
+		c.State = "running"
+	}
+	f.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	if f.OnStart != nil {
+		f.OnStart(c)
+	}
+
+	return nil
+}
+
+func (f *Fake) Stop(_ context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.Containers[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	c.State = "exited"
+	return nil
+}
+
+func (f *Fake) Remove(_ context.Context, id string, removeVolumes bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.Containers[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	if removeVolumes {
+		for _, m := range c.Mounts {
+			if m.Type == MountVolume {
+				f.RemovedVolumes = append(f.RemovedVolumes, m.Source)
+			}
+		}
+	}
+
+	delete(f.Containers, id)
+	return nil
+}
+
+func (f *Fake) Logs(_ context.Context, id string, w io.Writer, _ bool) error {
+	f.mu.Lock()
+	_, ok := f.Containers[id]
+	f.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	if _, err := io.WriteString(w, f.LogOutput); err != nil {
+		return fmt.Errorf("error writing logs: %w", err)
+	}
+
+	return nil
+}
+
+func (f *Fake) Close() error {
+	return nil
+}
@@ -0,0 +1,227 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/konstructio/colony/internal/constants"
+	"github.com/konstructio/colony/internal/logger"
+)
+
+// K3s manages the colony k3s container on a container runtime.
+type K3s struct {
+	runtime Runtime
+	log     *logger.Logger
+
+	// kubeconfig polling, shortened in tests
+	waitInterval time.Duration
+	waitTimeout  time.Duration
+}
+
+// NewK3s returns a manager for the colony k3s container on runtime.
+func NewK3s(log *logger.Logger, runtime Runtime) *K3s {
+	return &K3s{
+		runtime:      runtime,
+		log:          log,
+		waitInterval: 2 * time.Second,
+		waitTimeout:  15 * time.Second,
+	}
+}
+
+// Runtime returns the container runtime the container runs on.
+func (k *K3s) Runtime() Runtime {
+	return k.runtime
+}
+
+// Exists returns true if the colony k3s container exists.
+func (k *K3s) Exists(ctx context.Context) (bool, error) {
+	if _, err := k.runtime.Get(ctx, constants.ColonyK3sContainerName); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error getting %q container: %w", constants.ColonyK3sContainerName, err)
+	}
+	return true, nil
+}
+
+// State returns the state of the colony k3s container, e.g. "running"
+// or "exited".
+func (k *K3s) State(ctx context.Context) (string, error) {
+	k3scontainer, err := k.runtime.Get(ctx, constants.ColonyK3sContainerName)
+	if err != nil {
+		return "", fmt.Errorf("error getting %q container: %w", constants.ColonyK3sContainerName, err)
+	}
+	return k3scontainer.State, nil
+}
+
+// Logs writes the logs of the colony k3s container to w.
+func (k *K3s) Logs(ctx context.Context, w io.Writer, follow bool) error {
+	k3scontainer, err := k.runtime.Get(ctx, constants.ColonyK3sContainerName)
+	if err != nil {
+		return fmt.Errorf("error getting %q container: %w", constants.ColonyK3sContainerName, err)
+	}
+
+	if err := k.runtime.Logs(ctx, k3scontainer.ID, w, follow); err != nil {
+		return fmt.Errorf("error reading %q container logs: %w", constants.ColonyK3sContainerName, err)
+	}
+
+	return nil
+}
+
+// Remove stops and removes the colony k3s container and its volumes.
+func (k *K3s) Remove(ctx context.Context) error {
+	k3scontainer, err := k.runtime.Get(ctx, constants.ColonyK3sContainerName)
+	if err != nil {
+		return fmt.Errorf("error getting %q container: %w", constants.ColonyK3sContainerName, err)
+	}
+
+	if len(k3scontainer.ID) > constants.DefaultDockerIDLength {
+		k.log.Infof("found container name %q with ID %q", k3scontainer.Name, k3scontainer.ID[:constants.DefaultDockerIDLength])
+	} else {
+		k.log.Infof("found container name %q with ID %q", k3scontainer.Name, k3scontainer.ID)
+	}
+
+	if err := k.runtime.Stop(ctx, k3scontainer.ID); err != nil {
+		return fmt.Errorf("error stopping container: %w", err)
+	}
+
+	if err := k.runtime.Remove(ctx, k3scontainer.ID, true); err != nil {
+		return fmt.Errorf("error removing container: %w", err)
+	}
+
+	return nil
+}
+
+// Create creates and starts the colony k3s container running imageName,
+// and waits for k3s to write its kubeconfig. colonyDir is mounted at the
+// same path so k3s can write the kubeconfig into it.
+func (k *K3s) Create(ctx context.Context, imageName, colonyK3sBootstrapPath, colonyKubeconfigPath, colonyDir string) error {
+	exists, err := k.Exists(ctx)
+	if err != nil {
+		return fmt.Errorf("%s error: %w", k.runtime.Name(), err)
+	}
+
+	if exists {
+		return fmt.Errorf("%q container already exists. please remove before continuing or run `colony destroy`", constants.ColonyK3sContainerName)
+	}
+
+	return k.create(ctx, imageName, colonyK3sBootstrapPath, colonyKubeconfigPath, colonyDir, nil)
+}
+
+// Recreate replaces the colony k3s container with one running imageName.
+// The volumes holding the cluster data are kept and mounted into the new
+// container.
+func (k *K3s) Recreate(ctx context.Context, imageName, colonyK3sBootstrapPath, colonyKubeconfigPath, colonyDir string) error {
+	k3scontainer, err := k.runtime.Get(ctx, constants.ColonyK3sContainerName)
+	if err != nil {
+		return fmt.Errorf("error getting %q container: %w", constants.ColonyK3sContainerName, err)
+	}
+
+	var volumes []Mount
+	for _, m := range k3scontainer.Mounts {
+		if m.Type == MountVolume {
+			volumes = append(volumes, m)
+		}
+	}
+
+	k.log.Infof("replacing container %q, keeping %d volumes", constants.ColonyK3sContainerName, len(volumes))
+
+	if err := k.runtime.Stop(ctx, k3scontainer.ID); err != nil {
+		return fmt.Errorf("error stopping container: %w", err)
+	}
+
+	if err := k.runtime.Remove(ctx, k3scontainer.ID, false); err != nil {
+		return fmt.Errorf("error removing container: %w", err)
+	}
+
+	return k.create(ctx, imageName, colonyK3sBootstrapPath, colonyKubeconfigPath, colonyDir, volumes)
+}
+
+func (k *K3s) create(ctx context.Context, imageName, colonyK3sBootstrapPath, colonyKubeconfigPath, colonyDir string, extraMounts []Mount) error {
+	mounts := []Mount{
+		{
+			Type:   MountBind,
+			Source: colonyDir,
+			Target: colonyDir,
+		},
+		{
+			Type:   MountBind,
+			Source: colonyK3sBootstrapPath,
+			Target: fmt.Sprintf("/var/lib/rancher/k3s/server/manifests/%s", constants.ColonyYamlPath),
+		},
+		{
+			Type:   MountTmpfs,
+			Target: "/run",
+		},
+		{
+			Type:   MountTmpfs,
+			Target: "/var/run",
+		},
+	}
+	mounts = append(mounts, extraMounts...)
+
+	id, err := k.runtime.Create(ctx, Spec{
+		Name:  constants.ColonyK3sContainerName,
+		Image: imageName,
+		Env: []string{
+			fmt.Sprintf("K3S_KUBECONFIG_OUTPUT=%s", colonyKubeconfigPath),
+			"K3S_KUBECONFIG_MODE=666",
+		},
+		Cmd: []string{
+			"server",
+			"--disable=traefik,servicelb",
+			"--tls-san=colony",
+			"--node-label=colony.konstruct.io/node-type=colony",
+		},
+		Mounts: mounts,
+	})
+	if err != nil {
+		return fmt.Errorf("error creating container: %w", err)
+	}
+
+	k.log.Infof("created container with ID %q", id)
+
+	if err := k.runtime.Start(ctx, id); err != nil {
+		return fmt.Errorf("error starting container: %w", err)
+	}
+
+	k.log.Infof("Checking for file %s every %.0f seconds...", colonyKubeconfigPath, k.waitInterval.Seconds())
+
+	err = waitUntilFileExists(k.log, colonyKubeconfigPath, k.waitInterval, k.waitTimeout)
+	if err != nil {
+		return fmt.Errorf("error waiting for kubeconfig file: %w", err)
+	}
+
+	return nil
+}
+
+func waitUntilFileExists(log *logger.Logger, filename string, interval, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timeout reached while waiting for file %s", filename)
+		case <-ticker.C:
+			if _, err := os.Stat(filename); err != nil {
+				if os.IsNotExist(err) {
+					log.Infof("waiting for file %q...", filename)
+					continue
+				}
+
+				return fmt.Errorf("error checking file: %w", err)
+			}
+
+			log.Infof("found and stat'd file %q", filename)
+			return nil
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/konstructio/colony/internal/constants"
+	"github.com/konstructio/colony/internal/logger"
+)
+
+func requireNoError(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("error = %v", err)
+	}
+}
+
+func Test_waitUntilFileExists(t *testing.T) {
+	// Create a temporary directory
+	dir, err := os.MkdirTemp("", "test")
+	requireNoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Define the filename
+	filename := fmt.Sprintf("%s/testfile", dir)
+
+	// Set up a goroutine to create the file after 250 ms
+	go func() {
+		time.Sleep(250 * time.Millisecond)
+		_, err := os.Create(filename)
+		requireNoError(t, err)
+	}()
+
+	// Create a logger
+	log := logger.New(logger.Debug)
+
+	// Call waitForFile2 with an interval of 50 ms and a timeout of 1 second
+	err = waitUntilFileExists(log, filename, 50*time.Millisecond, 1*time.Second)
+	if err != nil {
+		t.Fatalf("waitUntilFileExists() error = %v", err)
+	}
+}
+
+func newTestK3s(t *testing.T) (*K3s, *Fake, string) {
+	t.Helper()
+
+	colonyDir := t.TempDir()
+	kubeconfigPath := filepath.Join(colonyDir, constants.KubeconfigHostPath)
+
+	runtime := NewFake()
+	runtime.OnStart = func(*Container) {
+		requireNoError(t, os.MkdirAll(filepath.Dir(kubeconfigPath), 0o700))
+		requireNoError(t, os.WriteFile(kubeconfigPath, []byte("kubeconfig"), 0o600))
+	}
+
+	k3s := NewK3s(logger.NOOPLogger, runtime)
+	k3s.waitInterval = 10 * time.Millisecond
+	k3s.waitTimeout = time.Second
+
+	return k3s, runtime, colonyDir
+}
+
+func TestK3s_Create(t *testing.T) {
+	ctx := context.Background()
+	k3s, runtime, colonyDir := newTestK3s(t)
+	kubeconfigPath := filepath.Join(colonyDir, constants.KubeconfigHostPath)
+
+	err := k3s.Create(ctx, "rancher/k3s:v1", filepath.Join(colonyDir, "colony.yaml"), kubeconfigPath, colonyDir)
+	requireNoError(t, err)
+
+	state, err := k3s.State(ctx)
+	requireNoError(t, err)
+	if state != "running" {
+		t.Fatalf("expected the container to be running but got %q", state)
+	}
+
+	t.Run("refuses to create a second container", func(tt *testing.T) {
+		err := k3s.Create(ctx, "rancher/k3s:v1", filepath.Join(colonyDir, "colony.yaml"), kubeconfigPath, colonyDir)
+		if err == nil {
+			tt.Fatalf("expecting an error but got nil")
+		}
+
+		if len(runtime.Containers) != 1 {
+			tt.Fatalf("expected 1 container but got %d", len(runtime.Containers))
+		}
+	})
+}
+
+func TestK3s_Recreate(t *testing.T) {
+	ctx := context.Background()
+	k3s, runtime, colonyDir := newTestK3s(t)
+	kubeconfigPath := filepath.Join(colonyDir, constants.KubeconfigHostPath)
+
+	// a container with the data volume k3s declares
+	id, err := runtime.Create(ctx, Spec{
+		Name:   constants.ColonyK3sContainerName,
+		Image:  "rancher/k3s:v1",
+		Mounts: []Mount{{Type: MountVolume, Source: "k3s-data", Target: "/var/lib/rancher/k3s"}},
+	})
+	requireNoError(t, err)
+	requireNoError(t, runtime.Start(ctx, id))
+
+	err = k3s.Recreate(ctx, "rancher/k3s:v2", filepath.Join(colonyDir, "colony.yaml"), kubeconfigPath, colonyDir)
+	requireNoError(t, err)
+
+	if len(runtime.RemovedVolumes) != 0 {
+		t.Fatalf("expected no volume to be removed but got %v", runtime.RemovedVolumes)
+	}
+
+	recreated, err := runtime.Get(ctx, constants.ColonyK3sContainerName)
+	requireNoError(t, err)
+
+	if recreated.ID == id {
+		t.Fatalf("expected the container to be replaced")
+	}
+
+	var kept bool
+	for _, m := range recreated.Mounts {
+		if m.Type == MountVolume && m.Source == "k3s-data" {
+			kept = true
+		}
+	}
+	if !kept {
+		t.Fatalf("expected volume %q to be mounted in the new container, got %v", "k3s-data", recreated.Mounts)
+	}
+}
+
+func TestK3s_Remove(t *testing.T) {
+	ctx := context.Background()
+	k3s, runtime, _ := newTestK3s(t)
+
+	id, err := runtime.Create(ctx, Spec{
+		Name:   constants.ColonyK3sContainerName,
+		Mounts: []Mount{{Type: MountVolume, Source: "k3s-data", Target: "/var/lib/rancher/k3s"}},
+	})
+	requireNoError(t, err)
+	requireNoError(t, runtime.Start(ctx, id))
+
+	requireNoError(t, k3s.Remove(ctx))
+
+	exists, err := k3s.Exists(ctx)
+	requireNoError(t, err)
+	if exists {
+		t.Fatalf("expected the container to be removed")
+	}
+
+	if len(runtime.RemovedVolumes) != 1 || runtime.RemovedVolumes[0] != "k3s-data" {
+		t.Fatalf("expected volume %q to be removed but got %v", "k3s-data", runtime.RemovedVolumes)
+	}
+
+	if err := k3s.Remove(ctx); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected %q removing a missing container but got: %v", ErrNotFound, err)
+	}
+}
@@ -0,0 +1,78 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when a container does not exist.
+var ErrNotFound = errors.New("container not found")
+
+// MountType is the kind of a container mount.
+type MountType string
+
+const (
+	MountBind   MountType = "bind"
+	MountTmpfs  MountType = "tmpfs"
+	MountVolume MountType = "volume"
+)
+
+// Mount is a bind, tmpfs or volume mount of a container. Source is the
+// host path of a bind mount and the name of a volume.
+type Mount struct {
+	Type   MountType
+	Source string
+	Target string
+}
+
+// Spec describes a privileged, host-network container.
+type Spec struct {
+	Name   string
+	Image  string
+	Env    []string
+	Cmd    []string
+	Mounts []Mount
+}
+
+// Container is a container known to the runtime.
+type Container struct {
+	ID     string
+	Name   string
+	State  string
+	Mounts []Mount
+}
+
+// DaemonInfo holds the daemon details colony relies on to run its
+// privileged, host-network k3s container.
+type DaemonInfo struct {
+	ServerVersion string
+	OSType        string
+	CgroupVersion string
+	Rootless      bool
+}
+
+// Runtime is a container engine able to run the colony k3s container.
+type Runtime interface {
+	// Name returns the name of the runtime, e.g. "docker".
+	Name() string
+	// Info returns details about the runtime daemon.
+	Info(ctx context.Context) (*DaemonInfo, error)
+	// Get returns the container with the given name, or ErrNotFound.
+	Get(ctx context.Context, name string) (*Container, error)
+	// Create pulls the image and creates a privileged, host-network
+	// container from the spec. It returns the container ID.
+	Create(ctx context.Context, spec Spec) (string, error)
+	// Start starts the container.
+	Start(ctx context.Context, id string) error
+	// Stop stops the container.
+	Stop(ctx context.Context, id string) error
+	// Remove removes the container and, if removeVolumes is set, the
+	// volumes mounted into it.
+	Remove(ctx context.Context, id string, removeVolumes bool) error
+	// Logs writes the container logs to w, following them if follow
+	// is set until ctx is done.
+	Logs(ctx context.Context, id string, w io.Writer, follow bool) error
+	// Close releases the connection to the runtime.
+	Close() error
+}
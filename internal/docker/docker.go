@@ -2,30 +2,34 @@ package docker
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/docker/docker/api/types"
 	containerTypes "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/client"
-	"github.com/konstructio/colony/internal/constants"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/konstructio/colony/internal/container"
 	"github.com/konstructio/colony/internal/logger"
 )
 
-var ErrK3sContainerNotFound = errors.New("colony k3s container not found")
-
+// Client is a container.Runtime backed by the Docker Engine API. Podman
+// serves the same API on its socket, so the client also drives Podman.
 type Client struct {
-	cli *client.Client
-	log *logger.Logger
+	cli  *client.Client
+	log  *logger.Logger
+	name string
 }
 
+var _ container.Runtime = (*Client)(nil)
+
+// New creates a client for the docker daemon configured in the
+// environment (DOCKER_HOST and friends).
 func New(logger *logger.Logger) (*Client, error) {
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
@@ -33,186 +37,207 @@ func New(logger *logger.Logger) (*Client, error) {
 	}
 
 	return &Client{
-		cli: cli,
-		log: logger,
+		cli:  cli,
+		log:  logger.WithField("runtime", "docker"),
+		name: "docker",
 	}, nil
 }
 
-func (c *Client) Close() error {
-	return c.cli.Close() //nolint:wrapcheck // exposing the close to upstream callers
-}
-
-func (c *Client) CheckColonyK3sContainerExists(ctx context.Context) (bool, error) {
-	if _, err := c.getColonyK3sContainer(ctx); err != nil {
-		if errors.Is(err, ErrK3sContainerNotFound) {
-			return false, nil
+// NewPodman creates a client for the podman socket. CONTAINER_HOST is
+// used when set, otherwise the rootless socket of the current user if it
+// exists, and the rootful socket last.
+func NewPodman(logger *logger.Logger) (*Client, error) {
+	host := os.Getenv("CONTAINER_HOST")
+	if host == "" {
+		host = "unix:///run/podman/podman.sock"
+		if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+			socket := filepath.Join(runtimeDir, "podman", "podman.sock")
+			if _, err := os.Stat(socket); err == nil {
+				host = "unix://" + socket
+			}
 		}
-		return false, err
 	}
-	return true, nil
-}
 
-func (c *Client) getColonyK3sContainer(ctx context.Context) (*types.Container, error) {
-	containers, err := c.cli.ContainerList(ctx, containerTypes.ListOptions{All: true})
+	cli, err := client.NewClientWithOpts(client.WithHost(host), client.WithAPIVersionNegotiation())
 	if err != nil {
-		return nil, fmt.Errorf("error listing containers on host: %w", err)
+		return nil, fmt.Errorf("error creating podman client for %q: %w", host, err)
 	}
 
-	for _, container := range containers {
-		if len(container.Names) > 0 && container.Names[0] == "/"+constants.ColonyK3sContainerName {
-			return &container, nil
-		}
-	}
-	return nil, ErrK3sContainerNotFound
+	return &Client{
+		cli:  cli,
+		log:  logger.WithField("runtime", "podman"),
+		name: "podman",
+	}, nil
 }
 
-func (c *Client) RemoveColonyK3sContainer(ctx context.Context) error {
-	k3scontainer, err := c.getColonyK3sContainer(ctx)
-	if err != nil {
-		return fmt.Errorf("error getting %q container: %w", constants.ColonyK3sContainerName, err)
-	}
+func (c *Client) Close() error {
+	return c.cli.Close() //nolint:wrapcheck // exposing the close to upstream callers
+}
 
-	if len(k3scontainer.Names) > 0 && len(k3scontainer.ID) > constants.DefaultDockerIDLength {
-		c.log.Infof("found container name %q with ID %q", strings.TrimPrefix(k3scontainer.Names[0], "/"), k3scontainer.ID[:constants.DefaultDockerIDLength])
-	} else {
-		c.log.Warnf("found container with ID %q -- unable to parse a name or an ID out of it", k3scontainer.ID)
-	}
+// Name returns the name of the runtime behind the API.
+func (c *Client) Name() string {
+	return c.name
+}
 
-	err = c.cli.ContainerStop(ctx, k3scontainer.ID, containerTypes.StopOptions{})
+// Info returns details about the daemon.
+func (c *Client) Info(ctx context.Context) (*container.DaemonInfo, error) {
+	info, err := c.cli.Info(ctx)
 	if err != nil {
-		return fmt.Errorf("error stopping container: %w", err)
+		return nil, fmt.Errorf("error getting %s daemon info: %w", c.name, err)
 	}
 
-	err = c.cli.ContainerRemove(ctx, k3scontainer.ID, containerTypes.RemoveOptions{Force: true})
-	if err != nil {
-		return fmt.Errorf("error removing container: %w", err)
+	daemonInfo := &container.DaemonInfo{
+		ServerVersion: info.ServerVersion,
+		OSType:        info.OSType,
+		CgroupVersion: info.CgroupVersion,
 	}
 
-	for _, mount := range k3scontainer.Mounts {
-		if mount.Type == "volume" {
-			if err := c.cli.VolumeRemove(ctx, mount.Name, false); err != nil {
-				c.log.Warnf("error removing volume %q: %v, continuing...", mount.Name, err)
-			}
+	for _, opt := range info.SecurityOptions {
+		if strings.Contains(opt, "name=rootless") {
+			daemonInfo.Rootless = true
 		}
 	}
 
-	return nil
+	return daemonInfo, nil
 }
 
-func (c *Client) CreateColonyK3sContainer(ctx context.Context, colonyK3sBootstrapPath, colonyKubeconfigPath, homeDir string) error {
-	log := logger.New(logger.Debug)
-
-	// check for an existing colony-k3s container
-	k3sColonyContainer, err := c.getColonyK3sContainer(ctx)
-	if k3sColonyContainer != nil {
-		return fmt.Errorf("%q container already exists. please remove before continuing or run `colony destroy`", constants.ColonyK3sContainerName)
+// Get returns the container with the given name.
+func (c *Client) Get(ctx context.Context, name string) (*container.Container, error) {
+	containers, err := c.cli.ContainerList(ctx, containerTypes.ListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("error listing containers on host: %w", err)
 	}
 
-	if err != nil && !errors.Is(err, ErrK3sContainerNotFound) {
-		return fmt.Errorf("docker error: %w", err)
+	for _, ctr := range containers {
+		if len(ctr.Names) > 0 && ctr.Names[0] == "/"+name {
+			return &container.Container{
+				ID:     ctr.ID,
+				Name:   name,
+				State:  ctr.State,
+				Mounts: fromMountPoints(ctr.Mounts),
+			}, nil
+		}
 	}
 
-	// Pull the rancher/k3s image if it's not already available
-	imageName := "rancher/k3s:v1.30.2-k3s1"
-	reader, err := c.cli.ImagePull(ctx, imageName, image.PullOptions{})
+	return nil, container.ErrNotFound
+}
+
+// Create pulls the image and creates a privileged, host-network
+// container from the spec.
+func (c *Client) Create(ctx context.Context, spec container.Spec) (string, error) {
+	reader, err := c.cli.ImagePull(ctx, spec.Image, image.PullOptions{})
 	if err != nil {
-		return fmt.Errorf("error pulling image %q: %w", imageName, err)
+		return "", fmt.Errorf("error pulling image %q: %w", spec.Image, err)
 	}
 	defer reader.Close()
 
-	log.Infof("pulled image %q successfully", imageName)
-
 	// c.cli.ImagePull is asynchronous.
 	// The reader needs to be read completely for the pull operation to complete.
-	// If stdout is not required, consider using io.Discard instead of os.Stdout.
-	io.Copy(os.Stdout, reader)
-
-	env := []string{
-		fmt.Sprintf("K3S_KUBECONFIG_OUTPUT=%s", colonyKubeconfigPath),
-		"K3S_KUBECONFIG_MODE=666",
-	}
-
-	mounts := []mount.Mount{
-		{
-			Type:   mount.TypeBind,
-			Source: filepath.Join(homeDir, constants.ColonyDir),
-			Target: filepath.Join(homeDir, constants.ColonyDir),
-		},
-		{
-			Type:   mount.TypeBind,
-			Source: colonyK3sBootstrapPath,
-			Target: fmt.Sprintf("/var/lib/rancher/k3s/server/manifests/%s", constants.ColonyYamlPath),
-		},
-		{
-			Type:   mount.TypeTmpfs,
-			Target: "/run",
-		},
-		{
-			Type:   mount.TypeTmpfs,
-			Target: "/var/run",
-		},
+	// The progress is discarded to keep stdout for command results.
+	io.Copy(io.Discard, reader)
+
+	c.log.Infof("pulled image %q successfully", spec.Image)
+
+	mounts := make([]mount.Mount, 0, len(spec.Mounts))
+	for _, m := range spec.Mounts {
+		mounts = append(mounts, mount.Mount{
+			Type:   mount.Type(m.Type),
+			Source: m.Source,
+			Target: m.Target,
+		})
 	}
 
 	resp, err := c.cli.ContainerCreate(ctx, &containerTypes.Config{
-		Image: imageName,
-		Env:   env,
-		Cmd: []string{
-			"server",
-			"--disable=traefik,servicelb",
-			"--tls-san=colony",
-			"--node-label=colony.konstruct.io/node-type=colony",
-		},
+		Image: spec.Image,
+		Env:   spec.Env,
+		Cmd:   spec.Cmd,
 	}, &containerTypes.HostConfig{
 		Privileged:  true,
 		NetworkMode: "host",
 		Mounts:      mounts,
-	}, nil, nil, constants.ColonyK3sContainerName)
+	}, nil, nil, spec.Name)
 	if err != nil {
-		return fmt.Errorf("error creating container: %w", err)
+		return "", fmt.Errorf("error creating container: %w", err)
 	}
 
-	log.Infof("created container with ID %q", resp.ID)
+	return resp.ID, nil
+}
 
-	if err := c.cli.ContainerStart(ctx, resp.ID, containerTypes.StartOptions{}); err != nil {
+func (c *Client) Start(ctx context.Context, id string) error {
+	if err := c.cli.ContainerStart(ctx, id, containerTypes.StartOptions{}); err != nil {
 		return fmt.Errorf("error starting container: %w", err)
 	}
+	return nil
+}
+
+func (c *Client) Stop(ctx context.Context, id string) error {
+	if err := c.cli.ContainerStop(ctx, id, containerTypes.StopOptions{}); err != nil {
+		return fmt.Errorf("error stopping container: %w", err)
+	}
+	return nil
+}
 
-	waitInterval := 2 * time.Second
-	timeout := 15 * time.Second
+// Remove removes the container. Volume removal failures are logged and
+// ignored.
+func (c *Client) Remove(ctx context.Context, id string, removeVolumes bool) error {
+	inspect, err := c.cli.ContainerInspect(ctx, id)
+	if err != nil {
+		return fmt.Errorf("error inspecting container: %w", err)
+	}
 
-	log.Infof("Checking for file %s every %.0f seconds...", colonyKubeconfigPath, waitInterval.Seconds())
+	if err := c.cli.ContainerRemove(ctx, id, containerTypes.RemoveOptions{Force: true}); err != nil {
+		return fmt.Errorf("error removing container: %w", err)
+	}
 
-	err = waitUntilFileExists(log, colonyKubeconfigPath, waitInterval, timeout)
+	if !removeVolumes {
+		return nil
+	}
+
+	for _, m := range inspect.Mounts {
+		if m.Type == mount.TypeVolume {
+			if err := c.cli.VolumeRemove(ctx, m.Name, false); err != nil {
+				c.log.Warnf("error removing volume %q: %v, continuing...", m.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Logs writes the container stdout and stderr to w.
+func (c *Client) Logs(ctx context.Context, id string, w io.Writer, follow bool) error {
+	reader, err := c.cli.ContainerLogs(ctx, id, containerTypes.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     follow,
+	})
 	if err != nil {
-		return fmt.Errorf("error waiting for kubeconfig file: %w", err)
+		return fmt.Errorf("error getting container logs: %w", err)
+	}
+	defer reader.Close()
+
+	// containers without a tty multiplex stdout and stderr
+	if _, err := stdcopy.StdCopy(w, w, reader); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("error reading container logs: %w", err)
 	}
 
 	return nil
 }
 
-func waitUntilFileExists(log *logger.Logger, filename string, interval, timeout time.Duration) error {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("timeout reached while waiting for file %s", filename)
-		case <-ticker.C:
-			if _, err := os.Stat(filename); err != nil {
-				if os.IsNotExist(err) {
-					log.Infof("waiting for file %q...", filename)
-					continue
-				}
-
-				return fmt.Errorf("error checking file: %w", err)
-			}
+func fromMountPoints(points []types.MountPoint) []container.Mount {
+	mounts := make([]container.Mount, 0, len(points))
+	for _, p := range points {
+		m := container.Mount{
+			Type:   container.MountType(p.Type),
+			Source: p.Source,
+			Target: p.Destination,
+		}
 
-			log.Infof("found and stat'd file %q", filename)
-			return nil
+		if p.Type == mount.TypeVolume {
+			m.Source = p.Name
 		}
+
+		mounts = append(mounts, m)
 	}
+	return mounts
 }
@@ -0,0 +1,137 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/konstructio/colony/internal/exec"
+	"github.com/konstructio/colony/internal/logger"
+	"sigs.k8s.io/yaml"
+)
+
+// Chart is a helm release described by a k3s HelmChart manifest.
+type Chart struct {
+	Name      string
+	Namespace string
+	Repo      string
+	Chart     string
+	Version   string
+	Values    string
+}
+
+// helmChart is the subset of the k3s HelmChart resource colony renders.
+type helmChart struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Repo            string `json:"repo"`
+		Chart           string `json:"chart"`
+		TargetNamespace string `json:"targetNamespace"`
+		Version         string `json:"version"`
+		ValuesContent   string `json:"valuesContent"`
+	} `json:"spec"`
+}
+
+// ChartsFromManifests returns the charts of every HelmChart in the
+// manifests, other kinds are ignored.
+func ChartsFromManifests(manifests []string) ([]Chart, error) {
+	var charts []Chart
+
+	for _, manifest := range manifests {
+		var hc helmChart
+		if err := yaml.Unmarshal([]byte(manifest), &hc); err != nil {
+			return nil, fmt.Errorf("error parsing manifest: %w", err)
+		}
+
+		if hc.Kind != "HelmChart" {
+			continue
+		}
+
+		charts = append(charts, Chart{
+			Name:      hc.Metadata.Name,
+			Namespace: hc.Spec.TargetNamespace,
+			Repo:      hc.Spec.Repo,
+			Chart:     hc.Spec.Chart,
+			Version:   hc.Spec.Version,
+			Values:    hc.Spec.ValuesContent,
+		})
+	}
+
+	return charts, nil
+}
+
+// Client runs the helm cli against a cluster.
+type Client struct {
+	logger     *logger.Logger
+	kubeconfig string
+}
+
+// New creates a helm client for the cluster in kubeconfig.
+func New(logger *logger.Logger, kubeconfig string) *Client {
+	return &Client{
+		logger:     logger,
+		kubeconfig: kubeconfig,
+	}
+}
+
+// Install installs the chart, or upgrades it if it is already installed.
+func (c *Client) Install(chart Chart) error {
+	values, err := os.CreateTemp("", fmt.Sprintf("colony-%s-values-*.yaml", chart.Name))
+	if err != nil {
+		return fmt.Errorf("error creating values file: %w", err)
+	}
+	defer os.Remove(values.Name())
+
+	if _, err := values.WriteString(chart.Values); err != nil {
+		values.Close()
+		return fmt.Errorf("error writing values file: %w", err)
+	}
+
+	if err := values.Close(); err != nil {
+		return fmt.Errorf("error writing values file: %w", err)
+	}
+
+	args := []string{
+		"upgrade", "--install", chart.Name, chart.Chart,
+		"--namespace", chart.Namespace,
+		"--create-namespace",
+		"--values", values.Name(),
+		"--kubeconfig", c.kubeconfig,
+	}
+
+	if chart.Version != "" {
+		args = append(args, "--version", chart.Version)
+	}
+
+	// oci charts carry their registry in the chart reference
+	if chart.Repo != "" && !strings.HasPrefix(chart.Chart, "oci://") {
+		args = append(args, "--repo", chart.Repo)
+	}
+
+	c.logger.Infof("installing chart %q version %q in namespace %q", chart.Name, chart.Version, chart.Namespace)
+	if _, err := exec.ExecuteCommand(c.logger, "helm", args...); err != nil {
+		return fmt.Errorf("error installing chart %q: %w", chart.Name, err)
+	}
+
+	return nil
+}
+
+// Uninstall removes the chart release. A release that is not installed
+// is not an error.
+func (c *Client) Uninstall(chart Chart) error {
+	c.logger.Infof("uninstalling chart %q from namespace %q", chart.Name, chart.Namespace)
+
+	_, err := exec.ExecuteCommand(c.logger, "helm", "uninstall", chart.Name,
+		"--namespace", chart.Namespace,
+		"--ignore-not-found",
+		"--kubeconfig", c.kubeconfig,
+	)
+	if err != nil {
+		return fmt.Errorf("error uninstalling chart %q: %w", chart.Name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package helm
+
+import (
+	"testing"
+)
+
+func TestChartsFromManifests(t *testing.T) {
+	manifests := []string{
+		`apiVersion: v1
+kind: Namespace
+metadata:
+  name: tink-system`,
+		`apiVersion: helm.cattle.io/v1
+kind: HelmChart
+metadata:
+  name: colony
+  namespace: tink-system
+spec:
+  repo: https://charts.konstruct.io
+  chart: colony
+  targetNamespace: tink-system
+  version: 0.2.2
+  valuesContent: |-
+    colony-agent:
+      replicas: 1`,
+	}
+
+	charts, err := ChartsFromManifests(manifests)
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	if len(charts) != 1 {
+		t.Fatalf("expected 1 chart but got %d", len(charts))
+	}
+
+	expected := Chart{
+		Name:      "colony",
+		Namespace: "tink-system",
+		Repo:      "https://charts.konstruct.io",
+		Chart:     "colony",
+		Version:   "0.2.2",
+		Values:    "colony-agent:\n  replicas: 1",
+	}
+
+	if charts[0] != expected {
+		t.Fatalf("expected chart %+v but got %+v", expected, charts[0])
+	}
+}
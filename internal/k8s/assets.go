@@ -0,0 +1,171 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/konstructio/colony/internal/constants"
+	"github.com/kubefirst/tink/api/v1alpha1"
+	rufiov1alpha1 "github.com/tinkerbell/rufio/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Labels set on the rufio machines and ipmi auth secrets.
+const (
+	labelName        = "colony.konstruct.io/name"
+	labelHardwareID  = "colony.konstruct.io/hardware-id"
+	labelBoardSerial = "colony.konstruct.io/board-serial"
+)
+
+var (
+	hardwareGVR = schema.GroupVersionResource{
+		Group:    v1alpha1.GroupVersion.Group,
+		Version:  v1alpha1.GroupVersion.Version,
+		Resource: "hardware",
+	}
+	machineGVR = schema.GroupVersionResource{
+		Group:    rufiov1alpha1.GroupVersion.Group,
+		Version:  rufiov1alpha1.GroupVersion.Version,
+		Resource: "machines",
+	}
+	rufioJobGVR = schema.GroupVersionResource{
+		Group:    rufiov1alpha1.GroupVersion.Group,
+		Version:  rufiov1alpha1.GroupVersion.Version,
+		Resource: "jobs",
+	}
+	workflowGVR = schema.GroupVersionResource{
+		Group:    v1alpha1.GroupVersion.Group,
+		Version:  v1alpha1.GroupVersion.Version,
+		Resource: "workflows",
+	}
+)
+
+// Asset is a hardware of the data center along with its BMC.
+type Asset struct {
+	Name        string    `json:"name"`
+	Hostname    string    `json:"hostname,omitempty"`
+	IP          string    `json:"ip,omitempty"`
+	MAC         string    `json:"mac,omitempty"`
+	Status      string    `json:"status,omitempty"`
+	BMCIP       string    `json:"bmcIP,omitempty"`
+	BoardSerial string    `json:"boardSerial,omitempty"`
+	PowerState  string    `json:"powerState,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// ListAssets returns the hardware of the data center. The BMC of a
+// hardware is its bmcRef machine, else the machine of the ipmi auth
+// secret labelled with the hardware id.
+func (c *Client) ListAssets(ctx context.Context) ([]Asset, error) {
+	hardwares, err := c.ListObjects(ctx, hardwareGVR, constants.ColonyNamespace, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	machineList, err := c.ListObjects(ctx, machineGVR, constants.ColonyNamespace, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	machines := make(map[string]*rufiov1alpha1.Machine, len(machineList))
+	for i := range machineList {
+		m := &rufiov1alpha1.Machine{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(machineList[i].UnstructuredContent(), m); err != nil {
+			return nil, fmt.Errorf("error converting unstructured to machine: %w", err)
+		}
+		machines[m.Name] = m
+	}
+
+	secrets, err := c.clientSet.CoreV1().Secrets(constants.ColonyNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labelHardwareID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing ipmi auth secrets: %w", err)
+	}
+
+	// hardware id to machine name
+	machineRefs := make(map[string]string, len(secrets.Items))
+	for _, s := range secrets.Items {
+		machineRefs[s.Labels[labelHardwareID]] = s.Labels[labelName]
+	}
+
+	assets := make([]Asset, 0, len(hardwares))
+	for i := range hardwares {
+		hw := &v1alpha1.Hardware{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(hardwares[i].UnstructuredContent(), hw); err != nil {
+			return nil, fmt.Errorf("error converting unstructured to hardware: %w", err)
+		}
+
+		machineName := machineRefs[hw.Name]
+		if hw.Spec.BMCRef != nil {
+			machineName = hw.Spec.BMCRef.Name
+		}
+
+		assets = append(assets, newAsset(hw, machines[machineName]))
+	}
+
+	return assets, nil
+}
+
+func newAsset(hw *v1alpha1.Hardware, machine *rufiov1alpha1.Machine) Asset {
+	asset := Asset{
+		Name:      hw.Name,
+		Hostname:  hw.Annotations["inspection-status"],
+		Status:    string(hw.Status.State),
+		CreatedAt: hw.CreationTimestamp.UTC(),
+	}
+
+	if len(hw.Spec.Interfaces) > 0 && hw.Spec.Interfaces[0].DHCP != nil {
+		dhcp := hw.Spec.Interfaces[0].DHCP
+		asset.MAC = dhcp.MAC
+		if dhcp.IP != nil {
+			asset.IP = dhcp.IP.Address
+		}
+	}
+
+	if machine != nil {
+		asset.BMCIP = machine.Spec.Connection.Host
+		asset.BoardSerial = machine.Labels[labelBoardSerial]
+		asset.PowerState = string(machine.Status.Power)
+	}
+
+	return asset
+}
+
+// MachinesBySerial returns the names of the rufio machines by the board
+// serial they were labelled with when added.
+func (c *Client) MachinesBySerial(ctx context.Context) (map[string]string, error) {
+	machines, err := c.ListObjects(ctx, machineGVR, constants.ColonyNamespace, metav1.ListOptions{
+		LabelSelector: labelBoardSerial,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	serials := make(map[string]string, len(machines))
+	for _, m := range machines {
+		if serial := m.GetLabels()[labelBoardSerial]; serial != "" {
+			serials[serial] = m.GetName()
+		}
+	}
+
+	return serials, nil
+}
+
+// MachineNames returns the names of the rufio machines.
+func (c *Client) MachineNames(ctx context.Context) (map[string]bool, error) {
+	machines, err := c.ListObjects(ctx, machineGVR, constants.ColonyNamespace, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(machines))
+	for _, m := range machines {
+		names[m.GetName()] = true
+	}
+
+	return names, nil
+}
@@ -0,0 +1,142 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/konstructio/colony/internal/constants"
+	"github.com/kubefirst/tink/api/v1alpha1"
+	rufiov1alpha1 "github.com/tinkerbell/rufio/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// labelIP is the label of the rufio machines holding the BMC ip.
+const labelIP = "colony.konstruct.io/ip"
+
+// BMC is a BMC of the data center as seen by rufio.
+type BMC struct {
+	Name        string `json:"name"`
+	IP          string `json:"ip"`
+	HardwareID  string `json:"hardwareID,omitempty"`
+	BoardSerial string `json:"boardSerial,omitempty"`
+	// Contactable is nil until rufio first tried to reach the BMC.
+	Contactable *bool  `json:"contactable,omitempty"`
+	PowerState  string `json:"powerState,omitempty"`
+	// ContactableSince is when rufio last recorded a change of
+	// reachability: when a reachable BMC became reachable, or when an
+	// unreachable one stopped answering.
+	ContactableSince time.Time `json:"contactableSince"`
+	Message          string    `json:"message,omitempty"`
+}
+
+// ListBMCs returns the BMCs of the rufio machines and ipmi auth secrets,
+// along with the hardware they manage.
+func (c *Client) ListBMCs(ctx context.Context) ([]BMC, error) {
+	machines, err := c.ListObjects(ctx, machineGVR, constants.ColonyNamespace, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := c.clientSet.CoreV1().Secrets(constants.ColonyNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labelType + "=" + typeIPMIAuth,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing ipmi auth secrets: %w", err)
+	}
+
+	hardwares, err := c.ListObjects(ctx, hardwareGVR, constants.ColonyNamespace, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// machine name to hardware id, from the bmcRef of the hardware
+	hardwareIDs := make(map[string]string, len(hardwares))
+	for i := range hardwares {
+		hw := &v1alpha1.Hardware{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(hardwares[i].UnstructuredContent(), hw); err != nil {
+			return nil, fmt.Errorf("error converting unstructured to hardware: %w", err)
+		}
+		if hw.Spec.BMCRef != nil {
+			hardwareIDs[hw.Spec.BMCRef.Name] = hw.Name
+		}
+	}
+
+	bmcs := make(map[string]*BMC, len(machines))
+
+	for i := range machines {
+		m := &rufiov1alpha1.Machine{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(machines[i].UnstructuredContent(), m); err != nil {
+			return nil, fmt.Errorf("error converting unstructured to machine: %w", err)
+		}
+
+		bmc := &BMC{
+			Name:        m.Name,
+			IP:          m.Spec.Connection.Host,
+			HardwareID:  hardwareIDs[m.Name],
+			BoardSerial: m.Labels[labelBoardSerial],
+			PowerState:  string(m.Status.Power),
+		}
+		if bmc.IP == "" {
+			bmc.IP = m.Labels[labelIP]
+		}
+
+		for _, condition := range m.Status.Conditions {
+			if condition.Type != rufiov1alpha1.Contactable {
+				continue
+			}
+
+			contactable := condition.Status == rufiov1alpha1.ConditionTrue
+			bmc.Contactable = &contactable
+			bmc.Message = condition.Message
+			bmc.ContactableSince = condition.LastUpdateTime.UTC()
+		}
+
+		bmcs[m.Name] = bmc
+	}
+
+	// secrets without a machine are BMCs rufio does not manage yet
+	for _, s := range secrets.Items {
+		bmc, ok := bmcs[s.Name]
+		if !ok {
+			bmc = &BMC{Name: s.Name, IP: secretIP(s.Name, s.Labels)}
+			bmcs[s.Name] = bmc
+		}
+
+		if id := s.Labels[labelHardwareID]; id != "" && bmc.HardwareID == "" {
+			bmc.HardwareID = id
+		}
+		if serial := s.Labels[labelBoardSerial]; serial != "" && bmc.BoardSerial == "" {
+			bmc.BoardSerial = serial
+		}
+	}
+
+	list := make([]BMC, 0, len(bmcs))
+	for _, bmc := range bmcs {
+		list = append(list, *bmc)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list, nil
+}
+
+// secretIP returns the BMC ip of an ipmi auth secret, from its label or
+// else from its name, the ip with dashes for dots.
+func secretIP(name string, labels map[string]string) string {
+	if ip := labels[labelIP]; ip != "" {
+		return ip
+	}
+
+	if addr, err := netip.ParseAddr(strings.ReplaceAll(name, "-", ".")); err == nil && addr.Is4() {
+		return addr.String()
+	}
+
+	return ""
+}
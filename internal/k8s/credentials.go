@@ -0,0 +1,153 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/konstructio/colony/internal/constants"
+	rufiov1alpha1 "github.com/tinkerbell/rufio/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Labels and annotations of the ipmi auth secrets.
+const (
+	labelType           = "colony.konstruct.io/type"
+	typeIPMIAuth        = "ipmi-auth"
+	annotationUpdatedAt = "colony.konstruct.io/credentials-updated-at"
+	annotationUpdatedBy = "colony.konstruct.io/credentials-updated-by"
+
+	typeIPMIAuthRecovery = "ipmi-auth-recovery"
+	recoverySuffix       = "-recovery"
+)
+
+// BMCCredentials are the credentials of a BMC stored in its ipmi auth
+// secret.
+type BMCCredentials struct {
+	SecretName string    `json:"secretName"`
+	HardwareID string    `json:"hardwareID,omitempty"`
+	Host       string    `json:"host,omitempty"`
+	Username   string    `json:"username"`
+	Password   string    `json:"-"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	UpdatedBy  string    `json:"updatedBy,omitempty"`
+
+	// resourceVersion of the secret, so updates fail if it changed since
+	resourceVersion string
+}
+
+// ListBMCCredentials returns the credentials of the ipmi auth secrets
+// matching the label selector, along with the host of their rufio
+// machine. Secrets never updated by colony report their creation.
+func (c *Client) ListBMCCredentials(ctx context.Context, selector string) ([]BMCCredentials, error) {
+	labelSelector := labelType + "=" + typeIPMIAuth
+	if selector != "" {
+		labelSelector += "," + selector
+	}
+
+	secrets, err := c.clientSet.CoreV1().Secrets(constants.ColonyNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing ipmi auth secrets: %w", err)
+	}
+
+	creds := make([]BMCCredentials, 0, len(secrets.Items))
+	for _, s := range secrets.Items {
+		cred := BMCCredentials{
+			SecretName:      s.Name,
+			HardwareID:      s.Labels[labelHardwareID],
+			Username:        string(s.Data["username"]),
+			Password:        string(s.Data["password"]),
+			UpdatedAt:       s.CreationTimestamp.UTC(),
+			UpdatedBy:       s.Annotations[annotationUpdatedBy],
+			resourceVersion: s.ResourceVersion,
+		}
+
+		if at, err := time.Parse(time.RFC3339, s.Annotations[annotationUpdatedAt]); err == nil {
+			cred.UpdatedAt = at
+		}
+
+		obj, err := c.GetObject(ctx, machineGVR, constants.ColonyNamespace, s.Name)
+		if err != nil {
+			return nil, err
+		}
+		if obj != nil {
+			m := &rufiov1alpha1.Machine{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), m); err != nil {
+				return nil, fmt.Errorf("error converting unstructured to machine: %w", err)
+			}
+			cred.Host = m.Spec.Connection.Host
+		}
+
+		creds = append(creds, cred)
+	}
+
+	return creds, nil
+}
+
+// UpdateBMCPassword stores a new password in the ipmi auth secret of the
+// credentials, recording who changed it and when. The update fails if the
+// secret changed since the credentials were listed.
+func (c *Client) UpdateBMCPassword(ctx context.Context, cred BMCCredentials, password, updatedBy string) error {
+	secrets := c.clientSet.CoreV1().Secrets(constants.ColonyNamespace)
+
+	secret, err := secrets.Get(ctx, cred.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("error getting secret %q: %w", cred.SecretName, err)
+	}
+
+	if cred.resourceVersion != "" && secret.ResourceVersion != cred.resourceVersion {
+		return fmt.Errorf("secret %q was changed while its credentials were rotated", cred.SecretName)
+	}
+
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+	secret.Data["password"] = []byte(password)
+
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+	secret.Annotations[annotationUpdatedAt] = time.Now().UTC().Format(time.RFC3339)
+	secret.Annotations[annotationUpdatedBy] = updatedBy
+
+	// the api server rejects the update if the resource version changed
+	if _, err := secrets.Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating secret %q: %w", cred.SecretName, err)
+	}
+
+	return nil
+}
+
+// SaveBMCRecoveryPassword stores a password set on a BMC that could not be
+// stored in its ipmi auth secret, in a separate recovery secret, so the
+// BMC stays reachable. It returns the name of the recovery secret.
+func (c *Client) SaveBMCRecoveryPassword(ctx context.Context, cred BMCCredentials, password, updatedBy string) (string, error) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cred.SecretName + recoverySuffix,
+			Namespace: constants.ColonyNamespace,
+			Labels: map[string]string{
+				labelName: cred.SecretName,
+				labelType: typeIPMIAuthRecovery,
+			},
+			Annotations: map[string]string{
+				annotationUpdatedAt: time.Now().UTC().Format(time.RFC3339),
+				annotationUpdatedBy: updatedBy,
+			},
+		},
+		Data: map[string][]byte{
+			"username": []byte(cred.Username),
+			"password": []byte(password),
+		},
+	}
+
+	if err := c.ApplySecret(ctx, secret); err != nil {
+		return "", err
+	}
+
+	return secret.Name, nil
+}
@@ -0,0 +1,169 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/konstructio/colony/internal/constants"
+	"github.com/kubefirst/tink/api/v1alpha1"
+	rufiov1alpha1 "github.com/tinkerbell/rufio/api/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer/yaml"
+)
+
+// ErrDeploymentNotFound is returned when no deployment matches the labels.
+var ErrDeploymentNotFound = errors.New("deployment not found")
+
+// DeploymentStatus is a point in time readiness of a deployment.
+type DeploymentStatus struct {
+	Name      string
+	Namespace string
+	Ready     int32
+	Desired   int32
+}
+
+// ServerVersion returns the version of the Kubernetes API server.
+func (c *Client) ServerVersion() (string, error) {
+	version, err := c.clientSet.Discovery().ServerVersion()
+	if err != nil {
+		return "", fmt.Errorf("error getting server version: %w", err)
+	}
+	return version.GitVersion, nil
+}
+
+// GetDeploymentStatus returns the readiness of the first deployment
+// matching the details, without waiting for it.
+func (c *Client) GetDeploymentStatus(ctx context.Context, deployment DeploymentDetails) (*DeploymentStatus, error) {
+	deployments, err := c.clientSet.AppsV1().Deployments(deployment.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", deployment.Label, deployment.Value),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing deployments: %w", err)
+	}
+
+	if len(deployments.Items) == 0 {
+		return nil, fmt.Errorf("%w: %s=%s in namespace %q", ErrDeploymentNotFound, deployment.Label, deployment.Value, deployment.Namespace)
+	}
+
+	d := deployments.Items[0]
+
+	var desired int32 = 1
+	if d.Spec.Replicas != nil {
+		desired = *d.Spec.Replicas
+	}
+
+	return &DeploymentStatus{
+		Name:      d.Name,
+		Namespace: d.Namespace,
+		Ready:     d.Status.ReadyReplicas,
+		Desired:   desired,
+	}, nil
+}
+
+// GetJob returns the batch Job with the given name.
+func (c *Client) GetJob(ctx context.Context, namespace, name string) (*batchv1.Job, error) {
+	job, err := c.clientSet.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting job %q in namespace %q: %w", name, namespace, err)
+	}
+	return job, nil
+}
+
+// MissingManifests returns the "kind/name" of every resource in the
+// manifests that does not exist in the cluster.
+func (c *Client) MissingManifests(ctx context.Context, manifests []string) ([]string, error) {
+	decoderUnstructured := yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme)
+
+	var missing []string
+	for _, manifest := range manifests {
+		var obj unstructured.Unstructured
+		_, gvk, err := decoderUnstructured.Decode([]byte(manifest), nil, &obj)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding manifest: %w", err)
+		}
+
+		mapping, err := c.restmapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return nil, fmt.Errorf("unable to map manifest to a Kubernetes resource: %w", err)
+		}
+
+		_, err = c.dynamic.Resource(mapping.Resource).Namespace(obj.GetNamespace()).Get(ctx, obj.GetName(), metav1.GetOptions{})
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				missing = append(missing, fmt.Sprintf("%s/%s", gvk.Kind, obj.GetName()))
+				continue
+			}
+			return nil, fmt.Errorf("error getting %s %q: %w", gvk.Kind, obj.GetName(), err)
+		}
+	}
+
+	return missing, nil
+}
+
+// CountHardwareByState returns the number of Hardware in each state.
+// Hardware without a state is counted as "unknown".
+func (c *Client) CountHardwareByState(ctx context.Context) (map[string]int, error) {
+	hardwares, err := c.ListObjects(ctx, hardwareGVR, constants.ColonyNamespace, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, hw := range hardwares {
+		state, _, _ := unstructured.NestedString(hw.Object, "status", "state")
+		if state == "" {
+			state = "unknown"
+		}
+		counts[state]++
+	}
+
+	return counts, nil
+}
+
+// RunningRufioJobs returns the names of the rufio Jobs that have neither
+// completed nor failed.
+func (c *Client) RunningRufioJobs(ctx context.Context) ([]string, error) {
+	jobs, err := c.ListObjects(ctx, rufioJobGVR, constants.ColonyNamespace, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var running []string
+	for i := range jobs {
+		job := &rufiov1alpha1.Job{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(jobs[i].UnstructuredContent(), job); err != nil {
+			return nil, fmt.Errorf("error converting unstructured to job: %w", err)
+		}
+
+		if job.HasCondition(rufiov1alpha1.JobCompleted, rufiov1alpha1.ConditionTrue) || job.HasCondition(rufiov1alpha1.JobFailed, rufiov1alpha1.ConditionTrue) {
+			continue
+		}
+		running = append(running, job.Name)
+	}
+
+	return running, nil
+}
+
+// RunningWorkflows returns the names of the pending and running
+// Workflows.
+func (c *Client) RunningWorkflows(ctx context.Context) ([]string, error) {
+	workflows, err := c.ListObjects(ctx, workflowGVR, constants.ColonyNamespace, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var running []string
+	for _, wf := range workflows {
+		state, _, _ := unstructured.NestedString(wf.Object, "status", "state")
+		if state == string(v1alpha1.WorkflowStatePending) || state == string(v1alpha1.WorkflowStateRunning) {
+			running = append(running, wf.GetName())
+		}
+	}
+
+	return running, nil
+}
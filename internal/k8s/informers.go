@@ -3,8 +3,10 @@ package k8s
 import (
 	"context"
 	"strings"
+	"sync/atomic"
 
 	"github.com/konstructio/colony/internal/constants"
+	"github.com/konstructio/colony/internal/logger"
 	"github.com/kubefirst/tink/api/v1alpha1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -12,15 +14,51 @@ import (
 	"k8s.io/client-go/tools/cache"
 )
 
-func (c *Client) HardwareInformer(ctx context.Context, ipmiIP string, hardwareChan chan *v1alpha1.Hardware) error {
+// HardwareMatch identifies the hardware discovered behind a BMC, either by
+// its bmcRef or by one of the NIC MAC addresses the BMC reported.
+type HardwareMatch struct {
+	BMCIP string
+	MACs  []string
+}
+
+// Matches returns true if hw is the hardware behind the BMC.
+func (m HardwareMatch) Matches(hw *v1alpha1.Hardware) bool {
+	if hw.Spec.BMCRef != nil && hw.Spec.BMCRef.Name == strings.ReplaceAll(m.BMCIP, ".", "-") {
+		return true
+	}
+
+	for _, iface := range hw.Spec.Interfaces {
+		if iface.DHCP == nil || iface.DHCP.MAC == "" {
+			continue
+		}
+		for _, mac := range m.MACs {
+			if strings.EqualFold(iface.DHCP.MAC, mac) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// HardwareInformer waits for the hardware behind the BMC to be created,
+// labels the BMC secret with its id and sends it to hardwareChan. The
+// hardware that already exists when the informer starts is ignored.
+func (c *Client) HardwareInformer(ctx context.Context, match HardwareMatch, hardwareChan chan *v1alpha1.Hardware) error {
 	// Create a new informer for the hardware resource
 	resource := v1alpha1.GroupVersion.WithResource("hardware")
 	factory := dynamicinformer.NewDynamicSharedInformerFactory(c.dynamic, 0)
 	informer := factory.ForResource(resource).Informer()
 
+	var found atomic.Bool
+
 	// Add event handlers to the informer
-	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
+	informer.AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if isInInitialList || found.Load() {
+				return
+			}
+
 			hw := &v1alpha1.Hardware{}
 			unst, ok := obj.(*unstructured.Unstructured)
 			if !ok {
@@ -33,16 +71,35 @@ func (c *Client) HardwareInformer(ctx context.Context, ipmiIP string, hardwareCh
 				return
 			}
 
-			c.logger.Infof("Hardware %q created by - id: %q \n", hw.Name, hw.ObjectMeta.UID)
+			log := c.logger.WithFields(logger.Fields{
+				logger.FieldHardwareID: hw.Name,
+				logger.FieldBMCIP:      match.BMCIP,
+			})
 
-			err := c.SecretAddLabel(ctx, strings.ReplaceAll(ipmiIP, ".", "-"), constants.ColonyNamespace, "colony.konstruct.io/hardware-id", hw.Name)
+			if !match.Matches(hw) {
+				log.Debugf("ignoring hardware %q discovered behind another bmc", hw.Name)
+				return
+			}
+
+			if !found.CompareAndSwap(false, true) {
+				return
+			}
+
+			log.Infof("Hardware %q created by - id: %q \n", hw.Name, hw.ObjectMeta.UID)
+
+			err := c.SecretAddLabel(ctx, strings.ReplaceAll(match.BMCIP, ".", "-"), constants.ColonyNamespace, labelHardwareID, hw.Name)
 			if err != nil {
-				c.logger.Errorf("Error adding label to secret: %v\n", err)
+				log.Errorf("Error adding label to secret: %v\n", err)
+				found.Store(false)
 				return
 			}
-			c.logger.Infof("added label to ipmi secret: %s\n", hw.Name)
-			// Send the hardware object through the channel
-			hardwareChan <- hw
+			log.Infof("added label to ipmi secret: %s\n", hw.Name)
+			// Send the hardware object through the channel, unless the
+			// caller stopped waiting
+			select {
+			case hardwareChan <- hw:
+			case <-ctx.Done():
+			}
 		},
 	})
 
@@ -0,0 +1,145 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bmc-toolbox/common"
+	"github.com/konstructio/colony/internal/constants"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// The inventory of a BMC is stored in a ConfigMap named after its rufio
+// machine and owned by it.
+const (
+	typeBMCInventory        = "bmc-inventory"
+	inventorySuffix         = "-inventory"
+	inventoryKey            = "inventory.json"
+	annotationCollectedAt   = "colony.konstruct.io/inventory-collected-at"
+	annotationCollectedFrom = "colony.konstruct.io/inventory-collected-from"
+)
+
+// ErrInventoryNotFound is returned for machines whose inventory was never
+// collected.
+var ErrInventoryNotFound = errors.New("no inventory collected")
+
+// Inventory is the hardware inventory read from the BMC of a machine.
+type Inventory struct {
+	Machine     string         `json:"machine"`
+	HardwareID  string         `json:"hardwareID,omitempty"`
+	BoardSerial string         `json:"boardSerial,omitempty"`
+	BMCIP       string         `json:"bmcIP,omitempty"`
+	CollectedAt time.Time      `json:"collectedAt"`
+	Device      *common.Device `json:"device"`
+}
+
+// SaveInventory stores the inventory of the machine, replacing the one
+// collected before.
+func (c *Client) SaveInventory(ctx context.Context, machine, bmcIP string, device *common.Device) error {
+	content, err := json.Marshal(device)
+	if err != nil {
+		return fmt.Errorf("error encoding inventory: %w", err)
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      machine + inventorySuffix,
+			Namespace: constants.ColonyNamespace,
+			Labels: map[string]string{
+				labelName:        machine,
+				labelType:        typeBMCInventory,
+				labelBoardSerial: device.Serial,
+			},
+			Annotations: map[string]string{
+				annotationCollectedAt:   time.Now().UTC().Format(time.RFC3339),
+				annotationCollectedFrom: bmcIP,
+			},
+		},
+		Data: map[string]string{inventoryKey: string(content)},
+	}
+
+	// the inventory goes away with its machine
+	obj, err := c.GetObject(ctx, machineGVR, constants.ColonyNamespace, machine)
+	if err != nil {
+		return err
+	}
+	if obj != nil {
+		cm.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Name:       obj.GetName(),
+			UID:        obj.GetUID(),
+		}}
+	}
+
+	configMaps := c.clientSet.CoreV1().ConfigMaps(constants.ColonyNamespace)
+
+	if _, err := configMaps.Create(ctx, cm, metav1.CreateOptions{}); err == nil {
+		return nil
+	} else if !k8serrors.IsAlreadyExists(err) {
+		return fmt.Errorf("error creating inventory configmap: %w", err)
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing, err := configMaps.Get(ctx, cm.Name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("error getting inventory configmap: %w", err)
+		}
+
+		existing.Labels = cm.Labels
+		existing.Annotations = cm.Annotations
+		existing.OwnerReferences = cm.OwnerReferences
+		existing.Data = cm.Data
+
+		_, err = configMaps.Update(ctx, existing, metav1.UpdateOptions{})
+		return err //nolint:wrapcheck // wrapped once the retry loop is done
+	})
+	if err != nil {
+		return fmt.Errorf("error updating inventory configmap: %w", err)
+	}
+
+	return nil
+}
+
+// GetInventory returns the inventory of the machine, along with the id
+// of its hardware once discovered.
+func (c *Client) GetInventory(ctx context.Context, machine string) (*Inventory, error) {
+	cm, err := c.clientSet.CoreV1().ConfigMaps(constants.ColonyNamespace).Get(ctx, machine+inventorySuffix, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("%w for machine %q", ErrInventoryNotFound, machine)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting inventory configmap: %w", err)
+	}
+
+	inventory := &Inventory{
+		Machine:     machine,
+		BoardSerial: cm.Labels[labelBoardSerial],
+		BMCIP:       cm.Annotations[annotationCollectedFrom],
+		Device:      &common.Device{},
+	}
+
+	if at, err := time.Parse(time.RFC3339, cm.Annotations[annotationCollectedAt]); err == nil {
+		inventory.CollectedAt = at
+	}
+
+	if err := json.Unmarshal([]byte(cm.Data[inventoryKey]), inventory.Device); err != nil {
+		return nil, fmt.Errorf("error decoding inventory of machine %q: %w", machine, err)
+	}
+
+	secret, err := c.clientSet.CoreV1().Secrets(constants.ColonyNamespace).Get(ctx, machine, metav1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("error getting ipmi auth secret: %w", err)
+	}
+	if err == nil {
+		inventory.HardwareID = secret.Labels[labelHardwareID]
+	}
+
+	return inventory, nil
+}
@@ -9,13 +9,15 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/konstructio/colony/internal/colony"
 	"github.com/konstructio/colony/internal/constants"
 	"github.com/konstructio/colony/internal/logger"
-	"github.com/konstructio/colony/internal/table"
 	"github.com/kubefirst/tink/api/v1alpha1"
 	rufiov1alpha1 "github.com/tinkerbell/rufio/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -31,6 +33,7 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"k8s.io/client-go/util/retry"
 )
 
@@ -70,6 +73,30 @@ func New(logger *logger.Logger, kubeConfig string) (*Client, error) {
 	}, nil
 }
 
+// CopyKubeconfig writes the current context of the kubeconfig at src to
+// dst, with every referenced certificate and key file inlined, so dst
+// keeps working on its own.
+func CopyKubeconfig(src, dst string) error {
+	config, err := clientcmd.LoadFromFile(src)
+	if err != nil {
+		return fmt.Errorf("error loading kubeconfig %q: %w", src, err)
+	}
+
+	if err := clientcmdapi.MinifyConfig(config); err != nil {
+		return fmt.Errorf("error reading current context of kubeconfig %q: %w", src, err)
+	}
+
+	if err := clientcmdapi.FlattenConfig(config); err != nil {
+		return fmt.Errorf("error inlining files of kubeconfig %q: %w", src, err)
+	}
+
+	if err := clientcmd.WriteToFile(*config, dst); err != nil {
+		return fmt.Errorf("error writing kubeconfig %q: %w", dst, err)
+	}
+
+	return nil
+}
+
 func (c *Client) LoadMappingsFromKubernetes() error {
 	discovery, err := discovery.NewDiscoveryClientForConfig(c.config)
 	if err != nil {
@@ -136,14 +163,116 @@ func (c *Client) CreateSecret(ctx context.Context, secret *corev1.Secret) error
 	return nil
 }
 
+// ApplySecret creates the secret, or replaces its data and labels and adds
+// its annotations if it already exists.
+func (c *Client) ApplySecret(ctx context.Context, secret *corev1.Secret) error {
+	secrets := c.clientSet.CoreV1().Secrets(secret.GetNamespace())
+
+	s, err := secrets.Create(ctx, secret, metav1.CreateOptions{})
+	if err == nil {
+		c.logger.Infof("created Secret %q in Namespace %q", s.Name, s.Namespace)
+		return nil
+	}
+
+	if !k8serrors.IsAlreadyExists(err) {
+		return fmt.Errorf("error creating secret: %w", err)
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing, err := secrets.Get(ctx, secret.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("error getting secret: %w", err)
+		}
+
+		existing.Labels = secret.Labels
+		for k, v := range secret.Annotations {
+			if existing.Annotations == nil {
+				existing.Annotations = make(map[string]string)
+			}
+			existing.Annotations[k] = v
+		}
+		existing.Data = secret.Data
+		existing.StringData = secret.StringData
+
+		_, err = secrets.Update(ctx, existing, metav1.UpdateOptions{})
+		return err //nolint:wrapcheck // wrapped once the retry loop is done
+	})
+	if err != nil {
+		return fmt.Errorf("error updating secret: %w", err)
+	}
+
+	c.logger.Infof("updated Secret %q in Namespace %q", secret.GetName(), secret.GetNamespace())
+
+	return nil
+}
+
+// GetSecret returns the secret, a missing secret is reported with an
+// error matching k8serrors.IsNotFound.
+func (c *Client) GetSecret(ctx context.Context, namespace, name string) (*corev1.Secret, error) {
+	secret, err := c.clientSet.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting secret %q in namespace %q: %w", name, namespace, err)
+	}
+	return secret, nil
+}
+
+// DeleteSecret deletes the secret, a missing secret is not an error.
+func (c *Client) DeleteSecret(ctx context.Context, namespace, name string) error {
+	err := c.clientSet.CoreV1().Secrets(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("error deleting secret %q in namespace %q: %w", name, namespace, err)
+	}
+	return nil
+}
+
+// AddClusterRoleRule appends the rule to the ClusterRole unless an
+// identical rule is already present.
+func (c *Client) AddClusterRoleRule(ctx context.Context, clusterRoleName string, rule rbacv1.PolicyRule) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		role, err := c.clientSet.RbacV1().ClusterRoles().Get(ctx, clusterRoleName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("error getting ClusterRole: %w", err)
+		}
+
+		for _, existing := range role.Rules {
+			if equality.Semantic.DeepEqual(existing, rule) {
+				c.logger.Infof("ClusterRole %s already has the rule, skipping", clusterRoleName)
+				return nil
+			}
+		}
+
+		role.Rules = append(role.Rules, rule)
+		if _, err := c.clientSet.RbacV1().ClusterRoles().Update(ctx, role, metav1.UpdateOptions{}); err != nil {
+			return err //nolint:wrapcheck // wrapped once the retry loop is done
+		}
+
+		c.logger.Infof("successfully patched ClusterRole %s", clusterRoleName)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error updating ClusterRole: %w", err)
+	}
+
+	return nil
+}
+
 type AgentConfig struct {
 	AgentID string
 	APIKey  string
 	APIURL  string
+	TLS     colony.TLS
 }
 
 var requiredKeys = []string{"api-key", "api-url", "agent-id"}
 
+// Optional keys of the colony-api secret holding its TLS settings.
+const (
+	SecretKeyCA       = "ca.crt"
+	SecretKeyTLSCert  = "tls.crt"
+	SecretKeyTLSKey   = "tls.key"
+	SecretKeyInsecure = "insecure-skip-verify"
+)
+
 func (c *Client) GetAgentConfig(ctx context.Context) (*AgentConfig, error) {
 	secret, err := c.clientSet.CoreV1().Secrets(constants.ColonyNamespace).Get(ctx, constants.ColonyAPISecretName, metav1.GetOptions{})
 	if err != nil {
@@ -163,6 +292,12 @@ func (c *Client) GetAgentConfig(ctx context.Context) (*AgentConfig, error) {
 		AgentID: config["agent-id"],
 		APIKey:  config["api-key"],
 		APIURL:  config["api-url"],
+		TLS: colony.TLS{
+			CA:         secret.Data[SecretKeyCA],
+			ClientCert: secret.Data[SecretKeyTLSCert],
+			ClientKey:  secret.Data[SecretKeyTLSKey],
+			Insecure:   string(secret.Data[SecretKeyInsecure]) == "true",
+		},
 	}, nil
 }
 
@@ -183,7 +318,10 @@ func (c *Client) GetHardwareMachineRefFromSecretLabel(ctx context.Context, names
 		return "", fmt.Errorf("error finding secret: %w", err)
 	}
 
-	c.logger.Infof("looking for secret for hardware %q in namespace %q", strings.Split(opts.LabelSelector, "=")[1], namespace)
+	hardwareID := strings.Split(opts.LabelSelector, "=")[1]
+	log := c.logger.WithField(logger.FieldHardwareID, hardwareID)
+
+	log.Infof("looking for secret for hardware %q in namespace %q", hardwareID, namespace)
 
 	if len(s.Items) == 0 {
 		return "", errors.New("no secrets found")
@@ -193,7 +331,7 @@ func (c *Client) GetHardwareMachineRefFromSecretLabel(ctx context.Context, names
 		return "", errors.New("no secrets found")
 	}
 
-	c.logger.Infof("found machine ref: %s", s.Items[0].Labels["colony.konstruct.io/name"])
+	log.Infof("found machine ref: %s", s.Items[0].Labels["colony.konstruct.io/name"])
 
 	return s.Items[0].Labels["colony.konstruct.io/name"], nil
 }
@@ -220,7 +358,20 @@ func (c *Client) SecretAddLabel(ctx context.Context, name, namespace, labelName,
 	return nil
 }
 
+// ApplyManifests creates the resources in the manifests, updating the ones
+// that already exist.
 func (c *Client) ApplyManifests(ctx context.Context, manifests []string) error {
+	return c.applyManifests(ctx, manifests, true)
+}
+
+// CreateManifests creates the resources in the manifests, leaving the ones
+// that already exist untouched. Use it for resources with immutable specs,
+// like Jobs, that cannot be updated in place.
+func (c *Client) CreateManifests(ctx context.Context, manifests []string) error {
+	return c.applyManifests(ctx, manifests, false)
+}
+
+func (c *Client) applyManifests(ctx context.Context, manifests []string, updateExisting bool) error {
 	decoderUnstructured := yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme)
 
 	for _, manifest := range manifests {
@@ -244,30 +395,71 @@ func (c *Client) ApplyManifests(ctx context.Context, manifests []string) error {
 
 		// Create the resource
 		_, err = c.dynamic.Resource(gvr).Namespace(obj.GetNamespace()).Create(ctx, &obj, metav1.CreateOptions{})
+		if err == nil {
+			continue
+		}
+
+		if !k8serrors.IsAlreadyExists(err) {
+			return fmt.Errorf("error creating resource: %w", err)
+		}
+
+		if !updateExisting {
+			c.logger.Infof("%s %q already exists, skipping", gvk.Kind, obj.GetName())
+			continue
+		}
+
+		retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			existingObj, getErr := c.dynamic.Resource(gvr).Namespace(obj.GetNamespace()).Get(ctx, obj.GetName(), metav1.GetOptions{})
+			if getErr != nil {
+				return fmt.Errorf("error getting existing resource: %w", getErr)
+			}
+
+			obj.SetResourceVersion(existingObj.GetResourceVersion())
+			_, err := c.dynamic.Resource(gvr).Namespace(obj.GetNamespace()).Update(ctx, &obj, metav1.UpdateOptions{})
+			if err != nil {
+				return fmt.Errorf("error updating resource: %w", err)
+			}
+
+			return nil
+		})
+		if retryErr != nil {
+			return fmt.Errorf("error updating resource: %w", retryErr)
+		}
+	}
+
+	return nil
+}
+
+// DeleteManifests deletes the resources in the manifests. Resources that
+// do not exist, or whose kind is no longer served, are skipped.
+func (c *Client) DeleteManifests(ctx context.Context, manifests []string) error {
+	decoderUnstructured := yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme)
+	propagation := metav1.DeletePropagationBackground
+
+	for _, manifest := range manifests {
+		var obj unstructured.Unstructured
+		_, gvk, err := decoderUnstructured.Decode([]byte(manifest), nil, &obj)
 		if err != nil {
-			if k8serrors.IsAlreadyExists(err) {
-				retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-					existingObj, getErr := c.dynamic.Resource(gvr).Namespace(obj.GetNamespace()).Get(ctx, obj.GetName(), metav1.GetOptions{})
-					if getErr != nil {
-						return fmt.Errorf("error getting existing resource: %w", getErr)
-					}
-
-					obj.SetResourceVersion(existingObj.GetResourceVersion())
-					_, err := c.dynamic.Resource(gvr).Namespace(obj.GetNamespace()).Update(ctx, &obj, metav1.UpdateOptions{})
-					if err != nil {
-						return fmt.Errorf("error updating resource: %w", err)
-					}
-
-					return nil
-				})
-
-				if retryErr != nil {
-					return fmt.Errorf("error updating resource: %w", retryErr)
-				}
+			return fmt.Errorf("error decoding manifest: %w", err)
+		}
+
+		mapping, err := c.restmapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			if meta.IsNoMatchError(err) {
+				c.logger.Infof("%s is not served by the cluster, skipping %q", gvk.Kind, obj.GetName())
+				continue
 			}
+			return fmt.Errorf("unable to map manifest to a Kubernetes resource: %w", err)
+		}
 
-			return fmt.Errorf("error creating resource: %w", err)
+		err = c.dynamic.Resource(mapping.Resource).Namespace(obj.GetNamespace()).Delete(ctx, obj.GetName(), metav1.DeleteOptions{
+			PropagationPolicy: &propagation,
+		})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("error deleting %s %q: %w", gvk.Kind, obj.GetName(), err)
 		}
+
+		c.logger.Infof("deleted %s %q", gvk.Kind, obj.GetName())
 	}
 
 	return nil
@@ -338,7 +530,7 @@ func (c *Client) waitForDeploymentReady(ctx context.Context, deployment *appsv1.
 		if err != nil {
 			// If we couldn't connect, retry
 			if isNetworkingError(err) {
-				c.logger.Warn("connection error, retrying: %s", err)
+				c.logger.Warnf("connection error, retrying: %s", err)
 				return false, nil
 			}
 
@@ -501,7 +693,7 @@ func (c *Client) waitForMachineReady(ctx context.Context, gvr schema.GroupVersio
 		if err != nil {
 			// If we couldn't connect, retry
 			if isNetworkingError(err) {
-				c.logger.Warn("connection error, retrying: %s", err)
+				c.logger.Warnf("connection error, retrying: %s", err)
 				return false, nil
 			}
 
@@ -551,14 +743,15 @@ func (c *Client) FetchAndWaitForMachines(ctx context.Context, machine MachineDet
 		return fmt.Errorf("error finding machine %q: %w", machine.Name, err)
 	}
 
-	c.logger.Infof("machine %q found in namespace %q", machine.Name, machine.Namespace)
+	log := c.logger.WithField(logger.FieldBMCIP, m.Spec.Connection.Host)
+	log.Infof("machine %q found in namespace %q", machine.Name, machine.Namespace)
 
 	_, err = c.waitForMachineReady(ctx, gvr, m, machine.WaitTimeout)
 	if err != nil {
 		return fmt.Errorf("error waiting for machine %q: %w", machine.Name, err)
 	}
 
-	c.logger.Infof("machine %q in namespace %q is ready", machine.Name, machine.Namespace)
+	log.Infof("machine %q in namespace %q is ready", machine.Name, machine.Namespace)
 
 	return nil
 }
@@ -581,7 +774,8 @@ type WorkflowWaitRequest struct {
 //
 //nolint:dupl
 func (c *Client) FetchAndWaitForRufioJobs(ctx context.Context, job RufioJobWaitRequest) error {
-	c.logger.Infof("waiting for job %q in namespace %q", job.RandomSuffix, job.Namespace)
+	log := c.logger.WithField(logger.FieldJob, job.RandomSuffix)
+	log.Infof("waiting for job %q in namespace %q", job.RandomSuffix, job.Namespace)
 
 	gvr := schema.GroupVersionResource{
 		Group:    rufiov1alpha1.GroupVersion.Group,
@@ -590,27 +784,28 @@ func (c *Client) FetchAndWaitForRufioJobs(ctx context.Context, job RufioJobWaitR
 	}
 
 	j, err := c.returnRufioJobObject(ctx, gvr, job.Namespace, job.WaitTimeout, metav1.ListOptions{
-		LabelSelector: fmt.Sprintf("colony.konstruct.io/job-id=%s", job.RandomSuffix),
+		LabelSelector: fmt.Sprintf("%s=%s", labelJobID, job.RandomSuffix),
 	})
 	if err != nil {
 		return fmt.Errorf("error finding job %q: %w", job.LabelValue, err)
 	}
 
-	c.logger.Infof("job %q found in namespace %q", job.LabelValue, job.Namespace)
+	log.Infof("job %q found in namespace %q", job.LabelValue, job.Namespace)
 
 	_, err = c.waitForJobComplete(ctx, gvr, j, job.WaitTimeout)
 	if err != nil {
 		return fmt.Errorf("error waiting for job %q: %w", job.LabelValue, err)
 	}
 
-	c.logger.Infof("job %q in namespace %q is ready", job.LabelValue, job.Namespace)
+	log.Infof("job %q in namespace %q is ready", job.LabelValue, job.Namespace)
 
 	return nil
 }
 
 //nolint:dupl
 func (c *Client) FetchAndWaitForWorkflow(ctx context.Context, workflow WorkflowWaitRequest) error {
-	c.logger.Infof("waiting for workflow %q in namespace %q", workflow.RandomSuffix, workflow.Namespace)
+	log := c.logger.WithField(logger.FieldWorkflow, workflow.RandomSuffix)
+	log.Infof("waiting for workflow %q in namespace %q", workflow.RandomSuffix, workflow.Namespace)
 
 	gvr := schema.GroupVersionResource{
 		Group:    v1alpha1.GroupVersion.Group,
@@ -625,14 +820,14 @@ func (c *Client) FetchAndWaitForWorkflow(ctx context.Context, workflow WorkflowW
 		return fmt.Errorf("error finding job %q: %w", workflow.LabelValue, err)
 	}
 
-	c.logger.Infof("job %q found in namespace %q", workflow.LabelValue, workflow.Namespace)
+	log.Infof("job %q found in namespace %q", workflow.LabelValue, workflow.Namespace)
 
 	_, err = c.waitWorkflowComplete(ctx, gvr, w, workflow.WaitTimeout)
 	if err != nil {
 		return fmt.Errorf("error waiting for job %q: %w", workflow.LabelValue, err)
 	}
 
-	c.logger.Infof("job %q in namespace %q is ready", workflow.LabelValue, workflow.Namespace)
+	log.Infof("job %q in namespace %q is ready", workflow.LabelValue, workflow.Namespace)
 
 	return nil
 }
@@ -644,7 +839,8 @@ type UpdateHardwareRequest struct {
 }
 
 func (c *Client) HardwareRemoveIPXE(ctx context.Context, hardware UpdateHardwareRequest) (*v1alpha1.Hardware, error) {
-	c.logger.Infof("getting hardware %q in namespace %q", hardware.HardwareID, hardware.Namespace)
+	log := c.logger.WithField(logger.FieldHardwareID, hardware.HardwareID)
+	log.Infof("getting hardware %q in namespace %q", hardware.HardwareID, hardware.Namespace)
 
 	gvr := schema.GroupVersionResource{
 		Group:    v1alpha1.GroupVersion.Group,
@@ -664,7 +860,7 @@ func (c *Client) HardwareRemoveIPXE(ctx context.Context, hardware UpdateHardware
 		return nil, fmt.Errorf("error converting unstructured to hardware: %w", err)
 	}
 
-	c.logger.Infof("hardware %q found, removing ipxe script ", hw.GetName())
+	log.Infof("hardware %q found, removing ipxe script ", hw.GetName())
 
 	h.Spec.Interfaces[0].Netboot.IPXE = &v1alpha1.IPXE{}
 
@@ -678,7 +874,7 @@ func (c *Client) HardwareRemoveIPXE(ctx context.Context, hardware UpdateHardware
 		return nil, fmt.Errorf("error updating hardware %q: %w", hardware.HardwareID, err)
 	}
 
-	c.logger.Infof("removed ipxe script from hardware %q", obj.GetName())
+	log.Infof("removed ipxe script from hardware %q", obj.GetName())
 
 	err = runtime.DefaultUnstructuredConverter.FromUnstructured(hw.UnstructuredContent(), h)
 	if err != nil {
@@ -687,43 +883,3 @@ func (c *Client) HardwareRemoveIPXE(ctx context.Context, hardware UpdateHardware
 
 	return h, nil
 }
-
-func (c *Client) ListAssets(ctx context.Context) error {
-	// Set up columns for hardware table
-	columns := []table.Column{
-		{Name: "name", Align: "left"},
-		{Name: "hostname", Align: "left"},
-		{Name: "ip", Align: "left"},
-		{Name: "mac", Align: "left"},
-		{Name: "status", Align: "left"},
-	}
-
-	printer := table.NewTablePrinter(columns)
-	gvr := schema.GroupVersionResource{
-		Group:    v1alpha1.GroupVersion.Group,
-		Version:  v1alpha1.GroupVersion.Version,
-		Resource: "hardware",
-	}
-
-	hardwares, err := c.dynamic.Resource(gvr).Namespace("tink-system").List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return fmt.Errorf("error listing hardwares: %w", err)
-	}
-	if len(hardwares.Items) == 0 {
-		return errors.New("no hardware found")
-	}
-
-	// Convert hardware objects to rows
-	rows := make([]map[string]string, 0, len(hardwares.Items))
-	for i := range hardwares.Items {
-		h := &v1alpha1.Hardware{}
-		err = runtime.DefaultUnstructuredConverter.FromUnstructured(hardwares.Items[i].UnstructuredContent(), h)
-		if err != nil {
-			return fmt.Errorf("error converting unstructured to machine: %w", err)
-		}
-		rows = append(rows, table.HardwareToRow(h))
-	}
-
-	printer.PrintTable(rows)
-	return nil
-}
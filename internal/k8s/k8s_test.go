@@ -2,18 +2,29 @@ package k8s
 
 import (
 	"context"
+	stderrors "errors"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/bmc-toolbox/common"
 	"github.com/konstructio/colony/internal/constants"
 	"github.com/konstructio/colony/internal/logger"
+	"github.com/kubefirst/tink/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
 	dynamicfake "k8s.io/client-go/dynamic/fake"
 	fakeServer "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestClient_CreateAPIKeySecret(t *testing.T) {
@@ -59,6 +70,37 @@ func TestClient_CreateAPIKeySecret(t *testing.T) {
 	})
 }
 
+func TestClient_ApplySecret(t *testing.T) {
+	client := &Client{
+		clientSet: fakeServer.NewClientset(),
+		logger:    logger.NOOPLogger,
+	}
+
+	ctx := context.TODO()
+
+	for _, value := range []string{"first", "second"} {
+		err := client.ApplySecret(ctx, &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      constants.ColonyAPISecretName,
+				Namespace: constants.ColonyNamespace,
+			},
+			Data: map[string][]byte{"api-key": []byte(value)},
+		})
+		if err != nil {
+			t.Fatalf("not expecting an error but got: %s", err)
+		}
+	}
+
+	secret, err := client.clientSet.CoreV1().Secrets(constants.ColonyNamespace).Get(ctx, constants.ColonyAPISecretName, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("not expecting an error got %s", err)
+	}
+
+	if string(secret.Data["api-key"]) != "second" {
+		t.Fatalf("expected the secret to be updated but got %q", string(secret.Data["api-key"]))
+	}
+}
+
 func Test_ApplyManifests(t *testing.T) {
 	const rbacRoleYAML = `---
 apiVersion: networking.k8s.io/v1
@@ -116,6 +158,40 @@ spec:
 		}
 	})
 
+	t.Run("existing resource is updated", func(tt *testing.T) {
+		scheme := runtime.NewScheme()
+		if err := networkingv1.AddToScheme(scheme); err != nil {
+			tt.Fatalf("error adding networkingv1 to scheme: %s", err)
+		}
+
+		restMapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{networkingv1.SchemeGroupVersion})
+		restMapper.AddSpecific(
+			networkingv1.SchemeGroupVersion.WithKind("NetworkPolicy"),
+			networkingv1.SchemeGroupVersion.WithResource("networkpolicies"),
+			networkingv1.SchemeGroupVersion.WithResource("networkpolicies"),
+			meta.RESTScopeNamespace,
+		)
+
+		client := &Client{
+			dynamic:    dynamicfake.NewSimpleDynamicClient(scheme),
+			restmapper: restMapper,
+			logger:     logger.NOOPLogger,
+		}
+
+		ctx := context.TODO()
+
+		// applying twice must not fail on the existing resource
+		for i := 0; i < 2; i++ {
+			if err := client.ApplyManifests(ctx, []string{rbacRoleYAML}); err != nil {
+				tt.Fatalf("not expecting an error on apply %d but got: %s", i+1, err)
+			}
+		}
+
+		if err := client.CreateManifests(ctx, []string{rbacRoleYAML}); err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+	})
+
 	t.Run("unknown resource creation", func(tt *testing.T) {
 		// Create a fake dynamic client with an empty scheme set
 		scheme := runtime.NewScheme()
@@ -145,3 +221,588 @@ spec:
 		}
 	})
 }
+
+func TestClient_GetDeploymentStatus(t *testing.T) {
+	replicas := int32(2)
+	client := &Client{
+		clientSet: fakeServer.NewClientset(&appsv1.Deployment{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "hegel",
+				Namespace: constants.ColonyNamespace,
+				Labels:    map[string]string{"app": "hegel"},
+			},
+			Spec:   appsv1.DeploymentSpec{Replicas: &replicas},
+			Status: appsv1.DeploymentStatus{ReadyReplicas: 1},
+		}),
+		logger: logger.NOOPLogger,
+	}
+
+	t.Run("existing deployment", func(tt *testing.T) {
+		status, err := client.GetDeploymentStatus(context.TODO(), DeploymentDetails{Label: "app", Value: "hegel", Namespace: constants.ColonyNamespace})
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if status.Ready != 1 || status.Desired != 2 {
+			tt.Fatalf("expected 1/2 replicas ready but got %d/%d", status.Ready, status.Desired)
+		}
+	})
+
+	t.Run("missing deployment", func(tt *testing.T) {
+		_, err := client.GetDeploymentStatus(context.TODO(), DeploymentDetails{Label: "app", Value: "smee", Namespace: constants.ColonyNamespace})
+		if !stderrors.Is(err, ErrDeploymentNotFound) {
+			tt.Fatalf("expected %q but got: %v", ErrDeploymentNotFound, err)
+		}
+	})
+}
+
+func TestClient_ListAssets(t *testing.T) {
+	hardware := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "tinkerbell.org/v1alpha1",
+		"kind":       "Hardware",
+		"metadata":   map[string]interface{}{"name": "hw-1", "namespace": constants.ColonyNamespace},
+		"spec": map[string]interface{}{
+			"interfaces": []interface{}{
+				map[string]interface{}{"dhcp": map[string]interface{}{
+					"mac": "00:11:22:33:44:55",
+					"ip":  map[string]interface{}{"address": "10.0.1.10"},
+				}},
+			},
+		},
+	}}
+
+	machine := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "bmc.tinkerbell.org/v1alpha1",
+		"kind":       "Machine",
+		"metadata": map[string]interface{}{
+			"name":      "10-0-0-1",
+			"namespace": constants.ColonyNamespace,
+			"labels":    map[string]interface{}{"colony.konstruct.io/board-serial": "SN123"},
+		},
+		"spec":   map[string]interface{}{"connection": map[string]interface{}{"host": "10.0.0.1"}},
+		"status": map[string]interface{}{"powerState": "on"},
+	}}
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		hardwareGVR: "HardwareList",
+		machineGVR:  "MachineList",
+	}, machine)
+
+	// the fake guesses "hardwares" as the resource of the Hardware kind
+	if err := dynamicClient.Tracker().Create(hardwareGVR, hardware, constants.ColonyNamespace); err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	client := &Client{
+		clientSet: fakeServer.NewClientset(&corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "10-0-0-1",
+				Namespace: constants.ColonyNamespace,
+				Labels: map[string]string{
+					"colony.konstruct.io/name":        "10-0-0-1",
+					"colony.konstruct.io/hardware-id": "hw-1",
+				},
+			},
+		}),
+		dynamic: dynamicClient,
+		logger:  logger.NOOPLogger,
+	}
+
+	assets, err := client.ListAssets(context.TODO())
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	if len(assets) != 1 {
+		t.Fatalf("expected 1 asset but got %d", len(assets))
+	}
+
+	asset := assets[0]
+	if asset.MAC != "00:11:22:33:44:55" || asset.IP != "10.0.1.10" || asset.BMCIP != "10.0.0.1" || asset.BoardSerial != "SN123" || asset.PowerState != "on" {
+		t.Fatalf("unexpected asset %+v", asset)
+	}
+}
+
+func TestClient_BMCCredentials(t *testing.T) {
+	machine := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "bmc.tinkerbell.org/v1alpha1",
+		"kind":       "Machine",
+		"metadata":   map[string]interface{}{"name": "10-0-0-1", "namespace": constants.ColonyNamespace},
+		"spec":       map[string]interface{}{"connection": map[string]interface{}{"host": "10.0.0.1"}},
+	}}
+
+	client := &Client{
+		clientSet: fakeServer.NewClientset(&corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "10-0-0-1",
+				Namespace: constants.ColonyNamespace,
+				Labels: map[string]string{
+					"colony.konstruct.io/type":        "ipmi-auth",
+					"colony.konstruct.io/hardware-id": "hw-1",
+				},
+			},
+			Data: map[string][]byte{"username": []byte("admin"), "password": []byte("old")},
+		}),
+		dynamic: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+			machineGVR: "MachineList",
+		}, machine),
+		logger: logger.NOOPLogger,
+	}
+
+	ctx := context.TODO()
+
+	creds, err := client.ListBMCCredentials(ctx, "colony.konstruct.io/hardware-id=hw-1")
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	if len(creds) != 1 || creds[0].Host != "10.0.0.1" || creds[0].Username != "admin" || creds[0].Password != "old" {
+		t.Fatalf("unexpected credentials %+v", creds)
+	}
+
+	if err := client.UpdateBMCPassword(ctx, creds[0], "new", "ops@host"); err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	updated, err := client.ListBMCCredentials(ctx, "")
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	if len(updated) != 1 || updated[0].Password != "new" || updated[0].UpdatedBy != "ops@host" || updated[0].UpdatedAt.IsZero() {
+		t.Fatalf("expected the password to be updated but got %+v", updated)
+	}
+
+	if none, err := client.ListBMCCredentials(ctx, "colony.konstruct.io/hardware-id=hw-2"); err != nil || len(none) != 0 {
+		t.Fatalf("expected no credentials for another hardware but got %+v, %v", none, err)
+	}
+
+	name, err := client.SaveBMCRecoveryPassword(ctx, updated[0], "lost", "ops@host")
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	recovery, err := client.clientSet.CoreV1().Secrets(constants.ColonyNamespace).Get(ctx, name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	if name != "10-0-0-1-recovery" || string(recovery.Data["password"]) != "lost" || string(recovery.Data["username"]) != "admin" {
+		t.Fatalf("unexpected recovery secret %q: %+v", name, recovery)
+	}
+
+	// the recovery secret is not an ipmi auth secret
+	if all, err := client.ListBMCCredentials(ctx, ""); err != nil || len(all) != 1 {
+		t.Fatalf("expected only the ipmi auth credentials but got %+v, %v", all, err)
+	}
+}
+
+func TestClient_Power(t *testing.T) {
+	machine := func(name, serial, power string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "bmc.tinkerbell.org/v1alpha1",
+			"kind":       "Machine",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": constants.ColonyNamespace,
+				"labels":    map[string]interface{}{"colony.konstruct.io/board-serial": serial},
+			},
+			"spec":   map[string]interface{}{"connection": map[string]interface{}{"host": strings.ReplaceAll(name, "-", ".")}},
+			"status": map[string]interface{}{"powerState": power},
+		}}
+	}
+
+	client := &Client{
+		clientSet: fakeServer.NewClientset(&corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "10-0-0-1",
+				Namespace: constants.ColonyNamespace,
+				Labels:    map[string]string{"colony.konstruct.io/hardware-id": "hw-1"},
+			},
+		}),
+		dynamic: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+			machineGVR:  "MachineList",
+			rufioJobGVR: "JobList",
+		}, machine("10-0-0-1", "SN1", "on"), machine("10-0-0-2", "SN2", "off")),
+		logger: logger.NOOPLogger,
+	}
+
+	ctx := context.TODO()
+
+	t.Run("list by hardware id", func(tt *testing.T) {
+		targets, err := client.ListPowerTargets(ctx, "hw-1", "")
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if len(targets) != 1 || targets[0].Machine != "10-0-0-1" || targets[0].Host != "10.0.0.1" || targets[0].PowerState != "on" {
+			tt.Fatalf("unexpected targets %+v", targets)
+		}
+	})
+
+	t.Run("list by selector", func(tt *testing.T) {
+		targets, err := client.ListPowerTargets(ctx, "", "colony.konstruct.io/board-serial=SN2")
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if len(targets) != 1 || targets[0].Machine != "10-0-0-2" || targets[0].HardwareID != "" || targets[0].PowerState != "off" {
+			tt.Fatalf("unexpected targets %+v", targets)
+		}
+	})
+
+	t.Run("create job", func(tt *testing.T) {
+		tasks, err := PowerTasks("soft-off")
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if err := client.CreateRufioJob(ctx, "10-0-0-1", "soft-off", "abc123", tasks); err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		jobs, err := client.ListObjects(ctx, rufioJobGVR, constants.ColonyNamespace, v1.ListOptions{LabelSelector: "colony.konstruct.io/job-id=abc123"})
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if len(jobs) != 1 {
+			tt.Fatalf("expected 1 job but got %d", len(jobs))
+		}
+
+		action, _, _ := unstructured.NestedSlice(jobs[0].Object, "spec", "tasks")
+		if len(action) != 1 || action[0].(map[string]interface{})["powerAction"] != "soft" {
+			tt.Fatalf("unexpected tasks %v", action)
+		}
+	})
+
+	t.Run("reboot tasks", func(tt *testing.T) {
+		tasks := RebootTasks("pxe", true)
+
+		if len(tasks) != 3 || *tasks[0].PowerAction != "off" || tasks[1].OneTimeBootDeviceAction.Devices[0] != "pxe" || *tasks[2].PowerAction != "on" {
+			tt.Fatalf("unexpected tasks %+v", tasks)
+		}
+	})
+
+	t.Run("unsupported action", func(tt *testing.T) {
+		if _, err := PowerTasks("hibernate"); err == nil {
+			tt.Fatal("expected an error but got nil")
+		}
+	})
+
+	job := func(name, jobID string, conditions ...interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "bmc.tinkerbell.org/v1alpha1",
+			"kind":       "Job",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": constants.ColonyNamespace,
+				"labels":    map[string]interface{}{"colony.konstruct.io/job-id": jobID},
+			},
+			"status": map[string]interface{}{"conditions": conditions},
+		}}
+	}
+
+	t.Run("wait for completed job", func(tt *testing.T) {
+		completed := job("10-0-0-1-on-done", "done",
+			map[string]interface{}{"type": "Running", "status": "True"},
+			map[string]interface{}{"type": "Completed", "status": "True"},
+		)
+		if _, err := client.dynamic.Resource(rufioJobGVR).Namespace(constants.ColonyNamespace).Create(ctx, completed, v1.CreateOptions{}); err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		err := client.FetchAndWaitForRufioJobs(ctx, RufioJobWaitRequest{LabelValue: completed.GetName(), Namespace: constants.ColonyNamespace, WaitTimeout: 10, RandomSuffix: "done"})
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+	})
+
+	t.Run("wait for failed job", func(tt *testing.T) {
+		failed := job("10-0-0-1-on-failed", "failed",
+			map[string]interface{}{"type": "Running", "status": "True"},
+			map[string]interface{}{"type": "Failed", "status": "True", "message": "bmc refused the power action"},
+		)
+		if _, err := client.dynamic.Resource(rufioJobGVR).Namespace(constants.ColonyNamespace).Create(ctx, failed, v1.CreateOptions{}); err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		start := time.Now()
+		err := client.FetchAndWaitForRufioJobs(ctx, RufioJobWaitRequest{LabelValue: failed.GetName(), Namespace: constants.ColonyNamespace, WaitTimeout: 30, RandomSuffix: "failed"})
+		if !stderrors.Is(err, ErrJobFailed) {
+			tt.Fatalf("expected ErrJobFailed but got: %v", err)
+		}
+
+		if !strings.Contains(err.Error(), "bmc refused the power action") {
+			tt.Fatalf("expected the job message in the error but got: %s", err)
+		}
+
+		if elapsed := time.Since(start); elapsed > 10*time.Second {
+			tt.Fatalf("expected the failure right away but waited %s", elapsed)
+		}
+	})
+}
+
+func TestClient_ListBMCs(t *testing.T) {
+	downSince := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	machine := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "bmc.tinkerbell.org/v1alpha1",
+		"kind":       "Machine",
+		"metadata": map[string]interface{}{
+			"name":      "10-0-0-1",
+			"namespace": constants.ColonyNamespace,
+			"labels":    map[string]interface{}{"colony.konstruct.io/board-serial": "SN1"},
+		},
+		"spec": map[string]interface{}{"connection": map[string]interface{}{"host": "10.0.0.1"}},
+		"status": map[string]interface{}{
+			"powerState": "unknown",
+			"conditions": []interface{}{
+				map[string]interface{}{
+					"type":           "Contactable",
+					"status":         "False",
+					"lastUpdateTime": downSince.Format(time.RFC3339),
+					"message":        "connection refused",
+				},
+			},
+		},
+	}}
+
+	hardware := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "tinkerbell.org/v1alpha1",
+		"kind":       "Hardware",
+		"metadata":   map[string]interface{}{"name": "hw-1", "namespace": constants.ColonyNamespace},
+		"spec":       map[string]interface{}{"bmcRef": map[string]interface{}{"kind": "Machine", "name": "10-0-0-1"}},
+	}}
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		hardwareGVR: "HardwareList",
+		machineGVR:  "MachineList",
+	}, machine)
+
+	if err := dynamicClient.Tracker().Create(hardwareGVR, hardware, constants.ColonyNamespace); err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	client := &Client{
+		clientSet: fakeServer.NewClientset(&corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "10-0-0-2",
+				Namespace: constants.ColonyNamespace,
+				// secrets created before the ip label carry the ip in their name
+				Labels: map[string]string{
+					"colony.konstruct.io/type":         "ipmi-auth",
+					"colony.konstruct.io/board-serial": "SN2",
+				},
+			},
+		}),
+		dynamic: dynamicClient,
+		logger:  logger.NOOPLogger,
+	}
+
+	bmcs, err := client.ListBMCs(context.TODO())
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	if len(bmcs) != 2 {
+		t.Fatalf("expected 2 bmcs but got %d", len(bmcs))
+	}
+
+	down := bmcs[0]
+	if down.IP != "10.0.0.1" || down.HardwareID != "hw-1" || down.BoardSerial != "SN1" || down.Contactable == nil || *down.Contactable || !down.ContactableSince.Equal(downSince) || down.Message != "connection refused" {
+		t.Fatalf("unexpected bmc %+v", down)
+	}
+
+	pending := bmcs[1]
+	if pending.IP != "10.0.0.2" || pending.BoardSerial != "SN2" || pending.Contactable != nil || !pending.ContactableSince.IsZero() {
+		t.Fatalf("unexpected bmc %+v", pending)
+	}
+}
+
+func TestClient_Inventory(t *testing.T) {
+	machine := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "bmc.tinkerbell.org/v1alpha1",
+		"kind":       "Machine",
+		"metadata":   map[string]interface{}{"name": "10-0-0-1", "namespace": constants.ColonyNamespace, "uid": "machine-uid"},
+		"spec":       map[string]interface{}{"connection": map[string]interface{}{"host": "10.0.0.1"}},
+	}}
+
+	clientSet := fakeServer.NewClientset(&corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "10-0-0-1",
+			Namespace: constants.ColonyNamespace,
+			Labels:    map[string]string{"colony.konstruct.io/hardware-id": "hw-1"},
+		},
+	})
+
+	client := &Client{
+		clientSet: clientSet,
+		dynamic: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+			machineGVR: "MachineList",
+		}, machine),
+		logger: logger.NOOPLogger,
+	}
+
+	ctx := context.TODO()
+
+	if _, err := client.GetInventory(ctx, "10-0-0-1"); !stderrors.Is(err, ErrInventoryNotFound) {
+		t.Fatalf("expected ErrInventoryNotFound but got %v", err)
+	}
+
+	for _, cores := range []int{16, 32} {
+		device := &common.Device{
+			Common: common.Common{Serial: "SN1"},
+			CPUs:   []*common.CPU{{Cores: cores}},
+		}
+		if err := client.SaveInventory(ctx, "10-0-0-1", "10.0.0.1", device); err != nil {
+			t.Fatalf("not expecting an error but got: %s", err)
+		}
+	}
+
+	inventory, err := client.GetInventory(ctx, "10-0-0-1")
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	if inventory.HardwareID != "hw-1" || inventory.BoardSerial != "SN1" || inventory.BMCIP != "10.0.0.1" || inventory.CollectedAt.IsZero() {
+		t.Fatalf("unexpected inventory %+v", inventory)
+	}
+
+	if len(inventory.Device.CPUs) != 1 || inventory.Device.CPUs[0].Cores != 32 {
+		t.Fatalf("expected the refreshed inventory but got %+v", inventory.Device.CPUs)
+	}
+
+	cm, err := clientSet.CoreV1().ConfigMaps(constants.ColonyNamespace).Get(ctx, "10-0-0-1-inventory", v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	if len(cm.OwnerReferences) != 1 || cm.OwnerReferences[0].UID != "machine-uid" {
+		t.Fatalf("expected the inventory to be owned by the machine but got %+v", cm.OwnerReferences)
+	}
+}
+
+func TestHardwareMatch(t *testing.T) {
+	match := HardwareMatch{BMCIP: "10.0.0.1", MACs: []string{"AA:BB:CC:DD:EE:01"}}
+
+	tests := []struct {
+		name     string
+		hardware *v1alpha1.Hardware
+		want     bool
+	}{
+		{
+			name:     "bmc reference",
+			hardware: &v1alpha1.Hardware{Spec: v1alpha1.HardwareSpec{BMCRef: &corev1.TypedLocalObjectReference{Kind: "Machine", Name: "10-0-0-1"}}},
+			want:     true,
+		},
+		{
+			name:     "reference to another bmc",
+			hardware: &v1alpha1.Hardware{Spec: v1alpha1.HardwareSpec{BMCRef: &corev1.TypedLocalObjectReference{Kind: "Machine", Name: "10-0-0-2"}}},
+			want:     false,
+		},
+		{
+			name:     "mac reported by the bmc",
+			hardware: &v1alpha1.Hardware{Spec: v1alpha1.HardwareSpec{Interfaces: []v1alpha1.Interface{{DHCP: &v1alpha1.DHCP{MAC: "aa:bb:cc:dd:ee:01"}}}}},
+			want:     true,
+		},
+		{
+			name:     "unknown mac",
+			hardware: &v1alpha1.Hardware{Spec: v1alpha1.HardwareSpec{Interfaces: []v1alpha1.Interface{{DHCP: &v1alpha1.DHCP{MAC: "aa:bb:cc:dd:ee:02"}}, {}}}},
+			want:     false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(tt *testing.T) {
+			if got := match.Matches(tc.hardware); got != tc.want {
+				tt.Fatalf("expected %t but got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestClient_HardwareInformer(t *testing.T) {
+	newHardware := func(name, bmcRef, mac string) *unstructured.Unstructured {
+		spec := map[string]interface{}{
+			"interfaces": []interface{}{
+				map[string]interface{}{"dhcp": map[string]interface{}{"mac": mac}},
+			},
+		}
+		if bmcRef != "" {
+			spec["bmcRef"] = map[string]interface{}{"kind": "Machine", "name": bmcRef}
+		}
+
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "tinkerbell.org/v1alpha1",
+			"kind":       "Hardware",
+			"metadata":   map[string]interface{}{"name": name, "namespace": constants.ColonyNamespace},
+			"spec":       spec,
+		}}
+	}
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		hardwareGVR: "HardwareList",
+	})
+
+	// hardware that exists before the informer starts is never picked
+	if err := dynamicClient.Tracker().Create(hardwareGVR, newHardware("hw-existing", "10-0-0-1", "aa:bb:cc:dd:ee:00"), constants.ColonyNamespace); err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	watching := make(chan struct{})
+	var once sync.Once
+	dynamicClient.PrependWatchReactor("*", func(k8stesting.Action) (bool, watch.Interface, error) {
+		once.Do(func() { close(watching) })
+		return false, nil, nil
+	})
+
+	client := &Client{
+		clientSet: fakeServer.NewClientset(&corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{Name: "10-0-0-1", Namespace: constants.ColonyNamespace},
+		}),
+		dynamic: dynamicClient,
+		logger:  logger.NOOPLogger,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	hardwareChan := make(chan *v1alpha1.Hardware, 1)
+	go client.HardwareInformer(ctx, HardwareMatch{BMCIP: "10.0.0.1", MACs: []string{"aa:bb:cc:dd:ee:01"}}, hardwareChan)
+
+	select {
+	case <-watching:
+	case <-ctx.Done():
+		t.Fatalf("the informer never started watching")
+	}
+	// the tracker registers the watch once the reactors ran
+	time.Sleep(100 * time.Millisecond)
+
+	for _, hw := range []*unstructured.Unstructured{
+		newHardware("hw-other", "", "aa:bb:cc:dd:ee:02"),
+		newHardware("hw-new", "", "AA:BB:CC:DD:EE:01"),
+	} {
+		if err := dynamicClient.Tracker().Create(hardwareGVR, hw, constants.ColonyNamespace); err != nil {
+			t.Fatalf("not expecting an error but got: %s", err)
+		}
+	}
+
+	select {
+	case hw := <-hardwareChan:
+		if hw.Name != "hw-new" {
+			t.Fatalf("expected hardware %q but got %q", "hw-new", hw.Name)
+		}
+	case <-ctx.Done():
+		t.Fatalf("the hardware behind the bmc was never picked")
+	}
+
+	secret, err := client.clientSet.CoreV1().Secrets(constants.ColonyNamespace).Get(ctx, "10-0-0-1", v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	if secret.Labels[labelHardwareID] != "hw-new" {
+		t.Fatalf("expected the secret to be labelled with %q but got %q", "hw-new", secret.Labels[labelHardwareID])
+	}
+}
@@ -0,0 +1,33 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ListObjects lists the objects of a resource in the namespace.
+func (c *Client) ListObjects(ctx context.Context, gvr schema.GroupVersionResource, namespace string, opts metav1.ListOptions) ([]unstructured.Unstructured, error) {
+	list, err := c.dynamic.Resource(gvr).Namespace(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing %s in namespace %q: %w", gvr.Resource, namespace, err)
+	}
+	return list.Items, nil
+}
+
+// GetObject returns the named object of a resource, or nil if it does
+// not exist.
+func (c *Client) GetObject(ctx context.Context, gvr schema.GroupVersionResource, namespace, name string) (*unstructured.Unstructured, error) {
+	obj, err := c.dynamic.Resource(gvr).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil //nolint:nilnil // a missing object is not an error
+		}
+		return nil, fmt.Errorf("error getting %s %q in namespace %q: %w", gvr.Resource, name, namespace, err)
+	}
+	return obj, nil
+}
@@ -0,0 +1,160 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/konstructio/colony/internal/constants"
+	rufiov1alpha1 "github.com/tinkerbell/rufio/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// labelJobID is the label FetchAndWaitForRufioJobs finds a rufio job by.
+const labelJobID = "colony.konstruct.io/job-id"
+
+// PowerActions are the power actions a rufio job can run, by the name
+// colony gives them.
+var PowerActions = map[string]rufiov1alpha1.PowerAction{
+	"on":       rufiov1alpha1.PowerOn,
+	"off":      rufiov1alpha1.PowerHardOff,
+	"soft-off": rufiov1alpha1.PowerSoftOff,
+	"cycle":    rufiov1alpha1.PowerCycle,
+	"reset":    rufiov1alpha1.PowerReset,
+}
+
+// PowerTarget is a rufio machine a power action runs on.
+type PowerTarget struct {
+	Machine    string `json:"machine"`
+	HardwareID string `json:"hardwareID,omitempty"`
+	Host       string `json:"host"`
+	PowerState string `json:"powerState,omitempty"`
+}
+
+// PowerTasks returns the tasks of a rufio job running the power action.
+func PowerTasks(action string) ([]rufiov1alpha1.Action, error) {
+	powerAction, ok := PowerActions[action]
+	if !ok {
+		names := make([]string, 0, len(PowerActions))
+		for name := range PowerActions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unsupported power action %q, must be one of: %s", action, strings.Join(names, ", "))
+	}
+
+	return []rufiov1alpha1.Action{{PowerAction: powerAction.Ptr()}}, nil
+}
+
+// RebootTasks returns the tasks of a rufio job powering the machine off
+// and back on, booting once from the boot device.
+func RebootTasks(bootDevice string, efiBoot bool) []rufiov1alpha1.Action {
+	return []rufiov1alpha1.Action{
+		{PowerAction: rufiov1alpha1.PowerHardOff.Ptr()},
+		{OneTimeBootDeviceAction: &rufiov1alpha1.OneTimeBootDeviceAction{
+			Devices: []rufiov1alpha1.BootDevice{rufiov1alpha1.BootDevice(bootDevice)},
+			EFIBoot: efiBoot,
+		}},
+		{PowerAction: rufiov1alpha1.PowerOn.Ptr()},
+	}
+}
+
+// ListPowerTargets returns the rufio machines of the hardware, or matching
+// the label selector, along with their power state.
+func (c *Client) ListPowerTargets(ctx context.Context, hardwareID, selector string) ([]PowerTarget, error) {
+	secrets, err := c.clientSet.CoreV1().Secrets(constants.ColonyNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labelHardwareID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing ipmi auth secrets: %w", err)
+	}
+
+	// machine name to hardware id
+	hardwareIDs := make(map[string]string, len(secrets.Items))
+	for _, s := range secrets.Items {
+		hardwareIDs[s.Name] = s.Labels[labelHardwareID]
+	}
+
+	var objects []unstructured.Unstructured
+	if hardwareID != "" {
+		for name, id := range hardwareIDs {
+			if id != hardwareID {
+				continue
+			}
+			obj, err := c.GetObject(ctx, machineGVR, constants.ColonyNamespace, name)
+			if err != nil {
+				return nil, err
+			}
+			if obj != nil {
+				objects = append(objects, *obj)
+			}
+		}
+	} else {
+		objects, err = c.ListObjects(ctx, machineGVR, constants.ColonyNamespace, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	targets := make([]PowerTarget, 0, len(objects))
+	for i := range objects {
+		m := &rufiov1alpha1.Machine{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(objects[i].UnstructuredContent(), m); err != nil {
+			return nil, fmt.Errorf("error converting unstructured to machine: %w", err)
+		}
+
+		targets = append(targets, PowerTarget{
+			Machine:    m.Name,
+			HardwareID: hardwareIDs[m.Name],
+			Host:       m.Spec.Connection.Host,
+			PowerState: string(m.Status.Power),
+		})
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Machine < targets[j].Machine
+	})
+
+	return targets, nil
+}
+
+// CreateRufioJob creates a rufio job running the tasks on the machine. The
+// job is labelled with jobID so it can be waited for with
+// FetchAndWaitForRufioJobs.
+func (c *Client) CreateRufioJob(ctx context.Context, machine, name, jobID string, tasks []rufiov1alpha1.Action) error {
+	job := &rufiov1alpha1.Job{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: rufiov1alpha1.GroupVersion.String(),
+			Kind:       "Job",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s-%s", machine, name, jobID),
+			Namespace: constants.ColonyNamespace,
+			Labels: map[string]string{
+				labelName:  machine,
+				labelJobID: jobID,
+			},
+		},
+		Spec: rufiov1alpha1.JobSpec{
+			MachineRef: rufiov1alpha1.MachineRef{
+				Name:      machine,
+				Namespace: constants.ColonyNamespace,
+			},
+			Tasks: tasks,
+		},
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(job)
+	if err != nil {
+		return fmt.Errorf("error converting job to unstructured: %w", err)
+	}
+
+	if _, err := c.dynamic.Resource(rufioJobGVR).Namespace(constants.ColonyNamespace).Create(ctx, &unstructured.Unstructured{Object: content}, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("error creating rufio job %q: %w", job.Name, err)
+	}
+
+	return nil
+}
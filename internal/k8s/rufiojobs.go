@@ -3,10 +3,11 @@ package k8s
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"time"
 
+	"github.com/konstructio/colony/internal/logger"
 	rufiov1alpha1 "github.com/tinkerbell/rufio/api/v1alpha1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -14,6 +15,9 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
+// ErrJobFailed is returned when rufio reports a job as failed.
+var ErrJobFailed = errors.New("rufio job failed")
+
 //nolint:dupl
 func (c *Client) returnRufioJobObject(ctx context.Context, gvr schema.GroupVersionResource, namespace string, timeoutSeconds int, opts metav1.ListOptions) (*rufiov1alpha1.Job, error) {
 	job := &rufiov1alpha1.Job{}
@@ -57,7 +61,8 @@ func (c *Client) waitForJobComplete(ctx context.Context, gvr schema.GroupVersion
 
 	job := &rufiov1alpha1.Job{}
 
-	c.logger.Infof("waiting for job %q in namespace %q to be ready - this could take up to %d seconds", jobName, namespace, timeoutSeconds)
+	log := c.logger.WithField(logger.FieldJob, jobName)
+	log.Infof("waiting for job %q in namespace %q to be ready - this could take up to %d seconds", jobName, namespace, timeoutSeconds)
 
 	err := wait.PollUntilContextTimeout(ctx, 5*time.Second, time.Duration(timeoutSeconds)*time.Second, true, func(ctx context.Context) (bool, error) {
 		// Get the latest Machine object
@@ -65,7 +70,7 @@ func (c *Client) waitForJobComplete(ctx context.Context, gvr schema.GroupVersion
 		if err != nil {
 			// If we couldn't connect, retry
 			if isNetworkingError(err) {
-				c.logger.Warn("connection error, retrying: %s", err)
+				c.logger.Warnf("connection error, retrying: %s", err)
 				return false, nil
 			}
 
@@ -78,10 +83,10 @@ func (c *Client) waitForJobComplete(ctx context.Context, gvr schema.GroupVersion
 
 		jsonData, err := json.Marshal(job.Status)
 		if err != nil {
-			log.Printf("Error marshaling: %v", err)
+			log.Errorf("error marshaling job status: %v", err)
 		}
 
-		c.logger.Infof("%+v", string(jsonData))
+		log.Debugf("job status: %s", string(jsonData))
 
 		if len(job.Status.Conditions) == 0 {
 			return false, nil
@@ -92,9 +97,17 @@ func (c *Client) waitForJobComplete(ctx context.Context, gvr schema.GroupVersion
 			return true, nil
 		}
 
+		// a failed job never completes, stop waiting for it
+		if lastCondition.Status == "True" && lastCondition.Type == rufiov1alpha1.JobFailed {
+			return false, fmt.Errorf("%w: job %q in namespace %q: %s", ErrJobFailed, jobName, namespace, lastCondition.Message)
+		}
+
 		// Machine is not yet ready, continue polling
 		return false, nil
 	})
+	if errors.Is(err, ErrJobFailed) {
+		return false, err
+	}
 	if err != nil {
 		return false, fmt.Errorf("the job %q in namespace %q was not ready within the timeout period: %w", jobName, namespace, err)
 	}
@@ -4,9 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"time"
 
+	"github.com/konstructio/colony/internal/logger"
 	v1alpha1 "github.com/kubefirst/tink/api/v1alpha1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -57,7 +57,8 @@ func (c *Client) waitWorkflowComplete(ctx context.Context, gvr schema.GroupVersi
 
 	wf := &v1alpha1.Workflow{}
 
-	c.logger.Infof("waiting for workflow %q in namespace %q to be ready - this could take up to %d seconds", workflowName, namespace, timeoutSeconds)
+	log := c.logger.WithField(logger.FieldWorkflow, workflowName)
+	log.Infof("waiting for workflow %q in namespace %q to be ready - this could take up to %d seconds", workflowName, namespace, timeoutSeconds)
 
 	err := wait.PollUntilContextTimeout(ctx, 5*time.Second, time.Duration(timeoutSeconds)*time.Second, true, func(ctx context.Context) (bool, error) {
 		// Get the latest workflow object
@@ -65,7 +66,7 @@ func (c *Client) waitWorkflowComplete(ctx context.Context, gvr schema.GroupVersi
 		if err != nil {
 			// If we couldn't connect, retry
 			if isNetworkingError(err) {
-				c.logger.Warn("connection error, retrying: %s", err)
+				c.logger.Warnf("connection error, retrying: %s", err)
 				return false, nil
 			}
 
@@ -78,10 +79,10 @@ func (c *Client) waitWorkflowComplete(ctx context.Context, gvr schema.GroupVersi
 
 		jsonData, err := json.Marshal(wf.Status)
 		if err != nil {
-			log.Printf("Error marshaling: %v", err)
+			log.Errorf("error marshaling workflow status: %v", err)
 		}
 
-		c.logger.Infof("%+v", string(jsonData))
+		log.Debugf("workflow status: %s", string(jsonData))
 
 		if len(wf.Status.Tasks) == 0 {
 			return false, nil
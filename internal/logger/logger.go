@@ -1,6 +1,13 @@
 package logger
 
-import "github.com/sirupsen/logrus"
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
 
 // loggerer is an interface for logging.
 type loggerer interface {
@@ -26,11 +33,29 @@ func (l *noopLogger) Warnf(string, ...interface{})  {}
 func (l *noopLogger) Errorf(string, ...interface{}) {}
 func (l *noopLogger) Debugf(string, ...interface{}) {}
 
+// fielder is implemented by loggers able to attach structured fields,
+// both logrus.Logger and logrus.Entry.
+type fielder interface {
+	WithFields(fields logrus.Fields) *logrus.Entry
+}
+
 // Logger is a logger.
 type Logger struct {
 	internal loggerer
 }
 
+// Fields are structured fields attached to every message of a logger.
+type Fields map[string]interface{}
+
+// Well known field names, shared so log shippers can index them.
+const (
+	FieldHardwareID  = "hardware_id"
+	FieldBMCIP       = "bmc_ip"
+	FieldJob         = "job"
+	FieldWorkflow    = "workflow"
+	FieldOperationID = "operation_id"
+)
+
 // LogLevel represents the log level.
 type LogLevel string
 
@@ -42,39 +67,122 @@ const (
 	Error LogLevel = "error"
 )
 
+// Levels are the supported log levels.
+var Levels = []string{string(Debug), string(Info), string(Warn), string(Error)}
+
+// Format is the encoding of log messages.
+type Format string
+
+// Log formats.
+const (
+	Text Format = "text"
+	JSON Format = "json"
+)
+
+// Formats are the supported log formats.
+var Formats = []string{string(Text), string(JSON)}
+
+// Options configure a logger.
+type Options struct {
+	Level  LogLevel
+	Format Format
+	// Output defaults to stderr.
+	Output io.Writer
+}
+
 // _ is a compile-time check to ensure that logrus.Logger
-// fully implements loggerer.
-var _ loggerer = &logrus.Logger{}
+// and logrus.Entry fully implement loggerer.
+var (
+	_ loggerer = &logrus.Logger{}
+	_ loggerer = &logrus.Entry{}
+)
 
 // NOOPLogger is a logger that discards all log messages.
 var NOOPLogger = &Logger{
 	internal: &noopLogger{},
 }
 
-// New creates a new logger with the specified log level.
+// New creates a new text logger with the specified log level.
 func New(level LogLevel) *Logger {
+	l, err := NewWithOptions(Options{Level: level, Format: Text})
+	if err != nil {
+		// unknown levels fall back to info
+		l, _ = NewWithOptions(Options{Level: Info, Format: Text})
+	}
+	return l
+}
+
+// NewWithOptions creates a new logger from opts.
+func NewWithOptions(opts Options) (*Logger, error) {
 	lr := logrus.New()
 
-	switch level {
+	switch opts.Level {
 	case Debug:
 		lr.SetLevel(logrus.DebugLevel)
-	case Info:
+	case Info, "":
 		lr.SetLevel(logrus.InfoLevel)
 	case Warn:
 		lr.SetLevel(logrus.WarnLevel)
 	case Error:
 		lr.SetLevel(logrus.ErrorLevel)
 	default:
-		lr.SetLevel(logrus.InfoLevel)
+		return nil, fmt.Errorf("unknown log level %q", opts.Level)
+	}
+
+	switch opts.Format {
+	case Text, "":
+		lr.SetFormatter(&logrus.TextFormatter{
+			FullTimestamp: true,
+		})
+	case JSON:
+		lr.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return nil, fmt.Errorf("unknown log format %q", opts.Format)
 	}
 
-	lr.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp: true,
-	})
+	lr.SetOutput(os.Stderr)
+	if opts.Output != nil {
+		lr.SetOutput(opts.Output)
+	}
 
 	return &Logger{
-		internal: logrus.New(),
+		internal: lr,
+	}, nil
+}
+
+// WithField returns a logger attaching the field to every message.
+func (l *Logger) WithField(key string, value interface{}) *Logger {
+	return l.WithFields(Fields{key: value})
+}
+
+// WithFields returns a logger attaching the fields to every message.
+func (l *Logger) WithFields(fields Fields) *Logger {
+	f, ok := l.internal.(fielder)
+	if !ok {
+		return l
+	}
+
+	return &Logger{
+		internal: f.WithFields(logrus.Fields(fields)),
+	}
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the logger.
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or an info level text
+// logger if there is none.
+func FromContext(ctx context.Context) *Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+			return l
+		}
 	}
+	return New(Info)
 }
 
 // Info logs an info message.
@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	t.Run("honours the level", func(tt *testing.T) {
+		var buf bytes.Buffer
+		l, err := NewWithOptions(Options{Level: Warn, Output: &buf})
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		l.Info("hidden")
+		l.Warn("shown")
+
+		if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "shown") {
+			tt.Fatalf("expected only the warning to be logged, got %q", buf.String())
+		}
+	})
+
+	t.Run("json with fields", func(tt *testing.T) {
+		var buf bytes.Buffer
+		l, err := NewWithOptions(Options{Level: Debug, Format: JSON, Output: &buf})
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		l.WithField(FieldOperationID, "op").WithFields(Fields{FieldHardwareID: "hw-1"}).Debugf("rebooting %s", "hw-1")
+
+		var entry map[string]string
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			tt.Fatalf("expected a json log line, got %q: %s", buf.String(), err)
+		}
+
+		if entry["msg"] != "rebooting hw-1" || entry[FieldOperationID] != "op" || entry[FieldHardwareID] != "hw-1" {
+			tt.Fatalf("unexpected log entry %v", entry)
+		}
+	})
+
+	t.Run("rejects unknown options", func(tt *testing.T) {
+		if _, err := NewWithOptions(Options{Level: "verbose"}); err == nil {
+			tt.Fatalf("expecting an error but got nil")
+		}
+
+		if _, err := NewWithOptions(Options{Format: "xml"}); err == nil {
+			tt.Fatalf("expecting an error but got nil")
+		}
+	})
+}
+
+func TestFromContext(t *testing.T) {
+	l := New(Debug)
+
+	if got := FromContext(NewContext(context.Background(), l)); got != l {
+		t.Fatalf("expected the logger carried by the context")
+	}
+
+	if FromContext(context.Background()) == nil {
+		t.Fatalf("expected a default logger")
+	}
+
+	// fields on the noop logger are dropped
+	if NOOPLogger.WithField(FieldJob, "job") != NOOPLogger {
+		t.Fatalf("expected the noop logger to be returned")
+	}
+}
@@ -0,0 +1,320 @@
+package preflight
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/konstructio/colony/internal/checks"
+	"github.com/konstructio/colony/internal/container"
+)
+
+const (
+	// minimum free disk space under the colony directory
+	diskWarnBytes = 20 << 30
+	diskFailBytes = 5 << 30
+)
+
+// port is a host port the colony stack binds to.
+type port struct {
+	Number   int
+	Protocol string
+	Service  string
+}
+
+var requiredPorts = []port{
+	{67, "udp", "dhcp"},
+	{69, "udp", "tftp"},
+	{80, "tcp", "http"},
+}
+
+// k3sPorts are only bound when colony runs its own k3s container.
+var k3sPorts = []port{
+	{6443, "tcp", "kube-apiserver"},
+}
+
+var requiredKernelModules = []string{"overlay", "br_netfilter", "nf_conntrack"}
+
+// arpTable is where the kernel exposes its ARP cache.
+const arpTable = "/proc/net/arp"
+
+// DaemonInfoer returns details about the container daemon.
+type DaemonInfoer interface {
+	Name() string
+	Info(ctx context.Context) (*container.DaemonInfo, error)
+}
+
+// Options holds the host settings to check before installing colony.
+type Options struct {
+	LoadBalancerIP        string
+	LoadBalancerInterface string
+	ColonyDir             string
+	Runtime               DaemonInfoer
+
+	// External is set when colony is installed into an existing cluster,
+	// which needs neither a container runtime nor the kube-apiserver port.
+	External bool
+}
+
+// Run runs every host check and returns the report. It does not
+// contact the colony API.
+func Run(ctx context.Context, opts Options) checks.Report {
+	if opts.External {
+		return checks.Report{
+			checkInterface(opts.LoadBalancerInterface, opts.LoadBalancerIP),
+			checkIPConflict(ctx, opts.LoadBalancerIP),
+			checkPorts(requiredPorts),
+			checkDiskSpace(opts.ColonyDir),
+		}
+	}
+
+	return checks.Report{
+		checkInterface(opts.LoadBalancerInterface, opts.LoadBalancerIP),
+		checkIPConflict(ctx, opts.LoadBalancerIP),
+		checkPorts(append(requiredPorts, k3sPorts...)),
+		checkRuntime(ctx, opts.Runtime),
+		checkDiskSpace(opts.ColonyDir),
+		checkKernelModules(),
+	}
+}
+
+func checkInterface(name, ip string) checks.Result {
+	const check = "interface"
+
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return checks.Failf(check, "interface %q not found: %s", name, err)
+	}
+
+	if iface.Flags&net.FlagUp == 0 {
+		return checks.Failf(check, "interface %q is down", name)
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return checks.Failf(check, "unable to read addresses of interface %q: %s", name, err)
+	}
+
+	lbIP := net.ParseIP(ip)
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.Contains(lbIP) {
+			return checks.Passf(check, "interface %q is up and %s is in subnet %s", name, ip, ipNet)
+		}
+	}
+
+	return checks.Warnf(check, "interface %q is up but %s is not in any of its subnets", name, ip)
+}
+
+func checkIPConflict(ctx context.Context, ip string) checks.Result {
+	const check = "ip-conflict"
+
+	if local, err := localInterfaceWithIP(ip); err != nil {
+		return checks.Warnf(check, "unable to list local addresses: %s", err)
+	} else if local != "" {
+		return checks.Warnf(check, "%s is already assigned to local interface %q", ip, local)
+	}
+
+	// sending a packet to the address makes the kernel resolve it,
+	// after which any host answering for it shows up in the ARP table
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", net.JoinHostPort(ip, "9"))
+	if err == nil {
+		conn.Write([]byte{0})
+		conn.Close()
+	}
+
+	select {
+	case <-ctx.Done():
+		return checks.Warnf(check, "cancelled before the ARP table could be read")
+	case <-time.After(time.Second):
+	}
+
+	mac, err := arpEntry(arpTable, ip)
+	if err != nil {
+		return checks.Warnf(check, "unable to read ARP table: %s", err)
+	}
+
+	if mac != "" {
+		return checks.Failf(check, "%s is already answering ARP from %s", ip, mac)
+	}
+
+	return checks.Passf(check, "no host is answering ARP for %s", ip)
+}
+
+func localInterfaceWithIP(ip string) (string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", fmt.Errorf("error listing interfaces: %w", err)
+	}
+
+	lbIP := net.ParseIP(ip)
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(lbIP) {
+				return iface.Name, nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// arpEntry returns the hardware address resolved for ip in the ARP
+// table at path, or an empty string if no host answered.
+func arpEntry(path, ip string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("error opening ARP table: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// IP address, HW type, Flags, HW address, Mask, Device
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[0] != ip {
+			continue
+		}
+
+		// 0x2 is ATF_COM, the entry has been resolved
+		if fields[2] == "0x2" && fields[3] != "00:00:00:00:00:00" {
+			return fields[3], nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("error reading ARP table: %w", err)
+	}
+
+	return "", nil
+}
+
+func checkPorts(ports []port) checks.Result {
+	const check = "ports"
+
+	var inUse, denied []string
+	for _, p := range ports {
+		address := fmt.Sprintf(":%d", p.Number)
+
+		var err error
+		if p.Protocol == "udp" {
+			var conn net.PacketConn
+			conn, err = net.ListenPacket(p.Protocol, address)
+			if err == nil {
+				conn.Close()
+			}
+		} else {
+			var listener net.Listener
+			listener, err = net.Listen(p.Protocol, address)
+			if err == nil {
+				listener.Close()
+			}
+		}
+
+		switch {
+		case err == nil:
+		case errors.Is(err, syscall.EACCES):
+			denied = append(denied, fmt.Sprintf("%d/%s", p.Number, p.Protocol))
+		default:
+			inUse = append(inUse, fmt.Sprintf("%d/%s (%s)", p.Number, p.Protocol, p.Service))
+		}
+	}
+
+	if len(inUse) > 0 {
+		return checks.Failf(check, "ports already in use: %s", strings.Join(inUse, ", "))
+	}
+
+	if len(denied) > 0 {
+		return checks.Warnf(check, "permission denied binding %s, run as root to check them", strings.Join(denied, ", "))
+	}
+
+	return checks.Passf(check, "all required ports are available")
+}
+
+func checkRuntime(ctx context.Context, client DaemonInfoer) checks.Result {
+	const check = "container-runtime"
+
+	if client == nil {
+		return checks.Failf(check, "no container runtime configured")
+	}
+
+	name := client.Name()
+
+	info, err := client.Info(ctx)
+	if err != nil {
+		return checks.Failf(check, "%s daemon is not reachable: %s", name, err)
+	}
+
+	if info.OSType != "linux" {
+		return checks.Failf(check, "%s daemon runs %q containers, colony needs linux", name, info.OSType)
+	}
+
+	if info.Rootless {
+		return checks.Failf(check, "%s daemon %s runs rootless and cannot run privileged host-network containers", name, info.ServerVersion)
+	}
+
+	return checks.Passf(check, "%s daemon %s can run privileged host-network containers (cgroup %s)", name, info.ServerVersion, info.CgroupVersion)
+}
+
+func checkDiskSpace(dir string) checks.Result {
+	const check = "disk-space"
+
+	// the colony directory may not exist yet, check its closest parent
+	path := dir
+	for {
+		if _, err := os.Stat(path); err == nil || filepath.Dir(path) == path {
+			break
+		}
+		path = filepath.Dir(path)
+	}
+
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return checks.Warnf(check, "unable to read free space of %q: %s", path, err)
+	}
+
+	free := stat.Bavail * uint64(stat.Bsize)
+	freeGiB := float64(free) / (1 << 30)
+
+	switch {
+	case free < diskFailBytes:
+		return checks.Failf(check, "only %.1f GiB free under %q", freeGiB, path)
+	case free < diskWarnBytes:
+		return checks.Warnf(check, "only %.1f GiB free under %q", freeGiB, path)
+	default:
+		return checks.Passf(check, "%.1f GiB free under %q", freeGiB, path)
+	}
+}
+
+func checkKernelModules() checks.Result {
+	const check = "kernel-modules"
+
+	if _, err := os.Stat("/sys/module"); err != nil {
+		return checks.Warnf(check, "unable to list kernel modules: %s", err)
+	}
+
+	var missing []string
+	for _, module := range requiredKernelModules {
+		// built-in and loaded modules both show up under /sys/module
+		if _, err := os.Stat(filepath.Join("/sys/module", module)); err != nil {
+			missing = append(missing, module)
+		}
+	}
+
+	if len(missing) > 0 {
+		return checks.Warnf(check, "kernel modules not loaded: %s (load them with modprobe)", strings.Join(missing, ", "))
+	}
+
+	return checks.Passf(check, "required kernel modules are loaded")
+}
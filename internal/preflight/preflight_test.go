@@ -0,0 +1,165 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/konstructio/colony/internal/checks"
+	"github.com/konstructio/colony/internal/container"
+)
+
+const arpFixture = `IP address       HW type     Flags       HW address            Mask     Device
+192.168.0.1      0x1         0x2         aa:bb:cc:dd:ee:01     *        eth0
+192.168.0.2      0x1         0x0         00:00:00:00:00:00     *        eth0
+192.168.0.3      0x1         0x2         00:00:00:00:00:00     *        eth0
+`
+
+func Test_arpEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arp")
+	if err := os.WriteFile(path, []byte(arpFixture), 0o600); err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	tests := []struct {
+		name string
+		ip   string
+		want string
+	}{
+		{name: "resolved entry", ip: "192.168.0.1", want: "aa:bb:cc:dd:ee:01"},
+		{name: "incomplete entry", ip: "192.168.0.2", want: ""},
+		{name: "resolved without address", ip: "192.168.0.3", want: ""},
+		{name: "no entry", ip: "192.168.0.4", want: ""},
+		{name: "header is not an entry", ip: "IP", want: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(tt *testing.T) {
+			got, err := arpEntry(path, tc.ip)
+			if err != nil {
+				tt.Fatalf("not expecting an error but got: %s", err)
+			}
+
+			if got != tc.want {
+				tt.Fatalf("expected %q but got %q", tc.want, got)
+			}
+		})
+	}
+
+	t.Run("missing table", func(tt *testing.T) {
+		if _, err := arpEntry(filepath.Join(tt.TempDir(), "missing"), "192.168.0.1"); err == nil {
+			tt.Fatalf("expected an error but got none")
+		}
+	})
+}
+
+func Test_checkInterface(t *testing.T) {
+	lo := loopbackInterface(t)
+
+	tests := []struct {
+		name  string
+		iface string
+		ip    string
+		want  checks.Status
+	}{
+		{name: "ip in subnet", iface: lo, ip: "127.0.0.1", want: checks.Pass},
+		{name: "ip outside subnet", iface: lo, ip: "192.0.2.10", want: checks.Warn},
+		{name: "missing interface", iface: "colony-missing0", ip: "127.0.0.1", want: checks.Fail},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(tt *testing.T) {
+			if got := checkInterface(tc.iface, tc.ip); got.Status != tc.want {
+				tt.Fatalf("expected %s but got %s: %s", tc.want, got.Status, got.Message)
+			}
+		})
+	}
+}
+
+func Test_checkPorts(t *testing.T) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+	defer listener.Close()
+
+	busy := listener.Addr().(*net.TCPAddr).Port
+
+	t.Run("port in use", func(tt *testing.T) {
+		got := checkPorts([]port{{busy, "tcp", "test"}})
+		if got.Status != checks.Fail {
+			tt.Fatalf("expected %s but got %s: %s", checks.Fail, got.Status, got.Message)
+		}
+	})
+
+	t.Run("port available", func(tt *testing.T) {
+		free, err := net.Listen("tcp", ":0")
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+		number := free.Addr().(*net.TCPAddr).Port
+		free.Close()
+
+		got := checkPorts([]port{{number, "tcp", "test"}})
+		if got.Status != checks.Pass {
+			tt.Fatalf("expected %s but got %s: %s", checks.Pass, got.Status, got.Message)
+		}
+	})
+}
+
+type unreachableDaemon struct{}
+
+func (unreachableDaemon) Name() string { return "unreachable" }
+
+func (unreachableDaemon) Info(context.Context) (*container.DaemonInfo, error) {
+	return nil, errors.New("connection refused")
+}
+
+func Test_checkRuntime(t *testing.T) {
+	rootless := container.NewFake()
+	rootless.Daemon.Rootless = true
+
+	windows := container.NewFake()
+	windows.Daemon.OSType = "windows"
+
+	tests := []struct {
+		name    string
+		runtime DaemonInfoer
+		want    checks.Status
+	}{
+		{name: "privileged linux daemon", runtime: container.NewFake(), want: checks.Pass},
+		{name: "rootless daemon", runtime: rootless, want: checks.Fail},
+		{name: "windows daemon", runtime: windows, want: checks.Fail},
+		{name: "unreachable daemon", runtime: unreachableDaemon{}, want: checks.Fail},
+		{name: "no runtime", runtime: nil, want: checks.Fail},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(tt *testing.T) {
+			if got := checkRuntime(context.Background(), tc.runtime); got.Status != tc.want {
+				tt.Fatalf("expected %s but got %s: %s", tc.want, got.Status, got.Message)
+			}
+		})
+	}
+}
+
+func loopbackInterface(t *testing.T) string {
+	t.Helper()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 && iface.Flags&net.FlagUp != 0 {
+			return iface.Name
+		}
+	}
+
+	t.Skip("no loopback interface is up")
+	return ""
+}
@@ -0,0 +1,174 @@
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/konstructio/colony/internal/table"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/yaml"
+)
+
+// Output formats.
+const (
+	Table      = "table"
+	Wide       = "wide"
+	JSON       = "json"
+	YAML       = "yaml"
+	JSONPath   = "jsonpath"
+	GoTemplate = "go-template"
+)
+
+// Formats lists the supported output formats, for flag help.
+var Formats = []string{Table, Wide, JSON, YAML, JSONPath + "=<expr>", GoTemplate + "=<template>"}
+
+// Column is a table column. Wide columns are only printed with -o wide.
+type Column struct {
+	Name  string
+	Align string
+	Wide  bool
+}
+
+// Rows is the table view of the printed data, one row per item keyed by
+// column name.
+type Rows struct {
+	Columns []Column
+	Items   []map[string]string
+}
+
+// Printer prints data in one of the output formats.
+type Printer struct {
+	format   string
+	jsonPath *jsonpath.JSONPath
+	template *template.Template
+}
+
+// New returns a printer for an -o flag value. The empty format is a table.
+func New(output string) (*Printer, error) {
+	format, expr, hasExpr := strings.Cut(output, "=")
+
+	switch format {
+	case "", Table, Wide, JSON, YAML:
+		if hasExpr {
+			return nil, fmt.Errorf("output format %q takes no expression", format)
+		}
+		if format == "" {
+			format = Table
+		}
+		return &Printer{format: format}, nil
+
+	case JSONPath:
+		if expr == "" {
+			return nil, fmt.Errorf("output format %s requires an expression, e.g. %s='{[*].name}'", JSONPath, JSONPath)
+		}
+
+		// like kubectl, accept expressions without the surrounding braces
+		if !strings.HasPrefix(expr, "{") {
+			expr = "{" + expr + "}"
+		}
+
+		jp := jsonpath.New("output")
+		if err := jp.Parse(expr); err != nil {
+			return nil, fmt.Errorf("error parsing jsonpath %q: %w", expr, err)
+		}
+
+		return &Printer{format: format, jsonPath: jp}, nil
+
+	case GoTemplate:
+		if expr == "" {
+			return nil, fmt.Errorf("output format %s requires a template", GoTemplate)
+		}
+
+		tmpl, err := template.New("output").Parse(expr)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing go-template %q: %w", expr, err)
+		}
+
+		return &Printer{format: format, template: tmpl}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported output format %q, must be one of: %s", output, strings.Join(Formats, ", "))
+	}
+}
+
+// IsTable returns true if the printer prints a table.
+func (p *Printer) IsTable() bool {
+	return p.format == Table || p.format == Wide
+}
+
+// Print writes data to w. Tables are printed from rows, every other
+// format from data. jsonpath and go-template see data through its json
+// encoding, so expressions use the json field names.
+func (p *Printer) Print(w io.Writer, data interface{}, rows Rows) error {
+	switch p.format {
+	case Table, Wide:
+		columns := make([]table.Column, 0, len(rows.Columns))
+		for _, col := range rows.Columns {
+			if col.Wide && p.format != Wide {
+				continue
+			}
+
+			align := col.Align
+			if align == "" {
+				align = "left"
+			}
+			columns = append(columns, table.Column{Name: col.Name, Align: align})
+		}
+
+		table.NewTablePrinter(columns).FprintTable(w, rows.Items)
+		return nil
+
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(data); err != nil {
+			return fmt.Errorf("error encoding json: %w", err)
+		}
+		return nil
+
+	case YAML:
+		content, err := yaml.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("error encoding yaml: %w", err)
+		}
+
+		if _, err := w.Write(content); err != nil {
+			return fmt.Errorf("error writing yaml: %w", err)
+		}
+		return nil
+	}
+
+	generic, err := toGeneric(data)
+	if err != nil {
+		return err
+	}
+
+	if p.jsonPath != nil {
+		if err := p.jsonPath.Execute(w, generic); err != nil {
+			return fmt.Errorf("error executing jsonpath: %w", err)
+		}
+	} else if err := p.template.Execute(w, generic); err != nil {
+		return fmt.Errorf("error executing go-template: %w", err)
+	}
+
+	fmt.Fprintln(w)
+	return nil
+}
+
+// toGeneric round trips data through json into maps and slices.
+func toGeneric(data interface{}) (interface{}, error) {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding json: %w", err)
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(content, &generic); err != nil {
+		return nil, fmt.Errorf("error decoding json: %w", err)
+	}
+
+	return generic, nil
+}
@@ -0,0 +1,72 @@
+package printer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type item struct {
+	Name  string `json:"name"`
+	BMCIP string `json:"bmcIP"`
+}
+
+func TestPrinter_Print(t *testing.T) {
+	data := []item{{Name: "hw-1", BMCIP: "10.0.0.1"}, {Name: "hw-2", BMCIP: "10.0.0.2"}}
+	rows := Rows{
+		Columns: []Column{{Name: "name"}, {Name: "bmc ip", Wide: true}},
+		Items: []map[string]string{
+			{"name": "hw-1", "bmc ip": "10.0.0.1"},
+			{"name": "hw-2", "bmc ip": "10.0.0.2"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		output   string
+		expected []string
+		missing  []string
+	}{
+		{name: "table", output: "", expected: []string{"NAME", "hw-1"}, missing: []string{"BMC IP"}},
+		{name: "wide", output: "wide", expected: []string{"NAME", "BMC IP", "10.0.0.2"}},
+		{name: "json", output: "json", expected: []string{`"bmcIP": "10.0.0.1"`}},
+		{name: "yaml", output: "yaml", expected: []string{"- bmcIP: 10.0.0.1\n  name: hw-1"}},
+		{name: "jsonpath", output: "jsonpath={[*].name}", expected: []string{"hw-1 hw-2\n"}},
+		{name: "jsonpath without braces", output: "jsonpath=[0].bmcIP", expected: []string{"10.0.0.1\n"}},
+		{name: "go-template", output: `go-template={{range .}}{{.name}},{{end}}`, expected: []string{"hw-1,hw-2,\n"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(tt *testing.T) {
+			p, err := New(tc.output)
+			if err != nil {
+				tt.Fatalf("not expecting an error but got: %s", err)
+			}
+
+			var buf bytes.Buffer
+			if err := p.Print(&buf, data, rows); err != nil {
+				tt.Fatalf("not expecting an error but got: %s", err)
+			}
+
+			for _, s := range tc.expected {
+				if !strings.Contains(buf.String(), s) {
+					tt.Fatalf("expected output to contain %q, got:\n%s", s, buf.String())
+				}
+			}
+
+			for _, s := range tc.missing {
+				if strings.Contains(buf.String(), s) {
+					tt.Fatalf("expected output not to contain %q, got:\n%s", s, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, output := range []string{"xml", "json=foo", "jsonpath=", "jsonpath={.name", "go-template={{.name"} {
+		if _, err := New(output); err == nil {
+			t.Fatalf("expecting an error for %q but got nil", output)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Package rollout runs an action over many items in batches, the way
+// a rolling reboot goes through the servers of a rack.
+package rollout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Outcome is what happened to an item of the rollout.
+type Outcome string
+
+const (
+	// Succeeded items ran without error.
+	Succeeded Outcome = "succeeded"
+	// Failed items ran with an error.
+	Failed Outcome = "failed"
+	// Skipped items never ran.
+	Skipped Outcome = "skipped"
+)
+
+// Options configure a rollout.
+type Options struct {
+	// BatchSize is the number of items run at once.
+	BatchSize int
+	// MaxUnavailable caps the items running at once. Failed items only
+	// count against it while they run. Zero means BatchSize.
+	MaxUnavailable int
+	// Pause is the time waited between batches.
+	Pause time.Duration
+	// ContinueOnError runs the next batches after a batch failed.
+	ContinueOnError bool
+	// OnBatch is called before a batch runs with its 1-based number and
+	// the indexes of its items.
+	OnBatch func(batch int, items []int)
+}
+
+// Result is the outcome of an item. Err is the error of a failed item or
+// the reason an item was skipped.
+type Result struct {
+	Batch   int
+	Outcome Outcome
+	Err     error
+}
+
+// Validate checks the options.
+func (o Options) Validate() error {
+	if o.BatchSize < 1 {
+		return errors.New("batch size must be at least 1")
+	}
+	if o.MaxUnavailable < 0 {
+		return errors.New("max unavailable cannot be negative")
+	}
+	if o.Pause < 0 {
+		return errors.New("pause cannot be negative")
+	}
+	return nil
+}
+
+// Run runs fn on the items in batches and returns the result of every
+// item, in the order of the items. The items of a batch run concurrently
+// and the next batch starts once all of them returned. After a failed
+// batch, or once ctx is done, the remaining items are skipped. Invalid
+// options skip every item.
+func Run[T any](ctx context.Context, items []T, opts Options, fn func(context.Context, T) error) []Result {
+	results := make([]Result, len(items))
+	if err := opts.Validate(); err != nil {
+		skip(results, err)
+		return results
+	}
+
+	maxUnavailable := opts.MaxUnavailable
+	if maxUnavailable == 0 {
+		maxUnavailable = opts.BatchSize
+	}
+
+	next := 0
+	for batch := 1; next < len(items); batch++ {
+		size := min(opts.BatchSize, maxUnavailable, len(items)-next)
+
+		if batch > 1 && opts.Pause > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(opts.Pause):
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			skip(results[next:], fmt.Errorf("rollout interrupted: %w", err))
+			break
+		}
+
+		indexes := make([]int, size)
+		for i := range indexes {
+			indexes[i] = next + i
+		}
+		if opts.OnBatch != nil {
+			opts.OnBatch(batch, indexes)
+		}
+
+		var wg sync.WaitGroup
+		for _, i := range indexes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = Result{Batch: batch, Outcome: Succeeded}
+				if err := fn(ctx, items[i]); err != nil {
+					results[i] = Result{Batch: batch, Outcome: Failed, Err: err}
+				}
+			}()
+		}
+		wg.Wait()
+		next += size
+
+		batchFailed := slices.ContainsFunc(indexes, func(i int) bool {
+			return results[i].Outcome == Failed
+		})
+
+		if batchFailed && !opts.ContinueOnError {
+			skip(results[next:], fmt.Errorf("batch %d failed", batch))
+			break
+		}
+	}
+
+	return results
+}
+
+func skip(results []Result, reason error) {
+	for i := range results {
+		results[i] = Result{Outcome: Skipped, Err: reason}
+	}
+}
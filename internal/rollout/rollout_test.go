@@ -0,0 +1,149 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	items := []int{0, 1, 2, 3, 4, 5, 6}
+
+	run := func(opts Options, fail map[int]bool) ([]Result, [][]int) {
+		var mu sync.Mutex
+		var batches [][]int
+		opts.OnBatch = func(_ int, indexes []int) {
+			mu.Lock()
+			defer mu.Unlock()
+			batches = append(batches, indexes)
+		}
+
+		results := Run(context.Background(), items, opts, func(_ context.Context, item int) error {
+			if fail[item] {
+				return errors.New("boom")
+			}
+			return nil
+		})
+		return results, batches
+	}
+
+	outcomes := func(results []Result) []Outcome {
+		o := make([]Outcome, 0, len(results))
+		for _, r := range results {
+			o = append(o, r.Outcome)
+		}
+		return o
+	}
+
+	t.Run("all batches succeed", func(tt *testing.T) {
+		results, batches := run(Options{BatchSize: 3}, nil)
+
+		if len(batches) != 3 || len(batches[2]) != 1 {
+			tt.Fatalf("expected batches of 3, 3 and 1 but got %v", batches)
+		}
+		for i, r := range results {
+			if r.Outcome != Succeeded || r.Batch != i/3+1 {
+				tt.Fatalf("unexpected result %d: %+v", i, r)
+			}
+		}
+	})
+
+	t.Run("stops on the first failed batch", func(tt *testing.T) {
+		results, batches := run(Options{BatchSize: 2}, map[int]bool{3: true})
+
+		if len(batches) != 2 {
+			tt.Fatalf("expected 2 batches but got %v", batches)
+		}
+
+		want := []Outcome{Succeeded, Succeeded, Succeeded, Failed, Skipped, Skipped, Skipped}
+		if got := outcomes(results); !slices.Equal(got, want) {
+			tt.Fatalf("expected %v but got %v", want, got)
+		}
+	})
+
+	t.Run("continues after a failed batch", func(tt *testing.T) {
+		results, batches := run(Options{BatchSize: 1, ContinueOnError: true}, map[int]bool{1: true})
+
+		if len(batches) != len(items) {
+			tt.Fatalf("expected a batch per item but got %v", batches)
+		}
+
+		want := []Outcome{Succeeded, Failed, Succeeded, Succeeded, Succeeded, Succeeded, Succeeded}
+		if got := outcomes(results); !slices.Equal(got, want) {
+			tt.Fatalf("expected %v but got %v", want, got)
+		}
+	})
+
+	t.Run("max unavailable caps the batches", func(tt *testing.T) {
+		_, batches := run(Options{BatchSize: 5, MaxUnavailable: 2}, nil)
+
+		if len(batches) != 4 || len(batches[0]) != 2 {
+			tt.Fatalf("expected batches of 2 but got %v", batches)
+		}
+	})
+
+	t.Run("failures do not shrink the next batches", func(tt *testing.T) {
+		results, batches := run(Options{BatchSize: 3, MaxUnavailable: 3, ContinueOnError: true}, map[int]bool{0: true, 1: true, 2: true})
+
+		if len(batches) != 3 || len(batches[1]) != 3 {
+			tt.Fatalf("unexpected batches %v", batches)
+		}
+
+		want := []Outcome{Failed, Failed, Failed, Succeeded, Succeeded, Succeeded, Succeeded}
+		if got := outcomes(results); !slices.Equal(got, want) {
+			tt.Fatalf("expected %v but got %v", want, got)
+		}
+	})
+
+	t.Run("skips everything with invalid options", func(tt *testing.T) {
+		results, batches := run(Options{}, nil)
+
+		if len(batches) != 0 {
+			tt.Fatalf("expected no batch but got %v", batches)
+		}
+		for _, r := range results {
+			if r.Outcome != Skipped || r.Err == nil {
+				tt.Fatalf("unexpected result %+v", r)
+			}
+		}
+	})
+
+	t.Run("skips everything once interrupted", func(tt *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		for _, r := range Run(ctx, items, Options{BatchSize: 2}, func(context.Context, int) error { return nil }) {
+			if r.Outcome != Skipped || !errors.Is(r.Err, context.Canceled) {
+				tt.Fatalf("unexpected result %+v", r)
+			}
+		}
+	})
+}
+
+func TestOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  Options
+		valid bool
+	}{
+		{name: "defaults", opts: Options{BatchSize: 1}, valid: true},
+		{name: "no batch size", opts: Options{}},
+		{name: "negative max unavailable", opts: Options{BatchSize: 1, MaxUnavailable: -1}},
+		{name: "negative pause", opts: Options{BatchSize: 1, Pause: -1}},
+		{name: "continue on error with max unavailable of the batch size", opts: Options{BatchSize: 5, MaxUnavailable: 5, ContinueOnError: true}, valid: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(tt *testing.T) {
+			err := tc.opts.Validate()
+			if tc.valid && err != nil {
+				tt.Fatalf("not expecting an error but got: %s", err)
+			}
+			if !tc.valid && err == nil {
+				tt.Fatal("expected an error but got nil")
+			}
+		})
+	}
+}
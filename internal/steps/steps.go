@@ -0,0 +1,116 @@
+package steps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/konstructio/colony/internal/logger"
+)
+
+// Step is a named unit of work. Steps must be safe to run again
+// after a partial failure.
+type Step struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// State records which steps of a multi-step operation completed,
+// persisted to a file so the operation can be resumed.
+type State struct {
+	path      string
+	Completed map[string]time.Time `json:"completed"`
+	Finished  bool                 `json:"finished,omitempty"`
+}
+
+// Load reads the state file at path. A missing file is an empty state.
+func Load(path string) (*State, error) {
+	state := &State{
+		path:      path,
+		Completed: make(map[string]time.Time),
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return state, nil
+		}
+		return nil, fmt.Errorf("error reading state file %q: %w", path, err)
+	}
+
+	if err := json.Unmarshal(content, state); err != nil {
+		return nil, fmt.Errorf("error parsing state file %q: %w", path, err)
+	}
+
+	if state.Completed == nil {
+		state.Completed = make(map[string]time.Time)
+	}
+
+	return state, nil
+}
+
+// Started returns true if any step has completed.
+func (s *State) Started() bool {
+	return len(s.Completed) > 0
+}
+
+// Done returns true if the named step has completed.
+func (s *State) Done(name string) bool {
+	_, ok := s.Completed[name]
+	return ok
+}
+
+// MarkDone records the named step as completed and saves the state.
+func (s *State) MarkDone(name string) error {
+	s.Completed[name] = time.Now().UTC()
+	return s.save()
+}
+
+func (s *State) save() error {
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("unable to create directory for %q: %w", s.path, err)
+	}
+
+	if err := os.WriteFile(s.path, content, 0o600); err != nil {
+		return fmt.Errorf("error writing state file %q: %w", s.path, err)
+	}
+
+	return nil
+}
+
+// Run runs the steps in order, skipping those already recorded as
+// completed, and records each step as it completes. The state is
+// marked as finished once every step completed.
+func Run(ctx context.Context, log *logger.Logger, state *State, steps []Step) error {
+	for _, step := range steps {
+		if state.Done(step.Name) {
+			log.Infof("step %q already completed, skipping", step.Name)
+			continue
+		}
+
+		log.Infof("running step %q", step.Name)
+		if err := step.Run(ctx); err != nil {
+			return fmt.Errorf("step %q failed: %w", step.Name, err)
+		}
+
+		if err := state.MarkDone(step.Name); err != nil {
+			return fmt.Errorf("error recording step %q: %w", step.Name, err)
+		}
+	}
+
+	state.Finished = true
+	if err := state.save(); err != nil {
+		return fmt.Errorf("error recording completion: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package steps
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/konstructio/colony/internal/logger"
+)
+
+func TestRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	ctx := context.TODO()
+
+	var ran []string
+	failSecond := true
+
+	steps := []Step{
+		{Name: "first", Run: func(context.Context) error {
+			ran = append(ran, "first")
+			return nil
+		}},
+		{Name: "second", Run: func(context.Context) error {
+			ran = append(ran, "second")
+			if failSecond {
+				return errors.New("boom")
+			}
+			return nil
+		}},
+	}
+
+	state, err := Load(path)
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	if err := Run(ctx, logger.NOOPLogger, state, steps); err == nil {
+		t.Fatalf("expecting an error but got nil")
+	}
+
+	// resume from the persisted state
+	failSecond = false
+	state, err = Load(path)
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	if !state.Done("first") || state.Done("second") || state.Finished {
+		t.Fatalf("expected only the first step to be recorded, got %v", state.Completed)
+	}
+
+	if err := Run(ctx, logger.NOOPLogger, state, steps); err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	expected := []string{"first", "second", "second"}
+	if len(ran) != len(expected) {
+		t.Fatalf("expected steps %v to run but got %v", expected, ran)
+	}
+	for i := range expected {
+		if ran[i] != expected[i] {
+			t.Fatalf("expected steps %v to run but got %v", expected, ran)
+		}
+	}
+
+	state, err = Load(path)
+	if err != nil {
+		t.Fatalf("not expecting an error but got: %s", err)
+	}
+
+	if !state.Finished {
+		t.Fatalf("expected the state to be finished")
+	}
+}
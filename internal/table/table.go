@@ -2,9 +2,10 @@ package table
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"strings"
 
-	tinkv1 "github.com/kubefirst/tink/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -59,37 +60,31 @@ func (t *TablePrinter) calculateColumnWidths(rows []map[string]string) {
 }
 
 func (t *TablePrinter) PrintTable(rows []map[string]string) {
+	t.FprintTable(os.Stdout, rows)
+}
+
+// FprintTable prints the table to w.
+func (t *TablePrinter) FprintTable(w io.Writer, rows []map[string]string) {
 	t.calculateColumnWidths(rows)
 
 	// Print header
 	for _, col := range t.Columns {
-		fmt.Print(t.formatCell(strings.ToUpper(col.Name), col.Width, "left"))
+		fmt.Fprint(w, t.formatCell(strings.ToUpper(col.Name), col.Width, "left"))
 	}
-	fmt.Println()
+	fmt.Fprintln(w)
 
 	// Print rows
 	for _, row := range rows {
 		for _, col := range t.Columns {
 			value := row[col.Name]
-			fmt.Print(t.formatCell(value, col.Width, col.Align))
+			fmt.Fprint(w, t.formatCell(value, col.Width, col.Align))
 		}
-		fmt.Println()
+		fmt.Fprintln(w)
 	}
 }
 
 // Helper functions to convert objects to table rows
 
-func HardwareToRow(hw *tinkv1.Hardware) map[string]string {
-	return map[string]string{
-		"name":     hw.Name,
-		"hostname": hw.Annotations["inspection-status"],
-		"ip":       hw.Spec.Interfaces[0].DHCP.IP.Address,
-		"mac":      hw.Spec.Interfaces[0].DHCP.MAC,
-		// "foo":      hw.Spec.BMCRef.Name,
-		"status": string(hw.Status.State), // power
-	}
-}
-
 func SecretToRow(secret *corev1.Secret) map[string]string {
 	return map[string]string{
 		"name":      secret.Name,
@@ -0,0 +1,82 @@
+package versions
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/konstructio/colony/manifests"
+	"sigs.k8s.io/yaml"
+)
+
+// Versions are the versions of the components colony installs.
+type Versions struct {
+	K3sImage       string `json:"k3sImage"`
+	ColonyChart    string `json:"colonyChart"`
+	TinkStackChart string `json:"tinkStackChart"`
+	Hook           string `json:"hook"`
+}
+
+// Component is a single named component version, used to compare
+// two sets of versions.
+type Component struct {
+	Name    string
+	Version string
+}
+
+// Components returns the versions as an ordered list.
+func (v *Versions) Components() []Component {
+	return []Component{
+		{Name: "k3s", Version: v.K3sImage},
+		{Name: "colony chart", Version: v.ColonyChart},
+		{Name: "tink-stack chart", Version: v.TinkStackChart},
+		{Name: "hook", Version: v.Hook},
+	}
+}
+
+// Target returns the versions compiled into this colony binary.
+func Target() (*Versions, error) {
+	var v Versions
+	if err := yaml.UnmarshalStrict(manifests.Versions, &v); err != nil {
+		return nil, fmt.Errorf("error parsing version manifest: %w", err)
+	}
+	return &v, nil
+}
+
+// LoadInstalled reads the versions recorded by the last init or
+// upgrade. It returns nil if nothing was recorded.
+func LoadInstalled(path string) (*Versions, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil //nolint:nilnil // no recorded versions is not an error
+		}
+		return nil, fmt.Errorf("error reading installed versions %q: %w", path, err)
+	}
+
+	var v Versions
+	if err := yaml.Unmarshal(content, &v); err != nil {
+		return nil, fmt.Errorf("error parsing installed versions %q: %w", path, err)
+	}
+
+	return &v, nil
+}
+
+// Save records the versions as installed.
+func (v *Versions) Save(path string) error {
+	content, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error marshalling versions: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("unable to create directory for %q: %w", path, err)
+	}
+
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return fmt.Errorf("error writing installed versions %q: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,293 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/konstructio/colony/internal/constants"
+)
+
+const (
+	// DefaultContext is the context used when none is selected. Its files
+	// live directly in the colony home, where colony kept them before
+	// contexts existed.
+	DefaultContext = "default"
+
+	// HomeEnv overrides the colony home directory.
+	HomeEnv = "COLONY_HOME"
+
+	// ContextEnv selects the context when --context is not given.
+	ContextEnv = "COLONY_CONTEXT"
+
+	contextsDir        = "contexts"
+	currentContextFile = "current-context"
+)
+
+var (
+	// ErrContextNotFound is returned for a context that was never added.
+	ErrContextNotFound = errors.New("context not found")
+
+	// ErrContextExists is returned when adding a context twice.
+	ErrContextExists = errors.New("context already exists")
+
+	validName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+)
+
+// Home is the colony home directory, ~/.colony unless COLONY_HOME is
+// set. It holds the default context and the named contexts.
+type Home struct {
+	Dir string
+}
+
+// DefaultHome returns the colony home of the current user.
+func DefaultHome() (*Home, error) {
+	if dir := os.Getenv(HomeEnv); dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving %s %q: %w", HomeEnv, dir, err)
+		}
+		return &Home{Dir: abs}, nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("error getting user home directory: %w", err)
+	}
+
+	return &Home{Dir: filepath.Join(homeDir, constants.ColonyDir)}, nil
+}
+
+// Current returns the name of the context selected with `colony context
+// use`, or the default context.
+func (h *Home) Current() (string, error) {
+	content, err := os.ReadFile(filepath.Join(h.Dir, currentContextFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return DefaultContext, nil
+		}
+		return "", fmt.Errorf("error reading current context: %w", err)
+	}
+
+	name := strings.TrimSpace(string(content))
+	if name == "" {
+		return DefaultContext, nil
+	}
+
+	return name, nil
+}
+
+// Use makes the named context the current one.
+func (h *Home) Use(name string) error {
+	if _, err := h.Get(name); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(h.Dir, 0o755); err != nil {
+		return fmt.Errorf("error creating %s: %w", h.Dir, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(h.Dir, currentContextFile), []byte(name+"\n"), 0o644); err != nil {
+		return fmt.Errorf("error writing current context: %w", err)
+	}
+
+	return nil
+}
+
+// Resolve returns the context to use: the given name, else the one in
+// COLONY_CONTEXT, else the current context.
+func (h *Home) Resolve(name string) (*Context, error) {
+	if name == "" {
+		name = os.Getenv(ContextEnv)
+	}
+
+	if name == "" {
+		var err error
+		name, err = h.Current()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return h.Get(name)
+}
+
+// Get returns an existing context. The default context always exists.
+func (h *Home) Get(name string) (*Context, error) {
+	ctx, err := h.context(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx.Name == DefaultContext {
+		return ctx, nil
+	}
+
+	if _, err := os.Stat(ctx.Dir); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %q, add it with `colony context add %s`", ErrContextNotFound, name, name)
+		}
+		return nil, fmt.Errorf("error reading context %q: %w", name, err)
+	}
+
+	return ctx, nil
+}
+
+// Add creates a new named context.
+func (h *Home) Add(name string) (*Context, error) {
+	ctx, err := h.context(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx.Name == DefaultContext {
+		return nil, fmt.Errorf("%w: %q", ErrContextExists, name)
+	}
+
+	if _, err := os.Stat(ctx.Dir); err == nil {
+		return nil, fmt.Errorf("%w: %q", ErrContextExists, name)
+	}
+
+	if err := os.MkdirAll(ctx.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating context directory: %w", err)
+	}
+
+	return ctx, nil
+}
+
+// List returns the names of all contexts, the default one first.
+func (h *Home) List() ([]string, error) {
+	names := []string{DefaultContext}
+
+	entries, err := os.ReadDir(filepath.Join(h.Dir, contextsDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return names, nil
+		}
+		return nil, fmt.Errorf("error listing contexts: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() && validName.MatchString(entry.Name()) && entry.Name() != DefaultContext {
+			names = append(names, entry.Name())
+		}
+	}
+
+	slices.Sort(names[1:])
+	return names, nil
+}
+
+// Delete removes a named context and all of its files. The default and
+// the current context cannot be deleted.
+func (h *Home) Delete(name string) error {
+	ctx, err := h.Get(name)
+	if err != nil {
+		return err
+	}
+
+	if ctx.Name == DefaultContext {
+		return errors.New("the default context cannot be deleted")
+	}
+
+	current, err := h.Current()
+	if err != nil {
+		return err
+	}
+
+	if current == name {
+		return fmt.Errorf("context %q is the current context, switch to another one with `colony context use` first", name)
+	}
+
+	if err := os.RemoveAll(ctx.Dir); err != nil {
+		return fmt.Errorf("error deleting context %q: %w", name, err)
+	}
+
+	return nil
+}
+
+func (h *Home) context(name string) (*Context, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("invalid context name %q, use lowercase letters, digits and dashes", name)
+	}
+
+	if name == DefaultContext {
+		return &Context{Name: name, Dir: h.Dir, home: true}, nil
+	}
+
+	return &Context{Name: name, Dir: filepath.Join(h.Dir, contextsDir, name)}, nil
+}
+
+// Context is the directory holding the kubeconfig, saved init config and
+// init state of one colony installation.
+type Context struct {
+	Name string
+	Dir  string
+
+	// home is set for the default context, which shares its directory
+	// with the other contexts.
+	home bool
+}
+
+// KubeconfigPath returns the path of the kubeconfig of the colony cluster.
+func (c *Context) KubeconfigPath() string {
+	return filepath.Join(c.Dir, constants.KubeconfigHostPath)
+}
+
+// ConfigPath returns the path of the config saved by `colony init`.
+func (c *Context) ConfigPath() string {
+	return filepath.Join(c.Dir, constants.ColonyConfigPath)
+}
+
+// InitStatePath returns the path of the `colony init` step state.
+func (c *Context) InitStatePath() string {
+	return filepath.Join(c.Dir, constants.ColonyInitStatePath)
+}
+
+// VersionsPath returns the path of the installed component versions.
+func (c *Context) VersionsPath() string {
+	return filepath.Join(c.Dir, constants.ColonyVersionsPath)
+}
+
+// PendingUpdatesPath returns the path of the colony api updates queued
+// while the api was unreachable.
+func (c *Context) PendingUpdatesPath() string {
+	return filepath.Join(c.Dir, constants.ColonyPendingUpdatesPath)
+}
+
+// BootstrapPath returns the path of the rendered colony.yaml manifest.
+func (c *Context) BootstrapPath() string {
+	return filepath.Join(c.Dir, "k3s-bootstrap", constants.ColonyYamlPath)
+}
+
+// Installed returns true if colony was initialized in the context.
+func (c *Context) Installed() bool {
+	for _, path := range []string{c.KubeconfigPath(), c.InitStatePath()} {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Entries returns the names of the files and directories in the context
+// directory, leaving out the ones of the other contexts.
+func (c *Context) Entries() ([]string, error) {
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", c.Dir, err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if c.home && (entry.Name() == contextsDir || entry.Name() == currentContextFile) {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+
+	return names, nil
+}
@@ -0,0 +1,124 @@
+package workspace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestHome_Contexts(t *testing.T) {
+	home := &Home{Dir: t.TempDir()}
+	t.Setenv(ContextEnv, "")
+
+	t.Run("default context lives in the home directory", func(tt *testing.T) {
+		ctx, err := home.Resolve("")
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if ctx.Name != DefaultContext || ctx.KubeconfigPath() != filepath.Join(home.Dir, "kubeconfig") {
+			tt.Fatalf("unexpected default context %+v", ctx)
+		}
+	})
+
+	t.Run("add, use and list", func(tt *testing.T) {
+		lab, err := home.Add("lab")
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if _, err := home.Add("lab"); !errors.Is(err, ErrContextExists) {
+			tt.Fatalf("expecting %v but got: %v", ErrContextExists, err)
+		}
+
+		if err := home.Use("lab"); err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		ctx, err := home.Resolve("")
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if ctx.Dir != lab.Dir {
+			tt.Fatalf("expected the lab context, got %+v", ctx)
+		}
+
+		names, err := home.List()
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if !slices.Equal(names, []string{DefaultContext, "lab"}) {
+			tt.Fatalf("unexpected contexts %v", names)
+		}
+	})
+
+	t.Run("flag and env take precedence over the current context", func(tt *testing.T) {
+		if _, err := home.Add("prod"); err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		tt.Setenv(ContextEnv, "prod")
+
+		ctx, err := home.Resolve("")
+		if err != nil || ctx.Name != "prod" {
+			tt.Fatalf("expected the prod context, got %+v, %v", ctx, err)
+		}
+
+		ctx, err = home.Resolve(DefaultContext)
+		if err != nil || ctx.Name != DefaultContext {
+			tt.Fatalf("expected the default context, got %+v, %v", ctx, err)
+		}
+	})
+
+	t.Run("unknown and invalid contexts", func(tt *testing.T) {
+		if _, err := home.Resolve("missing"); !errors.Is(err, ErrContextNotFound) {
+			tt.Fatalf("expecting %v but got: %v", ErrContextNotFound, err)
+		}
+
+		if _, err := home.Add("../escape"); err == nil {
+			tt.Fatalf("expecting an error but got nil")
+		}
+	})
+
+	t.Run("delete", func(tt *testing.T) {
+		if err := home.Delete("lab"); err == nil {
+			tt.Fatalf("expecting an error deleting the current context but got nil")
+		}
+
+		if err := home.Delete(DefaultContext); err == nil {
+			tt.Fatalf("expecting an error deleting the default context but got nil")
+		}
+
+		if err := home.Delete("prod"); err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if _, err := home.Get("prod"); !errors.Is(err, ErrContextNotFound) {
+			tt.Fatalf("expecting %v but got: %v", ErrContextNotFound, err)
+		}
+	})
+
+	t.Run("default context entries leave out the other contexts", func(tt *testing.T) {
+		ctx, err := home.Get(DefaultContext)
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if err := os.WriteFile(ctx.ConfigPath(), []byte("{}"), 0o600); err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		entries, err := ctx.Entries()
+		if err != nil {
+			tt.Fatalf("not expecting an error but got: %s", err)
+		}
+
+		if !slices.Equal(entries, []string{"config.yaml"}) {
+			tt.Fatalf("unexpected entries %v", entries)
+		}
+	})
+}
@@ -18,3 +18,6 @@ var IPMI embed.FS
 
 //go:embed templates/*.yaml
 var Templates embed.FS
+
+//go:embed versions.yaml
+var Versions []byte